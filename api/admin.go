@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cachedRange is one cache entry as listed by /admin/cache
+type cachedRange struct {
+	Rink       string `json:"rink"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	AgeSeconds int    `json:"ageSeconds"`
+	Fresh      bool   `json:"fresh"`
+}
+
+// adminKeyName is who the access log and audit trail say made an admin request
+const adminKeyName = "admin"
+
+// adminAuthorized checks the request token against ADMIN_TOKEN, read from the same header as
+// AUTH_TOKEN. Admin routes are off entirely without it, even when AUTH_TOKEN is unset.
+func adminAuthorized(r *http.Request) bool {
+	expected := os.Getenv("ADMIN_TOKEN")
+	return expected != "" && subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(expected)) == 1
+}
+
+// adminCacheHandler lists the cached ranges with their ages (GET) or purges them (DELETE), either
+// every entry covering ?date= or everything
+func adminCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	store := cacheStore()
+
+	switch r.Method {
+	case http.MethodGet:
+		ranges := []cachedRange{}
+		for _, key := range store.keys() {
+			entry, ok := store.get(key)
+			if !ok {
+				continue
+			}
+			rk, start, end := splitCacheKey(key)
+			ranges = append(ranges, cachedRange{Rink: rk, Start: start, End: end, AgeSeconds: int(time.Since(entry.fetchedAt).Seconds()), Fresh: entry.fresh()})
+		}
+		sort.Slice(ranges, func(i, j int) bool {
+			if ranges[i].Rink != ranges[j].Rink {
+				return ranges[i].Rink < ranges[j].Rink
+			}
+			if ranges[i].Start != ranges[j].Start {
+				return ranges[i].Start < ranges[j].Start
+			}
+			return ranges[i].End < ranges[j].End
+		})
+		writeJSONResponse(w, ranges)
+	case http.MethodDelete:
+		keys := store.keys()
+		if input := r.URL.Query().Get("date"); input != "" {
+			date, _, err := normalizeDate(input)
+			if err != nil {
+				writeBadDate(w, input)
+				return
+			}
+			var matching []string
+			for _, key := range keys {
+				if _, start, end := splitCacheKey(key); start <= date && date <= end {
+					matching = append(matching, key)
+				}
+			}
+			keys = matching
+		}
+		store.remove(keys...)
+		writeJSONResponse(w, map[string]int{"purged": len(keys)})
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to list or DELETE to purge")
+	}
+}
+
+// splitCacheKey undoes the rink + "/" + start + "/" + end cache key
+func splitCacheKey(key string) (string, string, string) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return "", key, key
+	}
+	return parts[0], parts[1], parts[2]
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// alertChannels deliver a text alert to one address (a phone number for sms and whatsapp, an address for email)
+var alertChannels = map[string]func(ctx context.Context, to string, text string) error{
+	"sms":      sendSMS,
+	"whatsapp": sendWhatsApp,
+	"email":    sendEmailAlert,
+}
+
+// alertSubscriber is one entry of ALERT_SUBSCRIBERS, `channel:address`
+type alertSubscriber struct {
+	channel string
+	to      string
+}
+
+// alertSubscribers reads ALERT_SUBSCRIBERS, e.g. sms:+15551234567,whatsapp:+15557654321. Entries for an
+// unknown channel are skipped (and rejected by config validation).
+func alertSubscribers() []alertSubscriber {
+	var subscribers []alertSubscriber
+	for _, entry := range listItems(os.Getenv("ALERT_SUBSCRIBERS")) {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[1] == "" || alertChannels[strings.ToLower(parts[0])] == nil {
+			continue
+		}
+		subscribers = append(subscribers, alertSubscriber{channel: strings.ToLower(parts[0]), to: strings.TrimSpace(parts[1])})
+	}
+	return subscribers
+}
+
+// watchedDate reports whether ALERT_DATES covers the date, by YYYY-MM-DD or weekday name
+func watchedDate(dateObj time.Time) bool {
+	for _, entry := range listItems(os.Getenv("ALERT_DATES")) {
+		if entry == dateObj.Format("2006-01-02") {
+			return true
+		}
+		if weekday, ok := parseWeekday(strings.ToLower(entry)); ok && weekday == dateObj.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// openings are the changes that took a session from sold out (or missing) to open
+func openings(changes []availabilityChange) []availabilityChange {
+	var opened []availabilityChange
+	for _, change := range changes {
+		if change.Previous == 0 && change.Spots > 0 {
+			opened = append(opened, change)
+		}
+	}
+	return opened
+}
+
+// alertText is the message, e.g. "Skating spots opened for Sat Jan 15: 7:00 PM (12 spots),
+// 8:00 PM (3 spots). Book: https://..."
+func alertText(rk rink, date string, opened []availabilityChange) string {
+	dateObj, _ := time.Parse("2006-01-02", date)
+	label := "Skating"
+	if multiRink() {
+		label = rk.label() + " skating"
+	}
+	return label + " spots opened for " + dateObj.Format("Mon Jan 2") + ": " + openedSessions(opened) + ". Book: " + bookingURL(rk, date)
+}
+
+// openedSessions lists the sessions with their spots, "7:00 PM (12 spots), 8:00 PM (3 spots)"
+func openedSessions(opened []availabilityChange) string {
+	var sessions []string
+	for _, change := range opened {
+		timeObj, _ := time.Parse("1504", change.Time)
+		sessions = append(sessions, timeObj.Format("3:04 PM")+" ("+spotsText(change.Spots)+")")
+	}
+	return strings.Join(sessions, ", ")
+}
+
+// alertOpenings tells every subscriber when sessions on a watched date open up. Delivery runs in
+// the background so a slow provider doesn't hold up the fetch that noticed.
+func alertOpenings(rk rink, date string, changes []availabilityChange) {
+	dateObj, err := time.Parse("2006-01-02", date)
+	opened := openings(changes)
+	if err != nil || len(opened) == 0 || !watchedDate(dateObj) {
+		return
+	}
+	subscribers := alertSubscribers()
+	if len(subscribers) == 0 {
+		return
+	}
+	text := alertText(rk, date, opened)
+	goBackground(func() {
+		for _, subscriber := range subscribers {
+			if err := alertChannels[subscriber.channel](context.Background(), subscriber.to, text); err != nil {
+				slog.Warn("alert delivery failed", "channel", subscriber.channel, "date", date, "error", err)
+			}
+		}
+	})
+}
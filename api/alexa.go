@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// alexaTimestampTolerance is how old a request may be, Amazon rejects skills that allow more
+const alexaTimestampTolerance = 150 * time.Second
+
+// alexaCertName is the name Amazon's signing certificate must be issued for
+const alexaCertName = "echo-api.amazon.com"
+
+// alexaRequest is the part of a skill request the handler reads
+type alexaRequest struct {
+	Session struct {
+		Application struct {
+			ApplicationID string `json:"applicationId"`
+		} `json:"application"`
+	} `json:"session"`
+	Context struct {
+		System struct {
+			Application struct {
+				ApplicationID string `json:"applicationId"`
+			} `json:"application"`
+		} `json:"System"`
+	} `json:"context"`
+	Request struct {
+		Type      string `json:"type"`
+		Timestamp string `json:"timestamp"`
+		Intent    struct {
+			Name  string `json:"name"`
+			Slots map[string]struct {
+				Value string `json:"value"`
+			} `json:"slots"`
+		} `json:"intent"`
+	} `json:"request"`
+}
+
+// applicationID is the skill the request is for, it's in context on every request and in session
+// on the ones that have a session
+func (request alexaRequest) applicationID() string {
+	if id := request.Context.System.Application.ApplicationID; id != "" {
+		return id
+	}
+	return request.Session.Application.ApplicationID
+}
+
+// slot is the slot's value, "" when it wasn't filled
+func (request alexaRequest) slot(name string) string {
+	return request.Request.Intent.Slots[name].Value
+}
+
+type alexaResponse struct {
+	Version  string            `json:"version"`
+	Response alexaResponseBody `json:"response"`
+}
+
+type alexaResponseBody struct {
+	OutputSpeech     *alexaSpeech `json:"outputSpeech,omitempty"`
+	Card             *alexaCard   `json:"card,omitempty"`
+	Reprompt         *alexaPrompt `json:"reprompt,omitempty"`
+	ShouldEndSession bool         `json:"shouldEndSession"`
+}
+
+type alexaSpeech struct {
+	Type string `json:"type"`
+	SSML string `json:"ssml"`
+}
+
+type alexaCard struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type alexaPrompt struct {
+	OutputSpeech alexaSpeech `json:"outputSpeech"`
+}
+
+func ssml(text string) alexaSpeech {
+	return alexaSpeech{Type: "SSML", SSML: "<speak>" + html.EscapeString(text) + "</speak>"}
+}
+
+// alexaSay ends the session after saying text
+func alexaSay(text string) alexaResponse {
+	speech := ssml(text)
+	return alexaResponse{Version: "1.0", Response: alexaResponseBody{OutputSpeech: &speech, ShouldEndSession: true}}
+}
+
+// alexaAsk says text and keeps listening, asking again with the same text
+func alexaAsk(text string) alexaResponse {
+	speech := ssml(text)
+	return alexaResponse{Version: "1.0", Response: alexaResponseBody{OutputSpeech: &speech, Reprompt: &alexaPrompt{speech}}}
+}
+
+// validAlexaCertURL checks SignatureCertChainUrl the way Amazon requires: https on
+// s3.amazonaws.com (port 443 if any), under /echo.api/ once the path is normalized
+func validAlexaCertURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "https") || !strings.EqualFold(parsed.Hostname(), "s3.amazonaws.com") {
+		return false
+	}
+	if port := parsed.Port(); port != "" && port != "443" {
+		return false
+	}
+	return strings.HasPrefix(path.Clean(parsed.Path), "/echo.api/")
+}
+
+// alexaCerts caches the verified signing certificate by chain URL, Amazon uses the same one for
+// every request until it rotates
+var alexaCerts = struct {
+	sync.Mutex
+	byURL map[string]*x509.Certificate
+}{byURL: map[string]*x509.Certificate{}}
+
+// alexaSigningCert downloads and verifies the chain: each certificate leads to a trusted root, and
+// the first one is current and issued for echo-api.amazon.com
+func alexaSigningCert(ctx context.Context, chainURL string, now time.Time) (*x509.Certificate, error) {
+	alexaCerts.Lock()
+	cert, ok := alexaCerts.byURL[chainURL]
+	alexaCerts.Unlock()
+	if ok && now.Before(cert.NotAfter) && now.After(cert.NotBefore) {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, chainURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("certificate chain download answered " + strconv.Itoa(resp.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChatBody))
+	if err != nil {
+		return nil, err
+	}
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, parsed)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no certificates in the chain")
+	}
+	intermediates := x509.NewCertPool()
+	for _, parsed := range chain[1:] {
+		intermediates.AddCert(parsed)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{DNSName: alexaCertName, Intermediates: intermediates, CurrentTime: now}); err != nil {
+		return nil, err
+	}
+
+	alexaCerts.Lock()
+	alexaCerts.byURL[chainURL] = chain[0]
+	alexaCerts.Unlock()
+	return chain[0], nil
+}
+
+// validAlexaSignature checks Signature-256, the base64 RSA SHA-256 signature of the body made with
+// the certificate at SignatureCertChainUrl
+func validAlexaSignature(ctx context.Context, r *http.Request, body []byte, now time.Time) bool {
+	chainURL := r.Header.Get("SignatureCertChainUrl")
+	if !validAlexaCertURL(chainURL) {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get("Signature-256"))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	cert, err := alexaSigningCert(ctx, chainURL, now)
+	if err != nil {
+		slog.WarnContext(ctx, "alexa certificate rejected", "url", chainURL, "error", err)
+		return false
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return false
+	}
+	digest := sha256.Sum256(body)
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+}
+
+// alexaHandler is the skill endpoint. Requests must be signed by Amazon, recent and for
+// ALEXA_SKILL_ID; it's a 404 until that's set.
+func alexaHandler(w http.ResponseWriter, r *http.Request) {
+	skillID := os.Getenv("ALEXA_SKILL_ID")
+	if skillID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	now := time.Now()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatBody))
+	if err != nil || !validAlexaSignature(r.Context(), r, body, now) {
+		writeJSONError(w, http.StatusBadRequest, "Bad signature")
+		return
+	}
+	var request alexaRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+	timestamp, err := time.Parse(time.RFC3339, request.Request.Timestamp)
+	if err != nil || now.Sub(timestamp) > alexaTimestampTolerance || timestamp.Sub(now) > alexaTimestampTolerance {
+		writeJSONError(w, http.StatusBadRequest, "Stale request")
+		return
+	}
+	if request.applicationID() != skillID {
+		writeJSONError(w, http.StatusForbidden, "Wrong skill")
+		return
+	}
+	writeJSONResponse(w, alexaReply(r.Context(), request))
+}
+
+// alexaHelp is the launch and help prompt
+const alexaHelp = "You can ask if there's skating, like: is there skating tomorrow night?"
+
+func alexaReply(ctx context.Context, request alexaRequest) alexaResponse {
+	switch request.Request.Type {
+	case "LaunchRequest":
+		return alexaAsk(alexaHelp)
+	case "SessionEndedRequest":
+		// Alexa ignores the response, it only wants a 200
+		return alexaResponse{Version: "1.0"}
+	case "IntentRequest":
+	default:
+		return alexaSay("Sorry, I can't do that.")
+	}
+
+	switch request.Request.Intent.Name {
+	case "SkateTimesIntent":
+	case "AMAZON.HelpIntent", "AMAZON.FallbackIntent":
+		return alexaAsk(alexaHelp)
+	case "AMAZON.StopIntent", "AMAZON.CancelIntent", "AMAZON.NavigateHomeIntent":
+		return alexaSay("Bye.")
+	default:
+		return alexaAsk(alexaHelp)
+	}
+
+	dateObj, ok := alexaDate(request.slot("date"))
+	if !ok {
+		return alexaAsk("Which day? You can say today, tomorrow or a date.")
+	}
+	query := chatQuery{rink: configuredRinks()[defaultRink], date: dateObj.Format("2006-01-02"), dateObj: dateObj}
+	if name := request.slot("rink"); name != "" {
+		rk, ok := configuredRinks()[strings.ToLower(name)]
+		if !ok {
+			return alexaAsk("I don't know the rink " + name + ". Which rink?")
+		}
+		query.rink = rk
+	}
+	skateTimesMap, opts, err := query.lookup(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "alexa lookup failed", "date", query.date, "error", err)
+		return alexaSay("Sorry, I couldn't reach the booking system. Try again in a minute.")
+	}
+	keys, cleanedMap := xola.CleanSkateTimes(query.date, skateTimesMap)
+	keys = opts.filter(keys)
+
+	now := time.Now().In(venueLocation())
+	text := formatVoiceSummary(query.rink, dateObj, keys, cleanedMap, now)
+	if period, inPeriod, ok := alexaTimeFilter(request.slot("time")); ok && len(keys) > 0 {
+		text = alexaPeriodSummary(query.rink.label(), spokenDay(dateObj, now)+" "+period, filterKeys(keys, inPeriod), cleanedMap)
+	}
+	response := alexaSay(text)
+	response.Response.Card = &alexaCard{
+		Type:    "Simple",
+		Title:   query.rink.label() + " skating",
+		Content: getFormattedTimes(query.date, dateObj, skateTimesMap, opts).String() + "\nBook: " + bookingURL(query.rink, query.date),
+	}
+	return response
+}
+
+// alexaDate reads an AMAZON.DATE value. Days come as YYYY-MM-DD, "this weekend" as the ISO week
+// with -WE, which means its Saturday. Empty means today; weeks, months and the like aren't a day.
+func alexaDate(value string) (time.Time, bool) {
+	if value == "" || value == "PRESENT_REF" {
+		_, today, _ := normalizeDate("")
+		return today, true
+	}
+	if dateObj, err := time.Parse("2006-01-02", value); err == nil {
+		return dateObj, true
+	}
+	var year, week int
+	if len(value) == 11 && strings.HasSuffix(value, "-WE") && value[4:6] == "-W" {
+		year, _ = strconv.Atoi(value[:4])
+		week, _ = strconv.Atoi(value[6:8])
+	}
+	if year == 0 || week == 0 {
+		return time.Time{}, false
+	}
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+	return monday.AddDate(0, 0, 5), true
+}
+
+// alexaTimeFilter reads an AMAZON.TIME value: MO, AF, EV or NI for a part of the day, or HH:MM for
+// sessions from then on. period is how it's said back, e.g. "night" or "after 7 PM".
+func alexaTimeFilter(value string) (period string, inPeriod func(skateTime string) bool, ok bool) {
+	parts := map[string]struct{ period, daypart string }{
+		"MO": {"morning", "Morning"},
+		"AF": {"afternoon", "Afternoon"},
+		"EV": {"evening", "Evening"},
+		"NI": {"night", "Evening"},
+	}
+	if part, ok := parts[value]; ok {
+		return part.period, func(skateTime string) bool { return daypart(skateTime) == part.daypart }, true
+	}
+	from, ok := xola.NormalizeSlotTime(value)
+	if !ok {
+		return "", nil, false
+	}
+	return "after " + spokenTime(from), func(skateTime string) bool { return skateTime >= from }, true
+}
+
+func filterKeys(keys []string, keep func(string) bool) []string {
+	var kept []string
+	for _, key := range keys {
+		if keep(key) {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
+
+// alexaPeriodSummary is formatVoiceSummary for part of a day that has sessions, e.g. "Bryant Park has
+// 12 spots tomorrow night, in one session at 8 PM."
+func alexaPeriodSummary(venue string, when string, keys []string, cleanedMap map[string]int) string {
+	if len(keys) == 0 {
+		return "There's no open skating at " + venue + " " + when + "."
+	}
+	total := 0
+	for _, skateTime := range keys {
+		total += cleanedMap[skateTime]
+	}
+	spots := pluralize(total, "spot")
+	if isLimited(total) {
+		spots = "limited spots"
+	}
+	if len(keys) == 1 {
+		return venue + " has " + spots + " " + when + ", in one session at " + spokenTime(keys[0]) + "."
+	}
+	return venue + " has " + spots + " across " + strconv.Itoa(len(keys)) + " sessions " + when + ", with the earliest at " + spokenTime(keys[0]) + "."
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// parseCIDRs reads a comma-separated list of CIDRs or bare IPs from env, skipping bad entries
+func parseCIDRs(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range listItems(os.Getenv(key)) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			slog.Warn("ignoring bad entry", "key", key, "entry", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is only believed when the
+// connection comes from one of the TRUSTED_PROXIES, and then it's read right to left, skipping
+// the trusted proxies, so a client can't just prepend an address of its choosing.
+// TRUSTED_PROXIES=* trusts whatever connects to the handler and believes only the last hop, the
+// address it saw, which is right on Vercel where the edge is the only proxy. Trusting every hop
+// there would end at the leftmost one, which the client writes.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	trustAll := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")) == "*"
+	var trusted []*net.IPNet
+	if !trustAll {
+		trusted = parseCIDRs("TRUSTED_PROXIES")
+	}
+	if ip != nil && !trustAll && !containsIP(trusted, ip) {
+		return ip
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if trustAll || !containsIP(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// allowedIP enforces ALLOWED_IPS, a comma-separated list of CIDRs (or single addresses). Every
+// address is allowed when it's unset.
+func allowedIP(r *http.Request) bool {
+	allowed := parseCIDRs("ALLOWED_IPS")
+	if len(allowed) == 0 {
+		return true
+	}
+	ip := clientIP(r)
+	return ip != nil && containsIP(allowed, ip)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   string
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"no proxies trusted ignores the header", "", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"untrusted peer ignores the header", "10.0.0.0/8", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"trusted peer uses the last hop", "10.0.0.0/8", "10.0.0.2:1234", "198.51.100.1", "198.51.100.1"},
+		{"trusted hops are skipped", "10.0.0.0/8", "10.0.0.2:1234", "198.51.100.1, 10.0.0.5", "198.51.100.1"},
+		{"a prepended address isn't believed", "10.0.0.0/8", "10.0.0.2:1234", "192.0.2.99, 198.51.100.1", "198.51.100.1"},
+		{"star takes the last hop", "*", "10.0.0.2:1234", "192.0.2.99, 198.51.100.1", "198.51.100.1"},
+		{"star without a header uses the peer", "*", "10.0.0.2:1234", "", "10.0.0.2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", test.trusted)
+			r := httptest.NewRequest("GET", "/api", nil)
+			r.RemoteAddr = test.remote
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			if got := clientIP(r); got.String() != test.want {
+				t.Errorf("clientIP() = %v, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAllowedIPBehindVercel(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "*")
+	t.Setenv("ALLOWED_IPS", "192.0.2.0/24")
+	r := httptest.NewRequest("GET", "/api", nil)
+	r.Header.Set("X-Forwarded-For", "192.0.2.99, 198.51.100.1")
+	if allowedIP(r) {
+		t.Error("a spoofed leftmost X-Forwarded-For hop got through the allowlist")
+	}
+	r.Header.Set("X-Forwarded-For", "192.0.2.99")
+	if !allowedIP(r) {
+		t.Error("an allowed client was refused")
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// audit defaults: how many entries /admin/audit returns, at most, and how many each instance
+// keeps in memory
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	auditMemorySize   = 1000
+)
+
+// auditEntry is one authenticated (or rejected) request, appended as a JSON line to AUDIT_LOG
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Key       string    `json:"key,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Date      string    `json:"date,omitempty"`
+	Status    int       `json:"status"`
+	RequestID string    `json:"requestId,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+}
+
+// requestRecord collects what the access log and audit trail need to know about a request as
+// the handler learns it. Set on the context by instrumentRequest.
+type requestRecord struct {
+	sync.Mutex
+	key     string
+	audited bool
+}
+
+type requestRecordContextKey struct{}
+
+// noteRequestKey marks the request for the audit trail, with the key it authenticated as ("" when
+// it was rejected or auth is off)
+func noteRequestKey(ctx context.Context, name string) {
+	rec, ok := ctx.Value(requestRecordContextKey{}).(*requestRecord)
+	if !ok {
+		return
+	}
+	rec.Lock()
+	rec.key, rec.audited = name, true
+	rec.Unlock()
+}
+
+// recentAudit is the in-memory tail of the audit trail, what /admin/audit answers from when
+// AUDIT_LOG isn't a file
+var recentAudit struct {
+	sync.Mutex
+	entries []auditEntry
+}
+
+// auditLogLock serializes appends to the AUDIT_LOG file
+var auditLogLock sync.Mutex
+
+// recordAudit writes the entry to AUDIT_LOG ("stdout" for JSON lines on stdout, otherwise a file
+// path) and the in-memory tail. It's a no-op when AUDIT_LOG is unset.
+func recordAudit(entry auditEntry) {
+	sink := os.Getenv("AUDIT_LOG")
+	if sink == "" {
+		return
+	}
+	recentAudit.Lock()
+	recentAudit.entries = append(recentAudit.entries, entry)
+	if len(recentAudit.entries) > auditMemorySize {
+		recentAudit.entries = recentAudit.entries[len(recentAudit.entries)-auditMemorySize:]
+	}
+	recentAudit.Unlock()
+
+	line, _ := json.Marshal(entry)
+	line = append(line, '\n')
+	auditLogLock.Lock()
+	defer auditLogLock.Unlock()
+	if sink == "stdout" {
+		os.Stdout.Write(line)
+		return
+	}
+	file, err := os.OpenFile(sink, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Warn("could not open audit log", "error", err)
+		return
+	}
+	defer file.Close()
+	file.Write(line)
+}
+
+// auditRequest builds the entry for a finished request
+func auditRequest(r *http.Request, rec *requestRecord, status int) {
+	rec.Lock()
+	key, audited := rec.key, rec.audited
+	rec.Unlock()
+	if !audited {
+		return
+	}
+	entry := auditEntry{Time: time.Now().UTC(), Key: key, Method: r.Method, Path: r.URL.Path, Status: status, RequestID: requestID(r.Context())}
+	if ip := clientIP(r); ip != nil {
+		entry.IP = ip.String()
+	}
+	if input := requestedDate(r); input != "" {
+		entry.Date = input
+		if date, _, err := normalizeDate(input); err == nil {
+			entry.Date = date
+		}
+	}
+	recordAudit(entry)
+}
+
+// requestedDate is the raw ?date= (or ?start=) the request asked for
+func requestedDate(r *http.Request) string {
+	if date := r.URL.Query().Get("date"); date != "" {
+		return date
+	}
+	return r.URL.Query().Get("start")
+}
+
+// loadAudit reads the audit trail, from the file when AUDIT_LOG is one so it survives restarts,
+// otherwise from this instance's memory. Oldest first.
+func loadAudit() []auditEntry {
+	sink := os.Getenv("AUDIT_LOG")
+	if sink == "" || sink == "stdout" {
+		recentAudit.Lock()
+		defer recentAudit.Unlock()
+		return append([]auditEntry(nil), recentAudit.entries...)
+	}
+
+	var entries []auditEntry
+	file, err := os.Open(sink)
+	if err != nil {
+		// no log yet just means nothing was audited
+		return entries
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("skipping bad audit log line")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// adminAuditHandler lists the most recent audit entries, newest first, optionally only ?key=
+// and only ?since= (RFC 3339 or a date), up to ?limit=
+func adminAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET")
+		return
+	}
+	query := r.URL.Query()
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive whole number")
+			return
+		}
+		limit = number
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			_, dateObj, dateErr := normalizeDate(value)
+			if dateErr != nil {
+				writeBadDate(w, value)
+				return
+			}
+			since = dateObj
+		}
+	}
+
+	entries := loadAudit()
+	result := []auditEntry{}
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		entry := entries[i]
+		if key := query.Get("key"); key != "" && entry.Key != key {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	writeJSONResponse(w, result)
+}
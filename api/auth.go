@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultAuthHeader is the header the token has always been read from
+const defaultAuthHeader = "token"
+
+// bearerScheme is stripped from the header value when AUTH_HEADER is Authorization
+const bearerScheme = "Bearer "
+
+// defaultKeyName is what AUTH_TOKEN is called among the API keys
+const defaultKeyName = "default"
+
+// apiKeys are the named keys that may call the API: each `name=key` pair in API_KEYS, plus
+// AUTH_TOKEN as "default". Auth is disabled when there are none.
+func apiKeys() map[string]string {
+	keys := map[string]string{}
+	if token := os.Getenv("AUTH_TOKEN"); token != "" {
+		keys[defaultKeyName] = token
+	}
+	for _, entry := range listItems(os.Getenv("API_KEYS")) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			keys[parts[0]] = parts[1]
+		}
+	}
+	return keys
+}
+
+// authorized checks the request the way AUTH_MODE asks, and puts the name of the key it
+// authenticated with on the request context:
+//   - "key" (default): one of the API keys (env or /admin/keys) in the auth header. Auth is disabled
+//     when there are none.
+//   - "hmac": a request signature made with REQUEST_SIGNING_SECRET, see signing.go
+//   - "jwt": a bearer JWT, named after its subject, see jwt.go
+func authorized(r *http.Request) (*http.Request, bool) {
+	switch strings.ToLower(os.Getenv("AUTH_MODE")) {
+	case "hmac":
+		if !validSignature(r) {
+			return r, false
+		}
+		return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, signedKeyName)), true
+	case "jwt":
+		subject, err := validJWT(r, time.Now())
+		if err != nil {
+			slog.WarnContext(r.Context(), "rejected JWT", "error", err)
+			return r, false
+		}
+		return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, "jwt:"+subject)), true
+	}
+
+	keys := apiKeys()
+	stored, err := managedKeys().all()
+	if err != nil {
+		// without the managed keys there's no telling whether auth is on, so nobody gets in
+		slog.WarnContext(r.Context(), "refusing requests while the managed keys can't be read", "error", err)
+		return r, false
+	}
+	if len(keys) == 0 && len(stored) == 0 {
+		return r, true
+	}
+	token := requestToken(r)
+	name, ok := matchKey(token, keys)
+	if !ok {
+		name, ok = matchStoredKey(token, stored)
+	}
+	if !ok {
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, name)), true
+}
+
+// authEnabled reports whether requests need credentials, in any AUTH_MODE. It's true when the
+// managed keys can't be read too, authorized lets nobody in then.
+func authEnabled() bool {
+	switch strings.ToLower(os.Getenv("AUTH_MODE")) {
+	case "hmac", "jwt":
+		return true
+	}
+	if len(apiKeys()) > 0 {
+		return true
+	}
+	stored, err := managedKeys().all()
+	return err != nil || len(stored) > 0
+}
+
+// matchKey finds the key the token belongs to. Every key is compared in constant time, in a fixed
+// order, so the response time doesn't tell how close a guess was or which key it was close to.
+func matchKey(token string, keys map[string]string) (string, bool) {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matched := ""
+	for _, name := range names {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(keys[name])) == 1 && matched == "" {
+			matched = name
+		}
+	}
+	return matched, matched != "" && token != ""
+}
+
+type apiKeyContextKey struct{}
+
+// apiKeyName is the name of the key the request authenticated with, "" when auth is disabled
+func apiKeyName(ctx context.Context) string {
+	name, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return name
+}
+
+// withFeedToken moves ?token= into the auth header, for the feed routes calendar and feed readers
+// subscribe to. A header that's already there wins.
+func withFeedToken(r *http.Request) *http.Request {
+	token := r.URL.Query().Get("token")
+	if token == "" || requestToken(r) != "" {
+		return r
+	}
+	header := os.Getenv("AUTH_HEADER")
+	if header == "" {
+		header = defaultAuthHeader
+	}
+	if strings.EqualFold(header, "Authorization") {
+		token = bearerScheme + token
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(header, token)
+	return r
+}
+
+// requestToken reads the token from the configured header (env AUTH_HEADER, default "token")
+func requestToken(r *http.Request) string {
+	header := os.Getenv("AUTH_HEADER")
+	if header == "" {
+		header = defaultAuthHeader
+	}
+	value := r.Header.Get(header)
+
+	// Authorization header is treated as "Authorization: Bearer <token>"
+	if strings.EqualFold(header, "Authorization") {
+		if len(value) < len(bearerScheme) || !strings.EqualFold(value[:len(bearerScheme)], bearerScheme) {
+			return ""
+		}
+		value = value[len(bearerScheme):]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuthHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		send   []string
+		want   int
+	}{
+		{"default header", "", []string{"token", "secret"}, http.StatusOK},
+		{"default header, wrong key", "", []string{"token", "nope"}, http.StatusForbidden},
+		{"no key", "", nil, http.StatusForbidden},
+		{"custom header", "X-Skate-Key", []string{"X-Skate-Key", "secret"}, http.StatusOK},
+		{"custom header ignores token", "X-Skate-Key", []string{"token", "secret"}, http.StatusForbidden},
+		{"bearer", "Authorization", []string{"Authorization", "Bearer secret"}, http.StatusOK},
+		{"bearer scheme is case-insensitive", "Authorization", []string{"Authorization", "bearer secret"}, http.StatusOK},
+		{"bearer without the scheme", "Authorization", []string{"Authorization", "secret"}, http.StatusForbidden},
+		{"bearer, wrong key", "Authorization", []string{"Authorization", "Bearer nope"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+			t.Setenv("AUTH_TOKEN", "secret")
+			t.Setenv("AUTH_HEADER", test.header)
+			w := get(t, "/api?date=2024-01-02", test.send...)
+			if w.Code != test.want {
+				t.Errorf("status = %d, want %d", w.Code, test.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysAreNamed(t *testing.T) {
+	resetAvailability(t)
+	t.Setenv("AUTH_TOKEN", "secret")
+	t.Setenv("API_KEYS", "bot=bot-key, broken, kiosk=kiosk-key")
+	keys := apiKeys()
+	want := map[string]string{defaultKeyName: "secret", "bot": "bot-key", "kiosk": "kiosk-key"}
+	if len(keys) != len(want) {
+		t.Fatalf("apiKeys() = %v, want %v", keys, want)
+	}
+	for name, key := range want {
+		if got, ok := matchKey(key, keys); !ok || got != name {
+			t.Errorf("matchKey(%q) = %q, %v, want %q", key, got, ok, name)
+		}
+	}
+	if _, ok := matchKey("", keys); ok {
+		t.Error("an empty token matched a key")
+	}
+}
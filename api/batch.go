@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// maxBatchDates caps how many dates (and so concurrent Xola requests) one batch can ask for
+const maxBatchDates = 31
+
+// batchRequest is the JSON body form of a batch, e.g. {"dates": ["tuesday", "thursday"]}
+type batchRequest struct {
+	Dates []string `json:"dates"`
+}
+
+// availabilityBatch is the structured batch response, one entry per requested date in request order
+type availabilityBatch struct {
+	Dates []availability `json:"dates"`
+}
+
+// batchDates reads the dates from ?dates=a,b,c or a JSON body
+func batchDates(r *http.Request) []string {
+	if param := r.URL.Query().Get("dates"); param != "" {
+		return strings.Split(param, ",")
+	}
+	var body batchRequest
+	if r.Body != nil {
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}
+	return body.Dates
+}
+
+// batchHandler looks up several specific dates at once, fetching them from Xola concurrently
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	inputs := batchDates(r)
+	if len(inputs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("No dates given, pass ?dates=2024-01-16,2024-01-18 or a JSON body {\"dates\": [...]}"))
+		return
+	}
+	if len(inputs) > maxBatchDates {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("A batch is limited to " + strconv.Itoa(maxBatchDates) + " dates"))
+		return
+	}
+	dates := make([]string, len(inputs))
+	dateObjs := make([]time.Time, len(inputs))
+	for i, input := range inputs {
+		date, dateObj, err := normalizeDate(input)
+		if err != nil {
+			writeBadDate(w, input)
+			return
+		}
+		dates[i], dateObjs[i] = date, dateObj
+	}
+	format, ok := endpointFormat(w, r, "text", "json", "yaml", "voice")
+	if !ok {
+		return
+	}
+
+	// fan out, each goroutine only writes its own index
+	skateTimesMaps := make([]map[string]map[string]int, len(dates))
+	waitlists := make([]map[string]map[string]bool, len(dates))
+	errs := make([]error, len(dates))
+	var wg sync.WaitGroup
+	for i := range dates {
+		if isClosedDay(rinkFromContext(r.Context()), dateObjs[i]) {
+			skateTimesMaps[i] = map[string]map[string]int{}
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			skateTimesMaps[i], waitlists[i], errs[i] = querySkateTimesAPI(r.Context(), dates[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+	}
+
+	opts := formatOptionsFromRequest(r)
+	if pricingEnabled() {
+		// only worth a lookup when there's a session to price
+		for i, date := range dates {
+			if len(skateTimesMaps[i][date]) > 0 {
+				opts.price = queryExperiencePrice(r.Context())
+				break
+			}
+		}
+	}
+
+	switch format {
+	case "json", "yaml":
+		result := availabilityBatch{Dates: make([]availability, 0, len(dates))}
+		for i, date := range dates {
+			dayOpts := opts
+			dayOpts.waitlists = waitlists[i][date]
+			result.Dates = append(result.Dates, buildAvailability(date, skateTimesMaps[i], dayOpts))
+		}
+		if format == "yaml" {
+			writeYAMLResponse(w, result)
+			return
+		}
+		writeJSONResponse(w, result)
+	case "voice":
+		var sentences []string
+		now := time.Now().In(venueLocation())
+		for i, date := range dates {
+			keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMaps[i])
+			sentences = append(sentences, formatVoiceSummary(opts.rink, dateObjs[i], opts.filter(keys), cleanedMap, now))
+		}
+		var sb strings.Builder
+		sb.WriteString(strings.Join(sentences, " ") + "\n")
+		writeSuccessResponse(w, &sb)
+	default:
+		var sb strings.Builder
+		for i, date := range dates {
+			dayOpts := opts
+			dayOpts.waitlists = waitlists[i][date]
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(getFormattedTimes(date, dateObjs[i], skateTimesMaps[i], dayOpts).String())
+		}
+		writeSuccessResponse(w, &sb)
+	}
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultBookingURL is the Xola checkout page for an experience, {date} is replaced with YYYY-MM-DD
+const defaultBookingURL = "https://checkout.xola.com/index.html#experience/{experience}?date={date}"
+
+// bookingURL builds the booking page link for a date from BOOKING_URL (falls back to the Xola checkout
+// for the rink's experience)
+func bookingURL(rk rink, date string) string {
+	template := os.Getenv("BOOKING_URL")
+	if template == "" || rk.name != defaultRink {
+		template = strings.Replace(defaultBookingURL, "{experience}", rk.experienceID, 1)
+	}
+	return strings.Replace(template, "{date}", date, -1)
+}
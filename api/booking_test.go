@@ -0,0 +1,26 @@
+package handler
+
+import "testing"
+
+func TestBookingURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		rink     string
+		want     string
+	}{
+		{"Xola checkout", "", "bp", "https://checkout.xola.com/index.html#experience/test-experience?date=2024-01-02"},
+		{"BOOKING_URL", "https://example.com/book?day={date}&again={date}", "bp", "https://example.com/book?day=2024-01-02&again=2024-01-02"},
+		{"other rinks use their own experience", "https://example.com/book?day={date}", "wollman", "https://checkout.xola.com/index.html#experience/wollman-experience?date=2024-01-02"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetAvailability(t)
+			t.Setenv("BOOKING_URL", test.template)
+			t.Setenv("RINKS", "Wollman=wollman-experience")
+			if got := bookingURL(configuredRinks()[test.rink], "2024-01-02"); got != test.want {
+				t.Errorf("bookingURL() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// circuit breaker defaults, see CIRCUIT_FAILURES and CIRCUIT_COOLDOWN_MS
+const (
+	defaultCircuitFailures   = 5
+	defaultCircuitCooldownMs = 30000
+)
+
+// errCircuitOpen is returned instead of calling Xola while the breaker is open
+var errCircuitOpen = errors.New("Xola circuit breaker is open")
+
+// circuitBreaker trips after CIRCUIT_FAILURES consecutive failed lookups. While open every call
+// fails fast; after CIRCUIT_COOLDOWN_MS one probe request is let through (half-open) and its
+// result either closes the breaker again or restarts the cooldown.
+type circuitBreaker struct {
+	sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+
+	// the last lookup that actually reached Xola, for /healthz
+	lastCall    time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+// xolaBreaker guards every availability request to Xola
+var xolaBreaker = &circuitBreaker{}
+
+func circuitCooldown() time.Duration {
+	return envMilliseconds("CIRCUIT_COOLDOWN_MS", defaultCircuitCooldownMs)
+}
+
+// allow reports whether a request may go to Xola now
+func (b *circuitBreaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < circuitCooldown() {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record feeds the result of an allowed request back into the breaker
+func (b *circuitBreaker) record(err error) {
+	b.Lock()
+	defer b.Unlock()
+	if errors.Is(err, context.Canceled) || errors.Is(err, errXolaBudget) {
+		// the client hung up or we held back ourselves, that says nothing about Xola
+		b.probing = false
+		return
+	}
+	b.lastCall = time.Now()
+	if err == nil {
+		b.lastSuccess, b.lastError = b.lastCall, ""
+		if b.open {
+			slog.Info("Xola recovered, closing circuit breaker")
+		}
+		b.failures, b.open, b.probing = 0, false, false
+		return
+	}
+	b.lastError = err.Error()
+	b.failures++
+	if b.probing || b.failures >= envInt("CIRCUIT_FAILURES", defaultCircuitFailures) {
+		if !b.open {
+			slog.Warn("Xola keeps failing, opening circuit breaker", "failures", b.failures)
+		}
+		b.open, b.openedAt, b.probing = true, time.Now(), false
+	}
+}
+
+// state is "closed", "open", or "half-open" once the cooldown has passed and the next call will probe
+func (b *circuitBreaker) state() string {
+	b.Lock()
+	defer b.Unlock()
+	switch {
+	case !b.open:
+		return "closed"
+	case b.probing || time.Since(b.openedAt) >= circuitCooldown():
+		return "half-open"
+	}
+	return "open"
+}
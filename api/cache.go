@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTLSeconds is how long fetched availability is reused when CACHE_TTL_SECONDS is unset
+const defaultCacheTTLSeconds = 60
+
+// cacheEntry is one fetched start/end range and when it came from Xola
+type cacheEntry struct {
+	skateTimesMap map[string]map[string]int
+	waitlists     map[string]map[string]bool
+	fetchedAt     time.Time
+}
+
+// availabilityStore is where fetched availability is kept between requests. Entries are reused for
+// CACHE_TTL_SECONDS and kept for a while afterwards too, so the circuit breaker has something to
+// serve while Xola is down.
+type availabilityStore interface {
+	get(key string) (cacheEntry, bool)
+	set(key string, entry cacheEntry)
+	keys() []string
+	remove(keys ...string)
+}
+
+// cacheStore picks the backend: the shared KV store when one is configured (see kv.go), otherwise
+// this instance's memory. Serverless instances come and go, so only the KV store survives between them.
+func cacheStore() availabilityStore {
+	if store, ok := kvStoreFromEnv(); ok {
+		return store
+	}
+	return localCache
+}
+
+// memoryStore keeps entries in this instance only
+type memoryStore struct {
+	sync.Mutex
+	byRange map[string]cacheEntry
+}
+
+var localCache = &memoryStore{byRange: map[string]cacheEntry{}}
+
+func (store *memoryStore) get(key string) (cacheEntry, bool) {
+	store.Lock()
+	defer store.Unlock()
+	entry, ok := store.byRange[key]
+	return entry, ok
+}
+
+func (store *memoryStore) set(key string, entry cacheEntry) {
+	store.Lock()
+	defer store.Unlock()
+	store.byRange[key] = entry
+}
+
+func (store *memoryStore) keys() []string {
+	store.Lock()
+	defer store.Unlock()
+	keys := make([]string, 0, len(store.byRange))
+	for key := range store.byRange {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (store *memoryStore) remove(keys ...string) {
+	store.Lock()
+	defer store.Unlock()
+	for _, key := range keys {
+		delete(store.byRange, key)
+	}
+}
+
+func cacheTTL() time.Duration {
+	return time.Duration(envInt("CACHE_TTL_SECONDS", defaultCacheTTLSeconds)) * time.Second
+}
+
+func storeAvailability(key string, skateTimesMap map[string]map[string]int, waitlists map[string]map[string]bool) {
+	cacheStore().set(key, cacheEntry{skateTimesMap: skateTimesMap, waitlists: waitlists, fetchedAt: time.Now()})
+}
+
+// cachedAvailability returns the last stored entry for the range, however old it is
+func cachedAvailability(key string) (cacheEntry, bool) {
+	return cacheStore().get(key)
+}
+
+// fresh reports whether the entry is still within CACHE_TTL_SECONDS
+func (entry cacheEntry) fresh() bool {
+	return time.Since(entry.fetchedAt) < cacheTTL()
+}
+
+// revalidatable reports whether an expired entry may still be served while it's refreshed in the
+// background, for up to CACHE_STALE_SECONDS past the TTL (off by default)
+func (entry cacheEntry) revalidatable() bool {
+	stale := time.Duration(envInt("CACHE_STALE_SECONDS", 0)) * time.Second
+	return time.Since(entry.fetchedAt) < cacheTTL()+stale
+}
+
+// dataAge tracks the oldest availability a request was answered with
+type dataAge struct {
+	sync.Mutex
+	oldest time.Time
+}
+
+type dataAgeKey struct{}
+
+func withDataAge(ctx context.Context) (context.Context, *dataAge) {
+	age := &dataAge{}
+	return context.WithValue(ctx, dataAgeKey{}, age), age
+}
+
+// noteFetchedAt records when the availability used for this request came from Xola
+func noteFetchedAt(ctx context.Context, fetchedAt time.Time) {
+	age, ok := ctx.Value(dataAgeKey{}).(*dataAge)
+	if !ok {
+		return
+	}
+	age.Lock()
+	defer age.Unlock()
+	if age.oldest.IsZero() || fetchedAt.Before(age.oldest) {
+		age.oldest = fetchedAt
+	}
+}
+
+// ageWriter adds the standard Age header (seconds since the data was fetched from Xola) to
+// responses built from availability, so clients can tell how fresh the numbers are
+type ageWriter struct {
+	http.ResponseWriter
+	age         *dataAge
+	wroteHeader bool
+}
+
+func (w *ageWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.age.Lock()
+		oldest := w.age.oldest
+		w.age.Unlock()
+		if !oldest.IsZero() {
+			w.Header().Set("Age", strconv.Itoa(int(time.Since(oldest).Seconds())))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ageWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// setCacheControl adds Cache-Control from CACHE_MAX_AGE (browsers and clients) and CACHE_S_MAXAGE
+// (the Vercel CDN). With auth enabled (see authEnabled) responses are private, the CDN would
+// otherwise hand them to anyone. ?fresh=true responses aren't cached at all.
+func setCacheControl(w http.ResponseWriter, r *http.Request) {
+	maxAge, sharedMaxAge := os.Getenv("CACHE_MAX_AGE"), os.Getenv("CACHE_S_MAXAGE")
+	if maxAge == "" && sharedMaxAge == "" {
+		return
+	}
+	if freshDataRequested(r.Context()) {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+	directives := []string{"public"}
+	if authEnabled() {
+		directives = []string{"private"}
+		sharedMaxAge = ""
+	}
+	if maxAge != "" {
+		directives = append(directives, "max-age="+strconv.Itoa(envInt("CACHE_MAX_AGE", 0)))
+	}
+	if sharedMaxAge != "" {
+		directives = append(directives, "s-maxage="+strconv.Itoa(envInt("CACHE_S_MAXAGE", 0)))
+	}
+	w.Header().Set("Cache-Control", strings.Join(directives, ", "))
+	// the Shortcut sends the date as a header, not in the URL
+	w.Header().Add("Vary", "startDate, endDate")
+}
+
+type freshKey struct{}
+
+// withFreshData marks the request (?fresh=true) as wanting to skip the cache and go to Xola
+func withFreshData(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+func freshDataRequested(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	return fresh
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheControlWithAuth(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		want  string
+	}{
+		{"no auth", func(t *testing.T) {}, "public, max-age=30, s-maxage=300"},
+		{"AUTH_TOKEN", func(t *testing.T) { t.Setenv("AUTH_TOKEN", "secret") }, "private, max-age=30"},
+		{"API_KEYS", func(t *testing.T) { t.Setenv("API_KEYS", "bot=bot-key") }, "private, max-age=30"},
+		{"managed key", func(t *testing.T) {
+			localKeys.put(storedKey{Name: "mom", Hash: hashKey("mom-key")})
+			t.Cleanup(func() { localKeys.remove("mom") })
+		}, "private, max-age=30"},
+		{"hmac", func(t *testing.T) {
+			t.Setenv("AUTH_MODE", "hmac")
+			t.Setenv("REQUEST_SIGNING_SECRET", "shh")
+		}, "private, max-age=30"},
+		{"jwt", func(t *testing.T) {
+			t.Setenv("AUTH_MODE", "JWT")
+			t.Setenv("JWT_SECRET", "shh")
+		}, "private, max-age=30"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetAvailability(t)
+			t.Setenv("CACHE_MAX_AGE", "30")
+			t.Setenv("CACHE_S_MAXAGE", "300")
+			test.setup(t)
+			w := httptest.NewRecorder()
+			setCacheControl(w, httptest.NewRequest(http.MethodGet, "/api?date=2024-01-02", nil))
+			if got := w.Header().Get("Cache-Control"); got != test.want {
+				t.Errorf("Cache-Control = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlInResponses(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	t.Setenv("CACHE_MAX_AGE", "30")
+	t.Setenv("CACHE_S_MAXAGE", "300")
+	t.Setenv("API_KEYS", "bot=bot-key")
+	w := get(t, "/api?date=2024-01-02", "token", "bot-key")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "private, max-age=30" {
+		t.Errorf("status %d, Cache-Control %q, want a private response for an API key", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
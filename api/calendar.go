@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultFeedDays is how far ahead a feed looks when the request names no dates, so a calendar
+// subscription keeps rolling forward
+const defaultFeedDays = 14
+
+// feedSession is one session in a feed, with its start and end in the venue's time zone
+type feedSession struct {
+	rink  rink
+	date  string
+	start time.Time
+	end   time.Time
+	spots int
+}
+
+// summary is the one-line description of the session, e.g. "Skating: 12 spots left"
+func (session feedSession) summary() string {
+	label := "Skating"
+	if multiRink() {
+		label = session.rink.label() + " skating"
+	}
+	if session.spots == 0 {
+		return label + ": sold out"
+	}
+	return label + ": " + spotsText(session.spots) + " left"
+}
+
+// feedDates are the days a feed covers: ?date= (or ?start=) through ?end=, just the one day without
+// an end, or the next defaultFeedDays from today without either
+func feedDates(r *http.Request) ([]time.Time, string) {
+	query := r.URL.Query()
+	if query.Get("date") == "" && query.Get("start") == "" && r.Header.Get("startDate") == "" {
+		_, today, _ := normalizeDate("")
+		return rangeDates(today, today.AddDate(0, 0, defaultFeedDays-1)), ""
+	}
+	date, startObj, err := requestDate(r)
+	if err != nil {
+		return nil, "Could not understand the date \"" + date + "\""
+	}
+	endObj := startObj
+	if input := requestEndDate(r); input != "" {
+		if _, endObj, err = normalizeDate(input); err != nil || endObj.Before(startObj) {
+			return nil, "Bad date range, expected a start date on or before the end date"
+		}
+	}
+	dates := rangeDates(startObj, endObj)
+	if len(dates) > maxRangeDays {
+		return nil, "Date range is limited to " + strconv.Itoa(maxRangeDays) + " days"
+	}
+	return dates, ""
+}
+
+// feedSessions looks up the feed's days and lists their sessions in order, filtered the way the
+// request asked (?accessibleOnly=1, ?surface=, ?includeSoldOut=1)
+func feedSessions(w http.ResponseWriter, r *http.Request) ([]feedSession, bool) {
+	dates, problem := feedDates(r)
+	if problem != "" {
+		writeJSONError(w, http.StatusBadRequest, problem)
+		return nil, false
+	}
+	skateTimesMap, _, err := queryOpenDays(r.Context(), dates)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return nil, false
+	}
+
+	opts := formatOptionsFromRequest(r)
+	var sessions []feedSession
+	for _, day := range dates {
+		sessions = append(sessions, daySessions(day, skateTimesMap, opts)...)
+	}
+	return sessions, true
+}
+
+// daySessions lists one day's sessions in order, filtered the way opts asks
+func daySessions(day time.Time, skateTimesMap map[string]map[string]int, opts formatOptions) []feedSession {
+	sessionLength := envMinutes("SESSION_MINUTES", defaultSessionMinutes)
+	date := day.Format("2006-01-02")
+	keys, cleanedMap := opts.skateTimes(date, skateTimesMap)
+	var sessions []feedSession
+	for _, skateTime := range opts.filter(keys) {
+		timeObj, _ := time.Parse("1504", skateTime)
+		start := time.Date(day.Year(), day.Month(), day.Day(), timeObj.Hour(), timeObj.Minute(), 0, 0, venueLocation())
+		sessions = append(sessions, feedSession{rink: opts.rink, date: date, start: start, end: start.Add(sessionLength), spots: cleanedMap[skateTime]})
+	}
+	return sessions
+}
+
+// icsHandler serves the sessions as an iCalendar feed, one VEVENT per session, for subscribing
+// from Apple or Google Calendar
+func icsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, ok := feedSessions(w, r)
+	if !ok {
+		return
+	}
+	writeICSResponse(w, r, sessions)
+}
+
+// writeICSResponse is the calendar for the sessions, also served as ?format=ics
+func writeICSResponse(w http.ResponseWriter, r *http.Request, sessions []feedSession) {
+	rk := rinkFromContext(r.Context())
+	now := time.Now().UTC()
+
+	var sb strings.Builder
+	writeICSLine(&sb, "BEGIN:VCALENDAR")
+	writeICSLine(&sb, "VERSION:2.0")
+	writeICSLine(&sb, "PRODID:-//bp-skate//Skate times//EN")
+	writeICSLine(&sb, "CALSCALE:GREGORIAN")
+	writeICSLine(&sb, "METHOD:PUBLISH")
+	writeICSLine(&sb, "X-WR-CALNAME:"+icsText(rk.label()+" skating"))
+	writeICSLine(&sb, "X-WR-TIMEZONE:"+venueTimezone)
+	// calendar apps poll subscriptions, ask for the cache lifetime rather than their default day
+	writeICSLine(&sb, "REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	writeICSLine(&sb, "X-PUBLISHED-TTL:PT1H")
+	for _, session := range sessions {
+		description := session.summary() + " as of " + now.In(venueLocation()).Format("Jan 2 3:04 PM") + ".\nBook: " + bookingURL(session.rink, session.date)
+		writeICSLine(&sb, "BEGIN:VEVENT")
+		// stable per session, so a refresh updates the event instead of adding another
+		writeICSLine(&sb, "UID:"+session.rink.name+"-"+session.start.Format("20060102T1504")+"@bp-skate")
+		writeICSLine(&sb, "DTSTAMP:"+now.Format("20060102T150405Z"))
+		writeICSLine(&sb, "DTSTART:"+session.start.UTC().Format("20060102T150405Z"))
+		writeICSLine(&sb, "DTEND:"+session.end.UTC().Format("20060102T150405Z"))
+		writeICSLine(&sb, "SUMMARY:"+icsText(session.summary()))
+		writeICSLine(&sb, "DESCRIPTION:"+icsText(description))
+		writeICSLine(&sb, "LOCATION:"+icsText(rk.label()))
+		writeICSLine(&sb, "URL:"+bookingURL(session.rink, session.date))
+		writeICSLine(&sb, "TRANSP:TRANSPARENT")
+		writeICSLine(&sb, "END:VEVENT")
+	}
+	writeICSLine(&sb, "END:VCALENDAR")
+
+	setCacheControl(w, r)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="skateTimes.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(sb.String()))
+}
+
+// icsText escapes a TEXT value (RFC 5545 3.3.11)
+func icsText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// writeICSLine ends the line with CRLF, folding it at 75 octets (counting the leading space of
+// each continuation) without splitting a UTF-8 character
+func writeICSLine(sb *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	sb.WriteString(line + "\r\n")
+}
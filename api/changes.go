@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// maxChanges is how many change events each instance remembers for /feed.xml
+const maxChanges = 200
+
+// availabilityChange is one session's spot count changing between two fetches
+type availabilityChange struct {
+	Rink     rink
+	Date     string
+	Time     string // HHMM
+	Spots    int
+	Previous int
+	At       time.Time
+}
+
+// title is the feed entry title, e.g. "Jan 15 7:00 PM: 12 spots open, was 0"
+func (change availabilityChange) title() string {
+	dateObj, _ := time.Parse("2006-01-02", change.Date)
+	timeObj, _ := time.Parse("1504", change.Time)
+	when := dateObj.Format("Jan 2") + " " + timeObj.Format("3:04 PM")
+	if multiRink() {
+		when = change.Rink.label() + " " + when
+	}
+	if change.Spots == 0 {
+		return when + ": sold out, was " + strconv.Itoa(change.Previous)
+	}
+	return when + ": " + spotsText(change.Spots) + " open, was " + strconv.Itoa(change.Previous)
+}
+
+// changeLog holds the last counts seen per rink/date and the recent changes, newest last
+var changeLog = struct {
+	sync.Mutex
+	lastSeen map[string]map[string]int
+	changes  []availabilityChange
+}{lastSeen: map[string]map[string]int{}}
+
+// recordChanges diffs the date's sessions against the last fetch of it and remembers every count
+// that moved, returning those changes. The first fetch of a date is only the baseline.
+func recordChanges(rk rink, date string, skateTimesMap map[string]map[string]int) []availabilityChange {
+	keys, cleanedMap := xola.SortedSkateTimes(date, skateTimesMap, true)
+	key := rk.name + "/" + date
+	now := time.Now()
+
+	changeLog.Lock()
+	defer changeLog.Unlock()
+	previous, seen := changeLog.lastSeen[key]
+	changeLog.lastSeen[key] = cleanedMap
+	if !seen {
+		return nil
+	}
+	var changes []availabilityChange
+	for _, skateTime := range keys {
+		spots := cleanedMap[skateTime]
+		if was, ok := previous[skateTime]; spots != was && (ok || spots > 0) {
+			changes = append(changes, availabilityChange{Rink: rk, Date: date, Time: skateTime, Spots: spots, Previous: was, At: now})
+		}
+	}
+	changeLog.changes = append(changeLog.changes, changes...)
+	if len(changeLog.changes) > maxChanges {
+		changeLog.changes = changeLog.changes[len(changeLog.changes)-maxChanges:]
+	}
+	return changes
+}
+
+// atomFeed and atomEntry are the parts of Atom (RFC 4287) the change feed uses
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// feedHandler serves the recent availability changes as an Atom feed, newest first. ?opened=1
+// keeps only sessions that went from sold out to open, ?date= only changes for that date.
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	onlyOpened := r.URL.Query().Get("opened") == "1"
+	date := ""
+	if input := requestedDate(r); input != "" {
+		var err error
+		if date, _, err = normalizeDate(input); err != nil {
+			writeBadDate(w, input)
+			return
+		}
+	}
+	rk := rinkFromContext(r.Context())
+
+	changeLog.Lock()
+	changes := append([]availabilityChange(nil), changeLog.changes...)
+	changeLog.Unlock()
+
+	feed := atomFeed{
+		ID:     "tag:bp-skate," + time.Now().Format("2006") + ":changes/" + rk.name,
+		Title:  rk.label() + " skating availability changes",
+		Author: atomAuthor{Name: "bp-skate"},
+		Link:   atomLink{Href: bookingURL(rk, ""), Rel: "alternate"},
+	}
+	var matching []availabilityChange
+	for _, change := range changes {
+		if change.Rink.name != rk.name || (date != "" && change.Date != date) || (onlyOpened && (change.Previous != 0 || change.Spots == 0)) {
+			continue
+		}
+		matching = append(matching, change)
+	}
+	// newest fetch first, sessions in time order within it
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].At.After(matching[j].At)
+	})
+
+	// the feed's updated time is its newest entry's, so it only changes when there's news
+	updated := time.Unix(0, 0)
+	if len(matching) > 0 {
+		updated = matching[0].At
+	}
+	for _, change := range matching {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "tag:bp-skate," + change.At.Format("2006-01-02") + ":" + rk.name + "/" + change.Date + "/" + change.Time + "/" + strconv.FormatInt(change.At.UnixNano(), 10),
+			Title:   change.title(),
+			Updated: change.At.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: bookingURL(rk, change.Date)},
+			Summary: change.title() + " (seen " + change.At.In(venueLocation()).Format("Jan 2 3:04 PM") + ")",
+		})
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Could not build the feed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
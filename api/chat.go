@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// chatAckTimeout is how long a chat command waits for availability before acknowledging and
+// following up with the result. Slack and Discord both give up on a command after 3 seconds.
+const chatAckTimeout = 2500 * time.Millisecond
+
+// maxChatBody caps what a chat platform may post, commands are a few KB at most
+const maxChatBody = 64 << 10
+
+// chatQuery is a parsed `/skate [date] [rink]` command from one of the chat integrations
+type chatQuery struct {
+	rink    rink
+	date    string
+	dateObj time.Time
+}
+
+// parseChatQuery reads the text after the command. A word naming a configured rink picks it (at
+// either end, so "/skate wollman friday" and "/skate friday wollman" both work), the rest is the date,
+// in any form ?date= takes, today when empty. The problem is a message for the user.
+func parseChatQuery(text string) (chatQuery, string) {
+	words := strings.Fields(text)
+	rinks := configuredRinks()
+	query := chatQuery{rink: rinks[defaultRink]}
+	if len(words) > 0 {
+		if rk, ok := rinks[strings.ToLower(words[0])]; ok {
+			query.rink, words = rk, words[1:]
+		} else if rk, ok := rinks[strings.ToLower(words[len(words)-1])]; ok {
+			query.rink, words = rk, words[:len(words)-1]
+		}
+	}
+	date, dateObj, err := normalizeDate(strings.Join(words, " "))
+	if err != nil {
+		return query, "Could not understand the date \"" + date + "\", try YYYY-MM-DD, tomorrow or a weekday."
+	}
+	query.date, query.dateObj = date, dateObj
+	return query, ""
+}
+
+// lookup fetches the day the way /api would, returning it with the display options to render it
+func (query chatQuery) lookup(ctx context.Context) (map[string]map[string]int, formatOptions, error) {
+	opts := formatOptions{rink: query.rink}
+	if isClosedDay(query.rink, query.dateObj) {
+		return map[string]map[string]int{}, opts, nil
+	}
+	skateTimesMap, waitlists, err := querySkateTimesAPI(withRink(ctx, query.rink), query.date)
+	opts.waitlists = waitlists[query.date]
+	return skateTimesMap, opts, err
+}
+
+// ackWithin runs work in the background and waits up to timeout for it. finished is false when it
+// took longer, followUp then runs once work is done. started is false when shutting down, nothing ran.
+func ackWithin(timeout time.Duration, work func(), followUp func()) (finished bool, started bool) {
+	done := make(chan struct{})
+	late := make(chan struct{})
+	started = goBackground(func() {
+		work()
+		select {
+		case done <- struct{}{}:
+		case <-late:
+			followUp()
+		}
+	})
+	if !started {
+		return false, false
+	}
+	select {
+	case <-done:
+		return true, true
+	case <-time.After(timeout):
+		close(late)
+		return false, true
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is one in-progress Xola lookup that concurrent identical requests wait on
+type flight struct {
+	done          chan struct{}
+	skateTimesMap map[string]map[string]int
+	waitlists     map[string]map[string]bool
+	err           error
+}
+
+// flights are the lookups currently in progress, keyed by start/end range
+var flights = struct {
+	sync.Mutex
+	byRange map[string]*flight
+}{byRange: map[string]*flight{}}
+
+// coalesce runs fetch once for every concurrent caller with the same key, so the bot and the browser
+// asking for the same date share one upstream request. The shared fetch isn't tied to any one
+// caller's context (it's still bounded by XOLA_TIMEOUT_MS), but each caller stops waiting when
+// its own context is done. Callers get the same maps back and must not modify them.
+func coalesce(ctx context.Context, key string, fetch func(context.Context) (map[string]map[string]int, map[string]map[string]bool, error)) (map[string]map[string]int, map[string]map[string]bool, error) {
+	flights.Lock()
+	call, inFlight := flights.byRange[key]
+	if !inFlight {
+		call = &flight{done: make(chan struct{})}
+		flights.byRange[key] = call
+	}
+	flights.Unlock()
+
+	if !inFlight {
+		go func() {
+			call.skateTimesMap, call.waitlists, call.err = fetch(context.WithoutCancel(ctx))
+			flights.Lock()
+			delete(flights.byRange, key)
+			flights.Unlock()
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+		return call.skateTimesMap, call.waitlists, call.err
+	case <-ctx.Done():
+		return map[string]map[string]int{}, map[string]map[string]bool{}, ctx.Err()
+	}
+}
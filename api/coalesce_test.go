@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoalesceSharesOneFetch(t *testing.T) {
+	const callers = 20
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (map[string]map[string]int, map[string]map[string]bool, error) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			<-release
+		}
+		return map[string]map[string]int{"2024-01-02": {"1500": 4}}, map[string]map[string]bool{}, nil
+	}
+
+	var started, done sync.WaitGroup
+	results := make([]map[string]map[string]int, callers)
+	started.Add(callers)
+	done.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer done.Done()
+			started.Done()
+			skateTimesMap, _, err := coalesce(context.Background(), "bp/coalesce-test", fetch)
+			if err != nil {
+				t.Errorf("caller %d: %v", i, err)
+			}
+			results[i] = skateTimesMap
+		}(i)
+	}
+	// every caller has at least reached coalesce and the first fetch is still waiting
+	started.Wait()
+	for !inFlight("bp/coalesce-test") {
+	}
+	close(release)
+	done.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n < 1 || n >= callers {
+		t.Errorf("%d callers caused %d fetches, want them shared", callers, n)
+	}
+	shared := map[string]int{}
+	for i, skateTimesMap := range results {
+		if skateTimesMap["2024-01-02"]["1500"] != 4 {
+			t.Errorf("caller %d got %v", i, skateTimesMap)
+		}
+		shared[fmt.Sprintf("%p", skateTimesMap)]++
+	}
+	if len(shared) > int(atomic.LoadInt32(&fetches)) {
+		t.Errorf("%d different results from %d fetches", len(shared), fetches)
+	}
+	if inFlight("bp/coalesce-test") {
+		t.Error("the finished flight is still registered")
+	}
+}
+
+func TestCoalesceCallerGivesUp(t *testing.T) {
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (map[string]map[string]int, map[string]map[string]bool, error) {
+		<-release
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return map[string]map[string]int{"2024-01-02": {"1500": 4}}, map[string]map[string]bool{}, nil
+	}
+	waiting := make(chan map[string]map[string]int)
+	go func() {
+		skateTimesMap, _, _ := coalesce(context.Background(), "bp/give-up-test", fetch)
+		waiting <- skateTimesMap
+	}()
+	for !inFlight("bp/give-up-test") {
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := coalesce(ctx, "bp/give-up-test", fetch); err != context.Canceled {
+		t.Errorf("err = %v, want the caller's own cancellation", err)
+	}
+	close(release)
+	if skateTimesMap := <-waiting; skateTimesMap["2024-01-02"]["1500"] != 4 {
+		t.Errorf("the remaining caller got %v after another gave up", skateTimesMap)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": 2}, "2024-01-03": {"1500": 3}, "2024-01-04": {"1500": 4}, "2024-01-05": {"1500": 5}}`)
+	dates := []string{"2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"}
+	ctx := withRink(context.Background(), configuredRinks()[defaultRink])
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		date := dates[i%len(dates)]
+		want := fmt.Sprintf("3:00 PM has %c spots\n", date[len(date)-1])
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if w := get(t, "/api?date="+date); !strings.HasSuffix(w.Body.String(), want) {
+				t.Errorf("%s: body %q, want %q", date, w.Body.String(), want)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			skateTimesMap, _, err := querySkateTimesRange(ctx, date, date)
+			if err != nil || skateTimesMap[date]["1500"] != int(date[len(date)-1]-'0') {
+				t.Errorf("%s: %v, %v", date, skateTimesMap, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// inFlight reports whether a lookup for key is currently running
+func inFlight(key string) bool {
+	flights.Lock()
+	defer flights.Unlock()
+	return flights.byRange[key] != nil
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"compress/gzip"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// gzipWriter compresses the response body when the client accepts gzip. Bodiless responses and
+// images (already compressed) go out as they are.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+// compressResponse wraps w in a gzipWriter when Accept-Encoding allows it and COMPRESS_RESPONSES
+// isn't 0. close must be called once the handler is done, to flush the compressed stream.
+func compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if os.Getenv("COMPRESS_RESPONSES") == "0" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return w, func() {}
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	gw := &gzipWriter{ResponseWriter: w}
+	return gw, func() {
+		if gw.gz != nil {
+			gw.gz.Close()
+		}
+	}
+}
+
+// acceptsGzip reads Accept-Encoding, honoring an explicit gzip;q=0
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(fields[0], "gzip") && fields[0] != "*" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q := strings.TrimSpace(param); q == "q=0" || q == "q=0.0" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	bodiless := status == http.StatusNoContent || status == http.StatusNotModified
+	if !bodiless && !strings.HasPrefix(w.Header().Get("Content-Type"), "image/") {
+		// net/http would sniff the compressed bytes otherwise, every untyped response here is text
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.gz.Write(data)
+}
@@ -0,0 +1,502 @@
+package handler
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// Configuration is read from environment variables everywhere (os.Getenv and the env.go helpers).
+// CONFIG_FILE can point at a JSON or flat YAML file of the same variable names, which fills in
+// whatever isn't already set in the environment, so env always wins. Both the Vercel handler and
+// cmd/server load it the same way, the first time the package initializes.
+
+// setting is one configuration variable and how to check its value, validate is nil for free text
+type setting struct {
+	key      string
+	validate func(string) error
+}
+
+// settings are every variable the service reads
+var settings = []setting{
+	{"ACCESSIBLE_SESSIONS", validSessionTimes},
+	{"ADMIN_TOKEN", nil},
+	{"AFTERNOON_START", validSessionTime},
+	{"ALEXA_SKILL_ID", nil},
+	{"ALERT_DATES", validAlertDates},
+	{"ALERT_SUBSCRIBERS", validSubscribers},
+	{"ALLOWED_IPS", validCIDRs},
+	{"API_KEYS", validNamedKeys},
+	{"AUDIT_LOG", nil},
+	{"AUTH_HEADER", nil},
+	{"AUTH_MODE", validAuthMode},
+	{"AUTH_TOKEN", nil},
+	{"BIND_ADDR", nil},
+	{"BOOKING_URL", validURL},
+	{"CACHE_MAX_AGE", validCount},
+	{"CACHE_STALE_SECONDS", validCount},
+	{"CACHE_S_MAXAGE", validCount},
+	{"CACHE_TTL_SECONDS", validCount},
+	{"CIRCUIT_COOLDOWN_MS", validCount},
+	{"CIRCUIT_FAILURES", validCount},
+	{"CLOSED_WEEKDAYS", validWeekdays},
+	{"COMPRESS_RESPONSES", validFlag},
+	{"CORS_HEADERS", nil},
+	{"CORS_METHODS", nil},
+	{"CORS_ORIGINS", nil},
+	{"DIGEST_DAYS", validCount},
+	{"DIGEST_TIME", validSessionTime},
+	{"DISCORD_APPLICATION_ID", nil},
+	{"DISCORD_BOT_TOKEN", nil},
+	{"DISCORD_PUBLIC_KEY", validPublicKey},
+	{"EMAIL_FROM", nil},
+	{"ENABLED_FORMATS", validFormats},
+	{"EVENING_START", validSessionTime},
+	{"GOOGLE_CALENDAR_ID", nil},
+	{"GOOGLE_CALENDAR_SESSIONS", validSessionTimes},
+	{"GOOGLE_CLIENT_ID", nil},
+	{"GOOGLE_CLIENT_SECRET", nil},
+	{"GOOGLE_REFRESH_TOKEN", nil},
+	{"GRPC_PORT", validPort},
+	{"INDOOR_SESSIONS", validSessionTimes},
+	{"JWT_AUDIENCE", nil},
+	{"JWT_ISSUER", nil},
+	{"JWT_JWKS_URL", validURL},
+	{"JWT_PUBLIC_KEY", nil},
+	{"JWT_SECRET", nil},
+	{"KV_REST_API_TOKEN", nil},
+	{"KV_REST_API_URL", validURL},
+	{"LOG_FORMAT", validLogFormat},
+	{"LOG_LEVEL", validLogLevel},
+	{"MIDNIGHT_GRACE_MINUTES", validCount},
+	{"NEXT_AVAILABLE_DAYS", validCount},
+	{"PORT", validPort},
+	{"PPROF", validFlag},
+	{"PRICE_CURRENCY", nil},
+	{"PRICE_LOCALE", nil},
+	{"PUBLIC_URL", validURL},
+	{"QR_CODES", validFlag},
+	{"RATE_LIMITS", validRateLimits},
+	{"RATE_LIMIT_PER_MINUTE", validCount},
+	{"REFRESH_DAYS", validCount},
+	{"REFRESH_INTERVAL_SECONDS", validCount},
+	{"REQUEST_SIGNING_SECRET", nil},
+	{"RETRY_ATTEMPTS", validCount},
+	{"RETRY_BACKOFF_MS", validCount},
+	{"RETRY_DEADLINE_MS", validCount},
+	{"RETRY_JITTER", validFlag},
+	{"RINKS", validRinks},
+	{"SEASON_END", validDate},
+	{"SEASON_START", validDate},
+	{"SELF_CHECK", validFlag},
+	{"SELF_CHECK_STRICT", validFlag},
+	{"SENDGRID_API_KEY", nil},
+	{"SESSION_MINUTES", validCount},
+	{"SHOW_PRICES", validFlag},
+	{"SHUTDOWN_TIMEOUT_SECONDS", validCount},
+	{"SLACK_SIGNING_SECRET", nil},
+	{"SMTP_HOST", nil},
+	{"SMTP_PASSWORD", nil},
+	{"SMTP_PORT", validPort},
+	{"SMTP_USER", nil},
+	{"SNAPSHOT_LOG", nil},
+	{"SPOTS_FLOOR", validCount},
+	{"TELEGRAM_BOT_TOKEN", nil},
+	{"TELEGRAM_WEBHOOK_SECRET", nil},
+	{"TRUSTED_PROXIES", validCIDRs},
+	{"TWILIO_ACCOUNT_SID", nil},
+	{"TWILIO_AUTH_TOKEN", nil},
+	{"TWILIO_FROM", nil},
+	{"TWILIO_WEBHOOK_URL", validURL},
+	{"TWILIO_WHATSAPP_FROM", nil},
+	{"UPSTASH_REDIS_REST_TOKEN", nil},
+	{"UPSTASH_REDIS_REST_URL", validURL},
+	{"VAPID_PRIVATE_KEY", validVAPIDKey},
+	{"VAPID_SUBJECT", nil},
+	{"WATCH_INTERVAL_SECONDS", validCount},
+	{"WEBHOOK_SECRET", nil},
+	{"WEBHOOK_URL", validURL},
+	{"WHATSAPP_PHONE_NUMBER_ID", nil},
+	{"WHATSAPP_TOKEN", nil},
+	{"XOLA_BASE_URL", validURL},
+	{"XOLA_EXPERIENCE_ID", nil},
+	{"XOLA_PROXY", validURL},
+	{"XOLA_PROXY_PASSWORD", nil},
+	{"XOLA_PROXY_USER", nil},
+	{"XOLA_RATE_LIMIT_PER_MINUTE", validCount},
+	{"XOLA_TIMEOUT_MS", validCount},
+}
+
+var loadConfigOnce sync.Once
+
+func init() {
+	loadConfig()
+}
+
+// configDone is set once loadConfig has finished
+var configDone int32
+
+func configLoaded() bool {
+	return atomic.LoadInt32(&configDone) == 1
+}
+
+// loadConfig applies CONFIG_FILE and validates the result, once. A config file that can't be read
+// stops startup, it was asked for explicitly. Bad values are logged (the readers fall back to their
+// defaults) unless CONFIG_STRICT=1, which stops startup too.
+func loadConfig() {
+	loadConfigOnce.Do(func() {
+		setupLogging()
+		if path := os.Getenv("CONFIG_FILE"); path != "" {
+			if err := applyConfigFile(path); err != nil {
+				fatal("could not load CONFIG_FILE", "path", path, "error", err)
+			}
+		}
+		// the file may have set LOG_FORMAT or LOG_LEVEL
+		setupLogging()
+		problems := validateConfig()
+		defer atomic.StoreInt32(&configDone, 1)
+		for _, problem := range problems {
+			slog.Warn("bad config value", "problem", problem)
+		}
+		if len(problems) > 0 && os.Getenv("CONFIG_STRICT") == "1" {
+			fatal("invalid configuration, refusing to start")
+		}
+	})
+}
+
+// applyConfigFile sets every variable from the file that the environment doesn't already have
+func applyConfigFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".yaml", ".yml":
+		values, err = parseYAMLConfig(data)
+	default:
+		return errors.New("expected a .json, .yaml or .yml file")
+	}
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	for key, value := range values {
+		key = strings.ToUpper(key)
+		if !known[key] {
+			slog.Warn("unknown setting in config file", "key", key, "path", path)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return nil
+}
+
+// parseJSONConfig reads a JSON object of settings, numbers and booleans are taken as written
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for key, value := range raw {
+		switch typed := value.(type) {
+		case string:
+			values[key] = typed
+		case float64:
+			values[key] = strconv.FormatFloat(typed, 'f', -1, 64)
+		case bool:
+			values[key] = "0"
+			if typed {
+				values[key] = "1"
+			}
+		default:
+			return nil, errors.New(key + " must be a string, number or boolean")
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig reads flat `KEY: value` lines, the only YAML a settings file needs. Blank lines
+// and # comments are skipped and values may be quoted.
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": expected KEY: value")
+		}
+		value := strings.TrimSpace(parts[1])
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		} else if comment := strings.Index(value, " #"); comment >= 0 {
+			value = strings.TrimSpace(value[:comment])
+		}
+		switch value {
+		case "true":
+			value = "1"
+		case "false":
+			value = "0"
+		}
+		values[strings.TrimSpace(parts[0])] = value
+	}
+	return values, nil
+}
+
+// validateConfig checks every set variable, returning one message per bad value in key order
+func validateConfig() []string {
+	var problems []string
+	for _, s := range settings {
+		value := os.Getenv(s.key)
+		if value == "" || s.validate == nil {
+			continue
+		}
+		if err := s.validate(value); err != nil {
+			problems = append(problems, s.key+"="+value+": "+err.Error())
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func validCount(value string) error {
+	if number, err := strconv.Atoi(value); err != nil || number < 0 {
+		return errors.New("expected a whole number of 0 or more")
+	}
+	return nil
+}
+
+func validFlag(value string) error {
+	if value != "0" && value != "1" {
+		return errors.New("expected 0 or 1")
+	}
+	return nil
+}
+
+func validAuthMode(value string) error {
+	switch strings.ToLower(value) {
+	case "key", "hmac", "jwt":
+		return nil
+	}
+	return errors.New("expected key, hmac or jwt")
+}
+
+func validLogFormat(value string) error {
+	switch strings.ToLower(value) {
+	case "text", "json":
+		return nil
+	}
+	return errors.New("expected text or json")
+}
+
+func validLogLevel(value string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return errors.New("expected debug, info, warn or error")
+	}
+	return nil
+}
+
+func validCIDRs(value string) error {
+	if strings.TrimSpace(value) == "*" {
+		return nil
+	}
+	for _, entry := range listItems(value) {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return errors.New(entry + " is not an IP address or CIDR")
+		}
+	}
+	return nil
+}
+
+func validPort(value string) error {
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		return errors.New("expected a port number")
+	}
+	return nil
+}
+
+func validURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return errors.New("expected an absolute URL")
+	}
+	return nil
+}
+
+// validPublicKey is a hex Ed25519 public key, as Discord shows it
+func validPublicKey(value string) error {
+	if key, err := hex.DecodeString(value); err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("expected a 64 character hex public key")
+	}
+	return nil
+}
+
+func validVAPIDKey(value string) error {
+	scalar, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		_, err = ecdh.P256().NewPrivateKey(scalar)
+	}
+	if err != nil {
+		return errors.New("expected a base64url P-256 private key")
+	}
+	return nil
+}
+
+func validDate(value string) error {
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		return errors.New("expected YYYY-MM-DD")
+	}
+	return nil
+}
+
+func validSessionTime(value string) error {
+	if _, ok := xola.NormalizeSlotTime(value); !ok {
+		return errors.New("expected HH:MM")
+	}
+	return nil
+}
+
+func validSessionTimes(value string) error {
+	for _, part := range listItems(value) {
+		if err := validSessionTime(part); err != nil {
+			return errors.New(part + ": expected comma-separated HH:MM times")
+		}
+	}
+	return nil
+}
+
+func validWeekdays(value string) error {
+	for _, part := range listItems(value) {
+		if _, ok := parseWeekday(strings.ToLower(part)); !ok {
+			return errors.New(part + " is not a weekday")
+		}
+	}
+	return nil
+}
+
+// validAlertDates are YYYY-MM-DD dates or weekday names
+func validAlertDates(value string) error {
+	for _, part := range listItems(value) {
+		if _, ok := parseWeekday(strings.ToLower(part)); !ok && validDate(part) != nil {
+			return errors.New(part + " is not a date or weekday")
+		}
+	}
+	return nil
+}
+
+// validSubscribers are `channel:address` pairs for a channel alerts can go out on
+func validSubscribers(value string) error {
+	for _, part := range listItems(value) {
+		entry := strings.SplitN(part, ":", 2)
+		if len(entry) != 2 || entry[1] == "" {
+			return errors.New(part + " is not channel:address")
+		}
+		if alertChannels[strings.ToLower(entry[0])] == nil {
+			return errors.New("unknown alert channel " + entry[0])
+		}
+	}
+	return nil
+}
+
+func validFormats(value string) error {
+	for _, part := range listItems(value) {
+		format := strings.ToLower(part)
+		supported := false
+		for _, candidate := range supportedFormats {
+			supported = supported || candidate == format
+		}
+		if !supported {
+			return errors.New(format + " is not one of " + strings.Join(supportedFormats, ", "))
+		}
+	}
+	return nil
+}
+
+func validRinks(value string) error {
+	if err := validPairs(value, "name=experienceID"); err != nil {
+		return err
+	}
+	for _, entry := range listItems(value) {
+		options := strings.Split(strings.SplitN(entry, "=", 2)[1], ";")
+		for _, option := range options[1:] {
+			option = strings.TrimSpace(option)
+			if !strings.HasPrefix(strings.ToLower(option), "closed=") {
+				return errors.New(option + " is not a rink option, expected closed=mon+tue")
+			}
+			if err := validWeekdays(strings.Replace(option[len("closed="):], "+", ",", -1)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validRateLimits(value string) error {
+	if err := validPairs(value, "name=perMinute"); err != nil {
+		return err
+	}
+	for _, entry := range listItems(value) {
+		if err := validCount(strings.SplitN(entry, "=", 2)[1]); err != nil {
+			return errors.New(entry + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func validNamedKeys(value string) error {
+	return validPairs(value, "name=key")
+}
+
+// validPairs checks a comma-separated list of name=value pairs, shape is what the error asks for
+func validPairs(value string, shape string) error {
+	for _, entry := range listItems(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			// don't echo the entry, it may be a secret
+			return errors.New("expected comma-separated " + shape + " pairs")
+		}
+	}
+	return nil
+}
+
+// listItems splits a comma-separated value, skipping empty items the same way the readers do
+func listItems(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CORS defaults, see CORS_METHODS and CORS_HEADERS
+const (
+	defaultCORSMethods = "GET, POST, DELETE, OPTIONS"
+	defaultCORSHeaders = "Content-Type, Authorization, X-Signature, X-Request-ID"
+)
+
+// corsExposedHeaders are the response headers a browser script may read
+const corsExposedHeaders = "X-Availability-Digest, X-Request-ID, ETag, Last-Modified, Age, Retry-After"
+
+// applyCORS sets the CORS headers for an allowed Origin (CORS_ORIGINS, comma-separated or *) and
+// answers preflight requests itself. It reports whether the request was a preflight, in which case
+// there's nothing left to do. Preflights come before auth, browsers never send credentials on them.
+func applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !corsOriginAllowed(origin) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	methods := os.Getenv("CORS_METHODS")
+	if methods == "" {
+		methods = defaultCORSMethods
+	}
+	headers := os.Getenv("CORS_HEADERS")
+	if headers == "" {
+		headers = defaultCORSHeaders
+		// whatever header the token is read from has to be allowed too
+		if authHeader := os.Getenv("AUTH_HEADER"); authHeader != "" {
+			headers += ", " + authHeader
+		} else {
+			headers += ", " + defaultAuthHeader
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+	w.Header().Set("Access-Control-Allow-Headers", headers)
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func corsOriginAllowed(origin string) bool {
+	for _, allowed := range listItems(os.Getenv("CORS_ORIGINS")) {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeCSVResponse writes one date,time,spots row per session across the days, for pasting into a
+// spreadsheet. Masked counts (SPOTS_FLOOR) are written as "limited".
+func writeCSVResponse(w http.ResponseWriter, days []availability) {
+	var sb strings.Builder
+	out := csv.NewWriter(&sb)
+	out.Write([]string{"date", "time", "spots"})
+	for _, day := range days {
+		for _, slot := range day.Slots {
+			spots := strconv.Itoa(slot.Spots)
+			if slot.Limited {
+				spots = "limited"
+			}
+			out.Write([]string{day.Date, slot.Time, spots})
+		}
+	}
+	out.Flush()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="skateTimes.csv"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(sb.String()))
+}
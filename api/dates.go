@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the input formats accepted for the date, the first one is canonical (and what Xola expects)
+var dateLayouts = []string{
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/2006",
+	"2006/01/02",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// normalizeDate parses the date in any of dateLayouts and returns it in the canonical YYYY-MM-DD layout,
+// along with the time parsed back from that canonical string so the two can never disagree
+func normalizeDate(input string) (string, time.Time, error) {
+	input = strings.TrimSpace(input)
+	// no date means today at the rink, not UTC today
+	if resolved, ok := resolveRelativeDate(input, time.Now().In(venueLocation())); ok {
+		input = resolved.Format(dateLayouts[0])
+	}
+	for _, layout := range dateLayouts {
+		parsed, err := time.Parse(layout, input)
+		if err != nil {
+			continue
+		}
+		date := parsed.Format(dateLayouts[0])
+		dateObj, _ := time.Parse(dateLayouts[0], date)
+		return date, dateObj, nil
+	}
+	return input, time.Time{}, errors.New("unrecognized date: " + input)
+}
+
+// requestDate reads and normalizes the requested date from ?date= (or ?start= for ranges),
+// falling back to the startDate header older Shortcuts send
+func requestDate(r *http.Request) (string, time.Time, error) {
+	query := r.URL.Query()
+	for _, param := range []string{"date", "start"} {
+		if value := query.Get(param); value != "" {
+			return normalizeDate(value)
+		}
+	}
+	return normalizeDate(r.Header.Get("startDate"))
+}
+
+// dateRequested reports whether the request names a date at all, rather than defaulting to today
+func dateRequested(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Get("date") != "" || query.Get("start") != "" || r.Header.Get("startDate") != ""
+}
+
+// requestEndDate is the raw end of a date range from ?end= or the endDate header, "" for single-day requests
+func requestEndDate(r *http.Request) string {
+	if end := r.URL.Query().Get("end"); end != "" {
+		return end
+	}
+	return r.Header.Get("endDate")
+}
+
+// resolveRelativeDate understands casual dates relative to today: "", "today", "tomorrow", "yesterday",
+// weekday names ("friday" is today if it's Friday, otherwise the coming one), "next saturday" (always
+// after today) and "in 3 days" / "in 2 weeks"
+func resolveRelativeDate(input string, today time.Time) (time.Time, bool) {
+	words := strings.Fields(strings.ToLower(input))
+	switch {
+	case len(words) == 0:
+		return today, true
+	case len(words) == 1 && words[0] == "today":
+		return today, true
+	case len(words) == 1 && words[0] == "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	case len(words) == 1 && words[0] == "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case len(words) == 3 && words[0] == "in":
+		count, err := strconv.Atoi(words[1])
+		if words[1] == "a" || words[1] == "one" {
+			count, err = 1, nil
+		}
+		if err != nil || count < 0 {
+			return time.Time{}, false
+		}
+		switch strings.TrimSuffix(words[2], "s") {
+		case "day":
+			return today.AddDate(0, 0, count), true
+		case "week":
+			return today.AddDate(0, 0, 7*count), true
+		}
+		return time.Time{}, false
+	}
+
+	// "friday", "this friday", "next friday"
+	next := false
+	if len(words) == 2 && (words[0] == "next" || words[0] == "this") {
+		next = words[0] == "next"
+		words = words[1:]
+	}
+	if len(words) != 1 {
+		return time.Time{}, false
+	}
+	weekday, ok := parseWeekday(words[0])
+	if !ok {
+		return time.Time{}, false
+	}
+	days := (int(weekday) - int(today.Weekday()) + 7) % 7
+	if days == 0 && next {
+		days = 7
+	}
+	return today.AddDate(0, 0, days), true
+}
+
+// parseWeekday accepts full weekday names and abbreviations of at least three letters
+func parseWeekday(name string) (time.Weekday, bool) {
+	if len(name) < 3 {
+		return time.Sunday, false
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.HasPrefix(strings.ToLower(day.String()), name) {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// writeBadDate is the 400 for a date we couldn't resolve
+func writeBadDate(w http.ResponseWriter, date string) {
+	slog.Warn("bad date input", "date", date)
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("Could not understand the date \"" + date + "\", try 2024-01-15, tomorrow, friday, next saturday or in 3 days"))
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"2024-01-02", "2024-01-02", true},
+		{"01/02/2024", "2024-01-02", true},
+		{"1/2/2024", "2024-01-02", true},
+		{"2024/01/02", "2024-01-02", true},
+		{"Jan 2, 2024", "2024-01-02", true},
+		{"January 2, 2024", "2024-01-02", true},
+		{" 2024-01-02 ", "2024-01-02", true},
+		{"2024-02-30", "", false},
+		{"02.01.2024", "", false},
+	}
+	for _, test := range tests {
+		date, dateObj, err := normalizeDate(test.input)
+		if (err == nil) != test.ok || (test.ok && (date != test.want || dateObj.Format("2006-01-02") != test.want)) {
+			t.Errorf("normalizeDate(%q) = %q, %v, %v, want %q", test.input, date, dateObj, err, test.want)
+		}
+	}
+}
+
+func TestResolveRelativeDate(t *testing.T) {
+	// a Tuesday
+	today := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"", "2024-01-02", true},
+		{"today", "2024-01-02", true},
+		{"Tomorrow", "2024-01-03", true},
+		{"yesterday", "2024-01-01", true},
+		{"tuesday", "2024-01-02", true},
+		{"next tuesday", "2024-01-09", true},
+		{"fri", "2024-01-05", true},
+		{"this saturday", "2024-01-06", true},
+		{"in 3 days", "2024-01-05", true},
+		{"in a week", "2024-01-09", true},
+		{"in 2 weeks", "2024-01-16", true},
+		{"in 2 months", "", false},
+		{"fr", "", false},
+		{"someday", "", false},
+	}
+	for _, test := range tests {
+		got, ok := resolveRelativeDate(test.input, today)
+		if ok != test.ok || (ok && got.Format("2006-01-02") != test.want) {
+			t.Errorf("resolveRelativeDate(%q) = %v, %v, want %s", test.input, got, ok, test.want)
+		}
+	}
+}
+
+func TestHeaderShowsQueriedDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header []string
+	}{
+		{"slashes", "/api?date=" + url.QueryEscape("01/02/2024"), nil},
+		{"short slashes", "/api?date=" + url.QueryEscape("1/2/2024"), nil},
+		{"written out", "/api?date=" + url.QueryEscape("January 2, 2024"), nil},
+		{"startDate header", "/api", []string{"startDate", "01/02/2024"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+			w := get(t, test.target, test.header...)
+			if !strings.HasPrefix(w.Body.String(), "For Jan 2, 2024:\n") {
+				t.Errorf("body = %q, want the header for Jan 2, 2024", w.Body.String())
+			}
+			if stub.calls() != 1 || stub.requests[0].URL.Query().Get("start") != "2024-01-02" {
+				t.Errorf("asked Xola for %v", stub.requests)
+			}
+		})
+	}
+}
+
+func TestBadDate(t *testing.T) {
+	stub := newXolaStub(t, `{}`)
+	if w := get(t, "/api?date=smarch+13"); w.Code != http.StatusBadRequest || stub.calls() != 0 {
+		t.Errorf("status = %d after %d Xola calls, want 400 without asking", w.Code, stub.calls())
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// default daypart boundaries (HHMM), sessions from the boundary on belong to the next part
+const (
+	defaultAfternoonStart = "1200"
+	defaultEveningStart   = "1700"
+)
+
+// daypartBoundary reads an HHMM boundary from env, falling back to the default when unset or invalid
+func daypartBoundary(key string, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	boundary, ok := xola.NormalizeSlotTime(value)
+	if !ok {
+		slog.Warn("ignoring bad setting", "key", key, "value", value)
+		return fallback
+	}
+	return boundary
+}
+
+// daypart names the part of the day a slot time (HHMM) falls in
+func daypart(skateTime string) string {
+	switch {
+	case skateTime >= daypartBoundary("EVENING_START", defaultEveningStart):
+		return "Evening"
+	case skateTime >= daypartBoundary("AFTERNOON_START", defaultAfternoonStart):
+		return "Afternoon"
+	}
+	return "Morning"
+}
@@ -0,0 +1,52 @@
+package handler
+
+import "testing"
+
+func TestDaypart(t *testing.T) {
+	tests := []struct {
+		afternoon string
+		evening   string
+		time      string
+		want      string
+	}{
+		{"", "", "0900", "Morning"},
+		{"", "", "1159", "Morning"},
+		{"", "", "1200", "Afternoon"},
+		{"", "", "1659", "Afternoon"},
+		{"", "", "1700", "Evening"},
+		{"13:00", "18:30", "1230", "Morning"},
+		{"13:00", "18:30", "1800", "Afternoon"},
+		{"13:00", "18:30", "1830", "Evening"},
+		{"noon", "", "1200", "Afternoon"},
+	}
+	for _, test := range tests {
+		resetAvailability(t)
+		t.Setenv("AFTERNOON_START", test.afternoon)
+		t.Setenv("EVENING_START", test.evening)
+		if got := daypart(test.time); got != test.want {
+			t.Errorf("AFTERNOON_START=%q EVENING_START=%q: daypart(%s) = %s, want %s", test.afternoon, test.evening, test.time, got, test.want)
+		}
+	}
+}
+
+func TestGroupedText(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"every part", `{"2024-01-02": {"900": 1, "1000": 2, "1300": 3, "1900": 4}}`,
+			"For Jan 2, 2024:\nMorning\n9:00 AM has 1 spots\n10:00 AM has 2 spots\nAfternoon\n1:00 PM has 3 spots\nEvening\n7:00 PM has 4 spots\n"},
+		{"empty parts have no header", `{"2024-01-02": {"900": 1, "1100": 0, "1900": 4}}`,
+			"For Jan 2, 2024:\nMorning\n9:00 AM has 1 spots\nEvening\n7:00 PM has 4 spots\n"},
+		{"sold out", `{"2024-01-02": {"900": 0}}`, "For Jan 2, 2024:\nSold out\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, test.payload)
+			if w := get(t, "/api?date=2024-01-02&group=1"); w.Body.String() != test.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// digestHeader carries the availability digest on the digest endpoint response
+const digestHeader = "X-Availability-Digest"
+
+// digestHandler returns a short hash of a date's availability so clients can poll cheaply
+// and only fetch the full schedule when it changes
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	date, _, err := requestDate(r)
+	if err != nil {
+		writeBadDate(w, date)
+		return
+	}
+	rawResponse, _, err := querySkateTimesAPI(r.Context(), date)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	digest := availabilityDigest(date, rawResponse)
+
+	w.Header().Set(digestHeader, digest)
+	var sb strings.Builder
+	sb.WriteString(digest)
+	writeSuccessResponse(w, &sb)
+}
+
+// availabilityDigest hashes the non-empty slots for the date in sorted order, so the same
+// availability always produces the same digest
+func availabilityDigest(date string, skateTimesMap map[string]map[string]int) string {
+	keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+	hash := sha256.New()
+	hash.Write([]byte(date + "\n"))
+	for _, skateTime := range keys {
+		hash.Write([]byte(skateTime + "=" + strconv.Itoa(cleanedMap[skateTime]) + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// changes remembers each rink and date's last digest ("<rink>/<date>") and when it was first seen,
+// which is what Last-Modified reports
+var changes = struct {
+	sync.Mutex
+	byRinkDate map[string]digestSeen
+}{byRinkDate: map[string]digestSeen{}}
+
+type digestSeen struct {
+	digest string
+	at     time.Time
+}
+
+// lastModified is when the rink's availability for the date last changed, as far as this instance
+// has seen
+func lastModified(rk rink, date string, digest string) time.Time {
+	changes.Lock()
+	defer changes.Unlock()
+	key := rk.name + "/" + date
+	seen, ok := changes.byRinkDate[key]
+	if !ok || seen.digest != digest {
+		seen = digestSeen{digest: digest, at: time.Now().UTC().Truncate(time.Second)}
+		changes.byRinkDate[key] = seen
+	}
+	return seen.at
+}
+
+// notModified sets ETag and Last-Modified for the availability and reports whether the client's
+// cached copy (If-None-Match, or If-Modified-Since when there's no ETag to compare) is still current.
+// The ETag is weak, the text and JSON renderings of the same availability share it.
+func notModified(w http.ResponseWriter, r *http.Request, date string, digest string) bool {
+	etag := `W/"` + digest + `"`
+	modified := lastModified(rinkFromContext(r.Context()), date, digest)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	w.Header().Add("Vary", "Accept")
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag[2:] {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
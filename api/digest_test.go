@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAvailabilityDigest(t *testing.T) {
+	base := map[string]map[string]int{"2024-01-02": {"1500": 4, "1600": 2}}
+	tests := []struct {
+		name   string
+		counts map[string]map[string]int
+		same   bool
+	}{
+		{"same availability", map[string]map[string]int{"2024-01-02": {"1600": 2, "1500": 4}}, true},
+		{"sold out sessions don't count", map[string]map[string]int{"2024-01-02": {"1500": 4, "1600": 2, "1700": 0}}, true},
+		{"other dates don't count", map[string]map[string]int{"2024-01-02": {"1500": 4, "1600": 2}, "2024-01-03": {"1500": 9}}, true},
+		{"a count moved", map[string]map[string]int{"2024-01-02": {"1500": 3, "1600": 2}}, false},
+		{"a session opened", map[string]map[string]int{"2024-01-02": {"1500": 4, "1600": 2, "1700": 1}}, false},
+	}
+	want := availabilityDigest("2024-01-02", base)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := availabilityDigest("2024-01-02", test.counts)
+			if (got == want) != test.same {
+				t.Errorf("digest %s vs %s, want same = %v", got, want, test.same)
+			}
+		})
+	}
+}
+
+func TestDigestEndpoint(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	first := get(t, "/api/digest?date=2024-01-02")
+	if first.Code != http.StatusOK || first.Body.String() != first.Header().Get(digestHeader) {
+		t.Fatalf("status %d, body %q, header %q", first.Code, first.Body.String(), first.Header().Get(digestHeader))
+	}
+	if again := get(t, "/api/digest?date=2024-01-02"); again.Body.String() != first.Body.String() {
+		t.Errorf("digest changed without the data changing: %s, then %s", first.Body.String(), again.Body.String())
+	}
+
+	stub.answer(http.StatusOK, `{"2024-01-02": {"1500": 3}}`)
+	changed := get(t, "/api/digest?date=2024-01-02&fresh=1")
+	if changed.Body.String() == first.Body.String() {
+		t.Errorf("digest %s didn't change when the spots did", changed.Body.String())
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	first := get(t, "/api?date=2024-01-02")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, ETag %q", first.Code, etag)
+	}
+	tests := []struct {
+		name   string
+		header []string
+		want   int
+	}{
+		{"matching ETag", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"one of several", []string{"If-None-Match", `W/"other", ` + etag}, http.StatusNotModified},
+		{"stale ETag", []string{"If-None-Match", `W/"other"`}, http.StatusOK},
+		{"not modified since", []string{"If-Modified-Since", first.Header().Get("Last-Modified")}, http.StatusNotModified},
+		{"modified since", []string{"If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT"}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w := get(t, "/api?date=2024-01-02", test.header...); w.Code != test.want {
+				t.Errorf("status = %d, want %d", w.Code, test.want)
+			}
+		})
+	}
+}
+
+func TestLastModifiedPerRink(t *testing.T) {
+	resetAvailability(t)
+	t.Setenv("RINKS", "Wollman=wollman-experience")
+	rinks := configuredRinks()
+	lastModified(rinks[defaultRink], "2099-01-02", "aaaa")
+	lastModified(rinks["wollman"], "2099-01-02", "bbbb")
+	changes.Lock()
+	defer changes.Unlock()
+	if bp, wollman := changes.byRinkDate["bp/2099-01-02"], changes.byRinkDate["Wollman/2099-01-02"]; bp.digest != "aaaa" || wollman.digest != "bbbb" {
+		t.Errorf("bp saw %q and Wollman %q, want each rink's own digest", bp.digest, wollman.digest)
+	}
+}
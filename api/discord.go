@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discordAPI is the REST API follow-ups and command registration go to
+var discordAPI = "https://discord.com/api/v10"
+
+// interaction and response types, https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	discordPing                   = 1
+	discordApplicationCommand     = 2
+	discordPong                   = 1
+	discordChannelMessage         = 4
+	discordDeferredChannelMessage = 5
+	// discordEphemeral is the message flag for "only you can see this"
+	discordEphemeral = 64
+)
+
+// discordColors are the embed's side bar, by how the day is going (see dayUrgency)
+var discordColors = map[string]int{
+	urgencySoldOut: 0x95a5a6,
+	urgencyLow:     0xe74c3c,
+	urgencySome:    0xf1c40f,
+	urgencyPlenty:  0x2ecc71,
+	"":             0xf1c40f,
+}
+
+// discordInteraction is the part of an incoming interaction the /skate command reads
+type discordInteraction struct {
+	Type          int    `json:"type"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"`
+	Data          struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// option is a string option's value, "" when it wasn't given
+func (interaction discordInteraction) option(name string) string {
+	for _, option := range interaction.Data.Options {
+		var value string
+		if option.Name == name && json.Unmarshal(option.Value, &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+type discordResponse struct {
+	Type int                 `json:"type"`
+	Data *discordMessageData `json:"data,omitempty"`
+}
+
+type discordMessageData struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+	Flags   int            `json:"flags,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string            `json:"title"`
+	URL         string            `json:"url,omitempty"`
+	Description string            `json:"description"`
+	Color       int               `json:"color"`
+	Footer      *discordEmbedFoot `json:"footer,omitempty"`
+	Timestamp   string            `json:"timestamp,omitempty"`
+}
+
+type discordEmbedFoot struct {
+	Text string `json:"text"`
+}
+
+// validDiscordSignature checks X-Signature-Ed25519 over timestamp+body with DISCORD_PUBLIC_KEY.
+// Discord sends deliberately bad signatures now and then and drops endpoints that accept them.
+func validDiscordSignature(publicKey string, r *http.Request, body []byte) bool {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	message := append([]byte(r.Header.Get("X-Signature-Timestamp")), body...)
+	return ed25519.Verify(ed25519.PublicKey(key), message, signature)
+}
+
+// discordInteractionsHandler is the interactions endpoint of the Discord app, answering the /skate
+// command. It's a 404 until DISCORD_PUBLIC_KEY is set.
+func discordInteractionsHandler(w http.ResponseWriter, r *http.Request) {
+	publicKey := os.Getenv("DISCORD_PUBLIC_KEY")
+	if publicKey == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatBody))
+	if err != nil || !validDiscordSignature(publicKey, r, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Bad signature"))
+		return
+	}
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad interaction")
+		return
+	}
+
+	switch {
+	case interaction.Type == discordPing:
+		writeJSONResponse(w, discordResponse{Type: discordPong})
+		return
+	case interaction.Type != discordApplicationCommand || interaction.Data.Name != "skate":
+		writeJSONResponse(w, discordEphemeralReply("Unknown command"))
+		return
+	}
+
+	query, problem := parseChatQuery(interaction.option("date"))
+	if name := interaction.option("rink"); name != "" && problem == "" {
+		rk, ok := configuredRinks()[strings.ToLower(name)]
+		if !ok {
+			problem = "Unknown rink " + name
+		}
+		query.rink = rk
+	}
+	if problem != "" {
+		writeJSONResponse(w, discordEphemeralReply(problem))
+		return
+	}
+
+	// the lookup may outlive the request, see ackWithin
+	ctx := context.WithoutCancel(r.Context())
+	var reply discordResponse
+	finished, started := ackWithin(chatAckTimeout,
+		func() { reply = discordCommandReply(ctx, query) },
+		func() { editDiscordResponse(ctx, interaction, reply.Data) })
+	switch {
+	case !started:
+		writeJSONResponse(w, discordEphemeralReply("Shutting down, try again in a moment."))
+	case finished:
+		writeJSONResponse(w, reply)
+	default:
+		// Discord shows "thinking…" until the follow-up edits the original response
+		writeJSONResponse(w, discordResponse{Type: discordDeferredChannelMessage})
+	}
+}
+
+// discordCommandReply is the day as an embed: one line per session with its urgency emoji, coloured
+// by how the day is going and linking to the booking page
+func discordCommandReply(ctx context.Context, query chatQuery) discordResponse {
+	skateTimesMap, opts, err := query.lookup(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "discord command lookup failed", "date", query.date, "error", err)
+		return discordEphemeralReply("Couldn't reach the booking system, try again in a minute.")
+	}
+	keys, cleanedMap := opts.skateTimes(query.date, skateTimesMap)
+	keys = opts.filter(keys)
+
+	embed := discordEmbed{
+		Title:     query.rink.label() + " — " + query.dateObj.Format("Monday, Jan 2"),
+		URL:       bookingURL(query.rink, query.date),
+		Color:     discordColors[dayUrgency(keys, cleanedMap)],
+		Footer:    &discordEmbedFoot{Text: "Tap the title to book"},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(keys) == 0 {
+		embed.Description = emptyDayMessage(query.rink, query.dateObj)
+		embed.Color = discordColors[urgencySoldOut]
+	}
+	var lines []string
+	for _, skateTime := range keys {
+		timeObj, _ := time.Parse("1504", skateTime)
+		lines = append(lines, urgencyEmoji[urgency(cleanedMap[skateTime])]+" **"+timeObj.Format("3:04 PM")+"** — "+spotsText(cleanedMap[skateTime]))
+	}
+	if len(lines) > 0 {
+		embed.Description = strings.Join(lines, "\n")
+	}
+	return discordResponse{Type: discordChannelMessage, Data: &discordMessageData{Embeds: []discordEmbed{embed}}}
+}
+
+func discordEphemeralReply(content string) discordResponse {
+	return discordResponse{Type: discordChannelMessage, Data: &discordMessageData{Content: content, Flags: discordEphemeral}}
+}
+
+// editDiscordResponse replaces the deferred "thinking…" response with the result. The interaction
+// token authenticates it, no bot token needed.
+func editDiscordResponse(ctx context.Context, interaction discordInteraction, data *discordMessageData) {
+	url := discordAPI + "/webhooks/" + interaction.ApplicationID + "/" + interaction.Token + "/messages/@original"
+	if err := discordRequest(ctx, http.MethodPatch, url, "", data); err != nil {
+		slog.WarnContext(ctx, "discord follow-up failed", "error", err)
+	}
+}
+
+// RegisterDiscordCommand creates (or updates) the /skate command of DISCORD_APPLICATION_ID using
+// DISCORD_BOT_TOKEN, with the configured rinks as choices. The long-running server calls it at
+// startup, it's a no-op unless both are set.
+func RegisterDiscordCommand(ctx context.Context) error {
+	applicationID, token := os.Getenv("DISCORD_APPLICATION_ID"), os.Getenv("DISCORD_BOT_TOKEN")
+	if applicationID == "" || token == "" {
+		return nil
+	}
+	type choice struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type option struct {
+		Type        int      `json:"type"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Choices     []choice `json:"choices,omitempty"`
+	}
+	// type 3 is a string option
+	options := []option{{Type: 3, Name: "date", Description: "YYYY-MM-DD, tomorrow, friday… (default today)"}}
+	if multiRink() {
+		rinkOption := option{Type: 3, Name: "rink", Description: "Which rink (default " + venueName + ")"}
+		for key, rk := range configuredRinks() {
+			rinkOption.Choices = append(rinkOption.Choices, choice{Name: rk.label(), Value: key})
+		}
+		sort.Slice(rinkOption.Choices, func(i, j int) bool { return rinkOption.Choices[i].Name < rinkOption.Choices[j].Name })
+		options = append(options, rinkOption)
+	}
+	command := struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Options     []option `json:"options"`
+	}{"skate", "Open skating sessions and spots left", options}
+	return discordRequest(ctx, http.MethodPost, discordAPI+"/applications/"+applicationID+"/commands", token, command)
+}
+
+// discordRequest sends body as JSON, with the bot token when there is one
+func discordRequest(ctx context.Context, method string, url string, botToken string, body interface{}) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if botToken != "" {
+		req.Header.Set("Authorization", "Bot "+botToken)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New("discord answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
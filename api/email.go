@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// sendGridAPI is where mail goes when SENDGRID_API_KEY is set
+var sendGridAPI = "https://api.sendgrid.com/v3/mail/send"
+
+// defaultSMTPPort is the submission port, with STARTTLS
+const defaultSMTPPort = 587
+
+// defaultDigestDays is how many days from today the digest covers without DIGEST_DAYS
+const defaultDigestDays = 7
+
+// email is one message, sent as plain text with an optional HTML alternative
+type email struct {
+	to      []string
+	subject string
+	text    string
+	html    string
+}
+
+// sendEmail sends through SendGrid when SENDGRID_API_KEY is set, otherwise through SMTP_HOST. Both
+// send from EMAIL_FROM.
+func sendEmail(ctx context.Context, message email) error {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" || len(message.to) == 0 {
+		return errors.New("email isn't configured")
+	}
+	if key := os.Getenv("SENDGRID_API_KEY"); key != "" {
+		return sendGridEmail(ctx, key, from, message)
+	}
+	return smtpEmail(from, message)
+}
+
+func sendGridEmail(ctx context.Context, key string, from string, message email) error {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	var to []address
+	for _, recipient := range message.to {
+		to = append(to, address{recipient})
+	}
+	contents := []content{{"text/plain", message.text}}
+	if message.html != "" {
+		contents = append(contents, content{"text/html", message.html})
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": to}},
+		"from":             address{from},
+		"subject":          message.subject,
+		"content":          contents,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridAPI, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New("SendGrid answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// smtpEmail sends through SMTP_HOST:SMTP_PORT, logging in with SMTP_USER / SMTP_PASSWORD when set.
+// net/smtp upgrades to TLS with STARTTLS when the server offers it.
+func smtpEmail(from string, message email) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return errors.New("set SENDGRID_API_KEY or SMTP_HOST to send email")
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(envInt("SMTP_PORT", defaultSMTPPort)))
+	return smtp.SendMail(addr, auth, from, message.to, mimeMessage(from, message))
+}
+
+// mimeMessage is the message with headers, multipart/alternative when there's an HTML part
+func mimeMessage(from string, message email) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + strings.Join(message.to, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if message.html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(message.text)
+		return buf.Bytes()
+	}
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	buf.WriteString("Content-Type: multipart/alternative; boundary=" + writer.Boundary() + "\r\n\r\n")
+	for _, part := range []struct{ contentType, body string }{{"text/plain", message.text}, {"text/html", message.html}} {
+		w, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType + "; charset=utf-8"}})
+		w.Write([]byte(part.body))
+	}
+	writer.Close()
+	buf.Write(parts.Bytes())
+	return buf.Bytes()
+}
+
+// sendEmailAlert is the email alert channel, one short message per opening
+func sendEmailAlert(ctx context.Context, to string, text string) error {
+	return sendEmail(ctx, email{to: []string{to}, subject: "Skating spots opened", text: text})
+}
+
+// emailSubscribers are the addresses of the email entries in ALERT_SUBSCRIBERS, who get the digest
+func emailSubscribers() []string {
+	var to []string
+	for _, subscriber := range alertSubscribers() {
+		if subscriber.channel == "email" {
+			to = append(to, subscriber.to)
+		}
+	}
+	return to
+}
+
+// digestDates are the next DIGEST_DAYS days, only the ones in ALERT_DATES when that's set
+func digestDates() []time.Time {
+	_, today, _ := normalizeDate("")
+	var dates []time.Time
+	for _, day := range rangeDates(today, today.AddDate(0, 0, envInt("DIGEST_DAYS", defaultDigestDays)-1)) {
+		if os.Getenv("ALERT_DATES") == "" || watchedDate(day) {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+// buildDigest is the availability digest for the default rink: every digest date, as readable
+// text and the same HTML as /view
+func buildDigest(ctx context.Context) (email, error) {
+	dates := digestDates()
+	skateTimesMap, waitlists, err := queryOpenDays(ctx, dates)
+	if err != nil {
+		return email{}, err
+	}
+	rk := configuredRinks()[defaultRink]
+	var text strings.Builder
+	var days []htmlDay
+	for i, day := range dates {
+		date := day.Format("2006-01-02")
+		opts := formatOptions{rink: rk, waitlists: waitlists[date]}
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		text.WriteString(getFormattedTimes(date, day, skateTimesMap, opts).String())
+		days = append(days, newHTMLDay(date, day, skateTimesMap, opts, day.Format("Monday, Jan 2")))
+	}
+	if len(dates) == 0 {
+		text.WriteString("No watched dates in the next " + strconv.Itoa(envInt("DIGEST_DAYS", defaultDigestDays)) + " days.\n")
+	} else {
+		text.WriteString("\nBook: " + bookingURL(rk, dates[0].Format("2006-01-02")) + "\n")
+	}
+	page, err := renderHTMLPage(rk.label()+" skating", days)
+	if err != nil {
+		return email{}, err
+	}
+	return email{to: emailSubscribers(), subject: rk.label() + " skating: open sessions", text: text.String(), html: page}, nil
+}
+
+// adminDigestHandler previews the digest as HTML (GET) or sends it to the email subscribers now
+// (POST)
+func adminDigestHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to preview or POST to send")
+		return
+	}
+	digest, err := buildDigest(r.Context())
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(digest.html))
+		return
+	}
+	if len(digest.to) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "No email entries in ALERT_SUBSCRIBERS")
+		return
+	}
+	if err := sendEmail(r.Context(), digest); err != nil {
+		slog.WarnContext(r.Context(), "digest not sent", "error", err)
+		writeJSONError(w, http.StatusBadGateway, "Could not send the digest: "+err.Error())
+		return
+	}
+	writeJSONResponse(w, map[string]int{"sent": len(digest.to)})
+}
+
+// init schedules the digest when DIGEST_TIME is set. Like the refresher it needs the long-running
+// server, on Vercel have a cron job POST to /admin/digest instead.
+func init() {
+	loadConfig()
+	at, ok := xola.NormalizeSlotTime(os.Getenv("DIGEST_TIME"))
+	if os.Getenv("DIGEST_TIME") == "" || !ok {
+		return
+	}
+	go runDigestSchedule(at)
+}
+
+// nextDigestTime is the next time after now that the venue's clock reads at (HHMM)
+func nextDigestTime(now time.Time, at string) time.Time {
+	hour, _ := strconv.Atoi(at[:2])
+	minute, _ := strconv.Atoi(at[2:])
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runDigestSchedule mails the digest every day at DIGEST_TIME until Shutdown
+func runDigestSchedule(at string) {
+	slog.Info("scheduling the email digest", "at", at[:2]+":"+at[2:])
+	for {
+		select {
+		case <-time.After(time.Until(nextDigestTime(time.Now().In(venueLocation()), at))):
+		case <-background.stop:
+			return
+		}
+		goBackground(func() {
+			digest, err := buildDigest(context.Background())
+			if err == nil && len(digest.to) > 0 {
+				err = sendEmail(context.Background(), digest)
+			}
+			if err != nil {
+				slog.Warn("scheduled digest not sent", "error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envInt reads a non-negative integer from env, falling back when unset or invalid
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		slog.Warn("ignoring bad setting", "key", key, "value", value)
+		return fallback
+	}
+	return number
+}
+
+// envMinutes reads a non-negative number of minutes from env
+func envMinutes(key string, fallback int) time.Duration {
+	return time.Duration(envInt(key, fallback)) * time.Minute
+}
+
+// envMilliseconds reads a non-negative number of milliseconds from env
+func envMilliseconds(key string, fallback int) time.Duration {
+	return time.Duration(envInt(key, fallback)) * time.Millisecond
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// extremes is the ?extremes=1 response: the session with the most spots left and the one with the fewest
+type extremes struct {
+	Date     string    `json:"date"`
+	Quietest *timeSlot `json:"quietest"`
+	Fullest  *timeSlot `json:"fullest"`
+}
+
+// pickExtremes finds the quietest (most spots) and fullest (fewest spots, still open) sessions in
+// one pass over the sorted keys. Ties go to the earliest session. Both are "" when nothing is open.
+func pickExtremes(keys []string, cleanedMap map[string]int) (string, string) {
+	var quietest, fullest string
+	for _, skateTime := range keys {
+		count := cleanedMap[skateTime]
+		if count <= 0 {
+			continue
+		}
+		if quietest == "" || count > cleanedMap[quietest] {
+			quietest = skateTime
+		}
+		if fullest == "" || count < cleanedMap[fullest] {
+			fullest = skateTime
+		}
+	}
+	return quietest, fullest
+}
+
+func buildExtremes(date string, skateTimesMap map[string]map[string]int, opts formatOptions) extremes {
+	keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+	quietest, fullest := pickExtremes(opts.filter(keys), cleanedMap)
+
+	return extremes{
+		Date:     date,
+		Quietest: findSlot(date, skateTimesMap, opts, quietest),
+		Fullest:  findSlot(date, skateTimesMap, opts, fullest),
+	}
+}
+
+// formatExtremes is the text form, e.g. "Quietest: 10 AM (12 spots); Fullest: 6 PM (1 spot)"
+func formatExtremes(dateObj time.Time, keys []string, cleanedMap map[string]int) string {
+	quietest, fullest := pickExtremes(keys, cleanedMap)
+	if quietest == "" {
+		return "For " + dateObj.Format("Jan 2, 2006") + ": Sold out"
+	}
+	return "Quietest: " + spokenTime(quietest) + " (" + extremeSpots(cleanedMap[quietest]) + "); " +
+		"Fullest: " + spokenTime(fullest) + " (" + extremeSpots(cleanedMap[fullest]) + ")"
+}
+
+func extremeSpots(count int) string {
+	if isLimited(count) {
+		return "limited spots"
+	}
+	return pluralize(count, "spot")
+}
+
+func writeExtremes(w http.ResponseWriter, r *http.Request, date string, dateObj time.Time, skateTimesMap map[string]map[string]int, opts formatOptions) {
+	format, ok := endpointFormat(w, r, "text", "json", "yaml")
+	if !ok {
+		return
+	}
+	switch format {
+	case "json":
+		writeJSONResponse(w, buildExtremes(date, skateTimesMap, opts))
+	case "yaml":
+		writeYAMLResponse(w, buildExtremes(date, skateTimesMap, opts))
+	default:
+		keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+		var sb strings.Builder
+		sb.WriteString(formatExtremes(dateObj, opts.filter(keys), cleanedMap) + "\n")
+		writeSuccessResponse(w, &sb)
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestExtremesText(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"distinct", `{"2024-01-02": {"1000": 12, "1400": 5, "1800": 1}}`, "Quietest: 10 AM (12 spots); Fullest: 6 PM (1 spot)\n"},
+		{"ties go to the earliest", `{"2024-01-02": {"1000": 4, "1200": 9, "1400": 9, "1600": 4}}`, "Quietest: 12 PM (9 spots); Fullest: 10 AM (4 spots)\n"},
+		{"sold out sessions aren't the fullest", `{"2024-01-02": {"1000": 0, "1130": 3, "1400": 7}}`, "Quietest: 2 PM (7 spots); Fullest: 11:30 AM (3 spots)\n"},
+		{"one session is both", `{"2024-01-02": {"1500": 4}}`, "Quietest: 3 PM (4 spots); Fullest: 3 PM (4 spots)\n"},
+		{"sold out", `{"2024-01-02": {"1500": 0}}`, "For Jan 2, 2024: Sold out\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, test.payload)
+			if w := get(t, "/api?date=2024-01-02&extremes=1"); w.Body.String() != test.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestExtremesJSON(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1000": 12, "1400": 5, "1800": 1}}`)
+	var body extremes
+	w := get(t, "/api?date=2024-01-02&extremes=1&format=json")
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Quietest == nil || body.Fullest == nil {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	if body.Quietest.Time != "10:00" || body.Quietest.Spots != 12 || body.Fullest.Time != "18:00" || body.Fullest.Spots != 1 {
+		t.Errorf("quietest %+v, fullest %+v", body.Quietest, body.Fullest)
+	}
+
+	newXolaStub(t, `{"2024-01-02": {}}`)
+	if w := get(t, "/api?date=2024-01-02&extremes=1&format=json"); w.Body.String() != `{"date":"2024-01-02","quietest":null,"fullest":null}` {
+		t.Errorf("sold out = %s", w.Body.String())
+	}
+}
+
+func TestExtremesNotAcceptable(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1000": 12}}`)
+	if w := get(t, "/api?date=2024-01-02&extremes=1&format=csv"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("status = %d, want 406 for a format extremes can't render", w.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+func getFormattedTimes(date string, dateObj time.Time, skateTimesMap map[string]map[string]int, opts formatOptions) *strings.Builder {
+	keys, skateTimesMapNoZeroValues := opts.skateTimes(date, skateTimesMap)
+	keys = opts.filter(keys)
+	return formatSkateTimes(dateObj, keys, skateTimesMapNoZeroValues, opts)
+}
+
+// formatSkateTimes renders into a new builder on every call. Builders must not be shared between
+// requests, and a strings.Builder can't safely be copied once written to, so it's returned by pointer.
+func formatSkateTimes(dateObj time.Time, keys []string, cleanedMap map[string]int, opts formatOptions) *strings.Builder {
+	sb := &strings.Builder{}
+	switch {
+	case !opts.relativeTo.IsZero():
+		sb.WriteString(relativeHeader(opts.rink.label(), dateObj, opts.relativeTo) + "\n")
+	case multiRink():
+		sb.WriteString(opts.rink.label() + " — " + dateObj.Format("Jan 2, 2006") + ":\n")
+	default:
+		sb.WriteString("For " + dateObj.Format("Jan 2, 2006") + ":\n")
+	}
+	// overnight grace window: yesterday's sessions that haven't finished yet
+	if opts.previousDate != "" {
+		previousKeys, previousMap := xola.CleanSkateTimes(opts.previousDate, opts.previousTimes)
+		previousDateObj, _ := time.Parse("2006-01-02", opts.previousDate)
+		for _, skateTime := range previousKeys {
+			timeObj, _ := time.Parse("1504", skateTime)
+			sb.WriteString(timeObj.Format("3:04 PM") + " (" + previousDateObj.Format("Jan 2") + ") has " + spotsText(previousMap[skateTime]) + "\n")
+		}
+	}
+	// Xola returns an empty schedule both off-season and when everything is taken
+	if len(keys) == 0 {
+		message := emptyDayMessage(opts.rink, dateObj)
+		if opts.emoji && message == "Sold out" {
+			message = urgencyEmoji[urgencySoldOut] + " " + message
+		}
+		sb.WriteString(message + "\n")
+	} else if opts.emoji {
+		if flag := dayFlag(keys, cleanedMap); flag != "" {
+			sb.WriteString(flag + "\n")
+		}
+	}
+	// iterate by sorted keys
+	accessible := accessibleSessions()
+	// surfaces are only worth spelling out in text when the venue has indoor sessions at all
+	indoor := sessionTimes("INDOOR_SESSIONS")
+	var section string
+	for _, skateTime := range keys {
+		// keys are sorted, so each daypart header is written once just before its first session
+		if opts.grouped && daypart(skateTime) != section {
+			section = daypart(skateTime)
+			sb.WriteString(section + "\n")
+		}
+		timeObj, _ := time.Parse("1504", skateTime)
+		if opts.emoji {
+			sb.WriteString(urgencyEmoji[urgency(cleanedMap[skateTime])] + " ")
+		}
+		var suffix string
+		if len(indoor) > 0 {
+			suffix = " (" + slotSurface(skateTime, indoor) + ")"
+		}
+		if accessible[skateTime] {
+			suffix += " (accessible)"
+		}
+		if cleanedMap[skateTime] == 0 {
+			if opts.waitlists[skateTime] {
+				suffix += " (waitlist open)"
+			}
+			sb.WriteString(timeObj.Format("3:04 PM") + " SOLD OUT" + suffix + "\n")
+			continue
+		}
+		if opts.price != "" {
+			sb.WriteString(timeObj.Format("3:04 PM") + " — from " + opts.price + " — " + spotsText(cleanedMap[skateTime]) + suffix + "\n")
+			continue
+		}
+		sb.WriteString(timeObj.Format("3:04 PM") + " has " + spotsText(cleanedMap[skateTime]) + suffix + "\n")
+	}
+	return sb
+}
+
+// emptyDayMessage is what a day with no sessions to list says: closed, out of season or sold out
+func emptyDayMessage(rk rink, dateObj time.Time) string {
+	if isClosedDay(rk, dateObj) {
+		return "Closed"
+	}
+	if message := outOfSeasonMessage(dateObj); message != "" {
+		return message
+	}
+	return "Sold out"
+}
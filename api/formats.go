@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// supportedFormats are every output format the handler can render
+var supportedFormats = []string{"text", "json", "yaml", "voice", "csv", "html", "slack", "ics", "shortcut", "homeassistant"}
+
+// defaultEnabledFormats are the lightweight formats served when ENABLED_FORMATS is unset
+var defaultEnabledFormats = []string{"text", "json", "csv"}
+
+// enabledFormats reads ENABLED_FORMATS (comma-separated), keeping only formats we know how to render
+func enabledFormats() []string {
+	configured := os.Getenv("ENABLED_FORMATS")
+	if configured == "" {
+		return defaultEnabledFormats
+	}
+	var enabled []string
+	for _, format := range strings.Split(configured, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		for _, supported := range supportedFormats {
+			if format == supported {
+				enabled = append(enabled, format)
+			}
+		}
+	}
+	return enabled
+}
+
+func formatEnabled(format string) bool {
+	for _, enabled := range enabledFormats() {
+		if format == enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotAcceptable is the 406 for a disabled or unknown format, listing what is available
+func writeNotAcceptable(w http.ResponseWriter, format string) {
+	writeFormatsNotAcceptable(w, format, enabledFormats())
+}
+
+func writeFormatsNotAcceptable(w http.ResponseWriter, format string, available []string) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusNotAcceptable)
+	w.Write([]byte("Format " + format + " is not available. Supported formats: " + strings.Join(available, ", ")))
+}
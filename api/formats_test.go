@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEnabledFormats(t *testing.T) {
+	tests := []struct {
+		configured string
+		want       string
+	}{
+		{"", "text,json,csv"},
+		{"text,json", "text,json"},
+		{" JSON , yaml", "json,yaml"},
+		{"text,xml", "text"},
+	}
+	for _, test := range tests {
+		t.Setenv("ENABLED_FORMATS", test.configured)
+		if got := strings.Join(enabledFormats(), ","); got != test.want {
+			t.Errorf("enabledFormats() with %q = %q, want %q", test.configured, got, test.want)
+		}
+	}
+}
+
+func TestDisabledFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled string
+		target  string
+		header  []string
+		want    int
+		body    string
+	}{
+		{"csv on by default", "", "/api?date=2024-01-02&format=csv", nil, http.StatusOK, "15:00"},
+		{"yaml off by default", "", "/api?date=2024-01-02&format=yaml", nil, http.StatusNotAcceptable,
+			"Format yaml is not available. Supported formats: text, json, csv"},
+		{"voice off by default", "", "/api?date=2024-01-02&format=voice", nil, http.StatusNotAcceptable,
+			"Format voice is not available. Supported formats: text, json, csv"},
+		{"yaml turned on", "text,yaml", "/api?date=2024-01-02&format=yaml", nil, http.StatusOK, "date: \"2024-01-02\"\n"},
+		{"enabled format", "text,json", "/api?date=2024-01-02&format=json", nil, http.StatusOK, `"date":"2024-01-02"`},
+		{"disabled format", "text,json", "/api?date=2024-01-02&format=yaml", nil, http.StatusNotAcceptable,
+			"Format yaml is not available. Supported formats: text, json"},
+		{"disabled Accept", "text,json", "/api?date=2024-01-02", []string{"Accept", "application/x-yaml"}, http.StatusNotAcceptable,
+			"Format yaml is not available. Supported formats: text, json"},
+		{"unknown format", "", "/api?date=2024-01-02&format=xml", nil, http.StatusNotAcceptable,
+			"Format xml is not available. Supported formats: text, json, csv"},
+		{"endpoint lists only what it renders", "", "/api/week?format=csv", nil, http.StatusNotAcceptable,
+			"Format csv is not available. Supported formats: text, json"},
+		{"endpoint drops disabled formats", "text,yaml", "/api/week?format=json", nil, http.StatusNotAcceptable,
+			"Format json is not available. Supported formats: text, yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+			t.Setenv("ENABLED_FORMATS", test.enabled)
+			w := get(t, test.target, test.header...)
+			if w.Code != test.want || !strings.Contains(w.Body.String(), test.body) {
+				t.Errorf("status %d, body %q, want %d with %q", w.Code, w.Body.String(), test.want, test.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// Google endpoints, variables so they can point at a fake in dev
+var (
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	googleCalendarAPI = "https://www.googleapis.com/calendar/v3"
+)
+
+// googleClient has its own short timeout like webhookClient, the sync runs inside a refresh
+var googleClient = &http.Client{Timeout: 5 * time.Second}
+
+// googleCalendarEnabled is whether the OAuth client, its refresh token and the flagged sessions
+// (GOOGLE_CALENDAR_SESSIONS) are all configured
+func googleCalendarEnabled() bool {
+	return os.Getenv("GOOGLE_CLIENT_ID") != "" && os.Getenv("GOOGLE_CLIENT_SECRET") != "" &&
+		os.Getenv("GOOGLE_REFRESH_TOKEN") != "" && os.Getenv("GOOGLE_CALENDAR_SESSIONS") != ""
+}
+
+// googleCalendarID is GOOGLE_CALENDAR_ID, the account's main calendar by default
+func googleCalendarID() string {
+	if id := os.Getenv("GOOGLE_CALENDAR_ID"); id != "" {
+		return id
+	}
+	return "primary"
+}
+
+// googleToken is the cached access token, refreshed shortly before it expires
+var googleToken struct {
+	sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// googleAccessToken trades GOOGLE_REFRESH_TOKEN for an access token, reusing it until it's about to expire
+func googleAccessToken() (string, error) {
+	googleToken.Lock()
+	defer googleToken.Unlock()
+	if googleToken.value != "" && time.Now().Before(googleToken.expires) {
+		return googleToken.value, nil
+	}
+	res, err := googleClient.PostForm(googleTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {os.Getenv("GOOGLE_CLIENT_ID")},
+		"client_secret": {os.Getenv("GOOGLE_CLIENT_SECRET")},
+		"refresh_token": {os.Getenv("GOOGLE_REFRESH_TOKEN")},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", errors.New("Google token refresh failed: " + res.Status + " " + token.Error)
+	}
+	googleToken.value = token.AccessToken
+	googleToken.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return googleToken.value, nil
+}
+
+// calendarEvent is the part of a Calendar API event we write
+type calendarEvent struct {
+	ID          string        `json:"id"`
+	Summary     string        `json:"summary"`
+	Description string        `json:"description"`
+	Location    string        `json:"location"`
+	Start       calendarTime  `json:"start"`
+	End         calendarTime  `json:"end"`
+	Source      *eventSource  `json:"source,omitempty"`
+	Reminders   eventReminder `json:"reminders"`
+}
+
+type calendarTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type eventSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type eventReminder struct {
+	UseDefault bool `json:"useDefault"`
+}
+
+// syncedSpots is the count last written per event, so unchanged sessions aren't written again
+var syncedSpots = struct {
+	sync.Mutex
+	byEvent map[string]int
+}{byEvent: map[string]int{}}
+
+// syncGoogleCalendar writes the date's flagged sessions (GOOGLE_CALENDAR_SESSIONS) to the calendar,
+// one event per session kept up to date with its spots. Sessions Xola doesn't list aren't touched.
+func syncGoogleCalendar(rk rink, date string, skateTimesMap map[string]map[string]int) {
+	if !googleCalendarEnabled() {
+		return
+	}
+	flagged := sessionTimes("GOOGLE_CALENDAR_SESSIONS")
+	_, cleanedMap := xola.SortedSkateTimes(date, skateTimesMap, true)
+	dateObj, _ := time.Parse("2006-01-02", date)
+	sessionLength := envMinutes("SESSION_MINUTES", defaultSessionMinutes)
+
+	for skateTime, spots := range cleanedMap {
+		if !flagged[skateTime] {
+			continue
+		}
+		timeObj, _ := time.Parse("1504", skateTime)
+		start := time.Date(dateObj.Year(), dateObj.Month(), dateObj.Day(), timeObj.Hour(), timeObj.Minute(), 0, 0, venueLocation())
+		session := feedSession{rink: rk, date: date, start: start, end: start.Add(sessionLength), spots: spots}
+		id := calendarEventID(session)
+
+		syncedSpots.Lock()
+		last, synced := syncedSpots.byEvent[id]
+		syncedSpots.Unlock()
+		if synced && last == spots {
+			continue
+		}
+		if err := writeCalendarEvent(id, session); err != nil {
+			slog.Warn("Google Calendar sync failed", "date", date, "time", skateTime, "error", err)
+			continue
+		}
+		syncedSpots.Lock()
+		syncedSpots.byEvent[id] = spots
+		syncedSpots.Unlock()
+	}
+}
+
+// calendarEventID is stable per session, so it's updated in place. Google allows a-v and 0-9,
+// hex fits.
+func calendarEventID(session feedSession) string {
+	sum := sha1.Sum([]byte(session.rink.name + "/" + session.start.Format(time.RFC3339)))
+	return "bpskate" + hex.EncodeToString(sum[:])
+}
+
+// writeCalendarEvent updates the event, or creates it the first time
+func writeCalendarEvent(id string, session feedSession) error {
+	token, err := googleAccessToken()
+	if err != nil {
+		return err
+	}
+	booking := bookingURL(session.rink, session.date)
+	event := calendarEvent{
+		ID:          id,
+		Summary:     session.summary(),
+		Description: session.summary() + " as of " + time.Now().In(venueLocation()).Format("Jan 2 3:04 PM") + ".\nBook: " + booking,
+		Location:    session.rink.label(),
+		Start:       calendarTime{DateTime: session.start.Format(time.RFC3339), TimeZone: venueTimezone},
+		End:         calendarTime{DateTime: session.end.Format(time.RFC3339), TimeZone: venueTimezone},
+		Source:      &eventSource{Title: "Book", URL: booking},
+		// spot counts change all day, only the calendar's own reminders make sense
+		Reminders: eventReminder{UseDefault: true},
+	}
+	body, _ := json.Marshal(event)
+	events := googleCalendarAPI + "/calendars/" + url.PathEscape(googleCalendarID()) + "/events"
+
+	status, err := googleRequest(http.MethodPut, events+"/"+id, token, body)
+	if err == nil && status == http.StatusNotFound {
+		status, err = googleRequest(http.MethodPost, events, token, body)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return errors.New("Google Calendar returned status " + http.StatusText(status))
+	}
+	return nil
+}
+
+func googleRequest(method string, target string, token string, body []byte) (int, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := googleClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		// revoked or expired early, fetch a new one next time
+		googleToken.Lock()
+		googleToken.value = ""
+		googleToken.Unlock()
+	}
+	return res.StatusCode, nil
+}
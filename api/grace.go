@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// defaultSessionMinutes is how long a skating session runs when SESSION_MINUTES is unset
+const defaultSessionMinutes = 60
+
+// lateSessions is the overnight grace window for 24h displays: for the default "now" request (no
+// date given, see dateRequested) made within MIDNIGHT_GRACE_MINUTES after midnight, it returns
+// yesterday's date and just its sessions that are still running. Outside the window (or when it's
+// unset) the date is "".
+func lateSessions(ctx context.Context, date string, now time.Time) (string, map[string]map[string]int) {
+	grace := envMinutes("MIDNIGHT_GRACE_MINUTES", 0)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if grace == 0 || date != now.Format("2006-01-02") || now.Sub(midnight) > grace {
+		return "", nil
+	}
+
+	prevDate := midnight.AddDate(0, 0, -1).Format("2006-01-02")
+	prevMap, _, err := querySkateTimesAPI(ctx, prevDate)
+	if err != nil {
+		// yesterday's tail is a nice-to-have, don't fail today's response over it
+		return "", nil
+	}
+	keys, cleanedMap := xola.CleanSkateTimes(prevDate, prevMap)
+
+	sessionLength := envMinutes("SESSION_MINUTES", defaultSessionMinutes)
+	yesterday := midnight.AddDate(0, 0, -1)
+	running := map[string]int{}
+	for _, skateTime := range keys {
+		timeObj, _ := time.Parse("1504", skateTime)
+		start := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), timeObj.Hour(), timeObj.Minute(), 0, 0, now.Location())
+		if start.Add(sessionLength).After(now) {
+			running[skateTime] = cleanedMap[skateTime]
+		}
+	}
+	if len(running) == 0 {
+		return "", nil
+	}
+	return prevDate, map[string]map[string]int{prevDate: running}
+}
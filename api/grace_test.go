@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLateSessions(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   string
+		payload string
+		date    string
+		now     string
+		want    string
+		running map[string]int
+	}{
+		{"still running after midnight", "30", `{"2024-01-02": {"2300": 4, "2330": 2}}`, "2024-01-03", "00:20", "2024-01-02", map[string]int{"2330": 2}},
+		{"past the grace window", "30", `{"2024-01-02": {"2330": 2}}`, "2024-01-03", "00:45", "", nil},
+		{"grace unset", "", `{"2024-01-02": {"2330": 2}}`, "2024-01-03", "00:20", "", nil},
+		{"another date", "30", `{"2024-01-02": {"2330": 2}}`, "2024-01-04", "00:20", "", nil},
+		{"nothing still running", "30", `{"2024-01-02": {"2300": 4}}`, "2024-01-03", "00:20", "", nil},
+		{"sold out", "30", `{"2024-01-02": {"2330": 0}}`, "2024-01-03", "00:20", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, test.payload)
+			t.Setenv("MIDNIGHT_GRACE_MINUTES", test.grace)
+			now, err := time.Parse("2006-01-02 15:04", "2024-01-03 "+test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			date, skateTimesMap := lateSessions(context.Background(), test.date, now)
+			if date != test.want || fmt.Sprint(skateTimesMap[date]) != fmt.Sprint(test.running) {
+				t.Errorf("lateSessions() = %q, %v, want %q, %v", date, skateTimesMap[date], test.want, test.running)
+			}
+		})
+	}
+}
+
+func TestLateSessionsOnlyWithoutDate(t *testing.T) {
+	now := time.Now().In(venueLocation())
+	today, yesterday := now.Format("2006-01-02"), now.AddDate(0, 0, -1).Format("2006-01-02")
+	newXolaStub(t, `{"`+yesterday+`": {"2330": 2}, "`+today+`": {"1500": 4}}`)
+	// a window and sessions long enough that it's always open, whenever the test runs
+	t.Setenv("MIDNIGHT_GRACE_MINUTES", "1440")
+	t.Setenv("SESSION_MINUTES", "2880")
+	late := "11:30 PM (" + now.AddDate(0, 0, -1).Format("Jan 2") + ") has 2 spots\n"
+
+	if w := get(t, "/api"); !strings.Contains(w.Body.String(), late) {
+		t.Errorf("body = %q, want yesterday's late session %q", w.Body.String(), late)
+	}
+	if w := get(t, "/api?date="+today); strings.Contains(w.Body.String(), "11:30 PM") {
+		t.Errorf("body = %q, an explicit date shouldn't pick up yesterday", w.Body.String())
+	}
+}
@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The /graphql endpoint answers this schema. It's a small hand-rolled executor, enough for
+// queries with arguments, variables and aliases (no fragments, mutations or introspection),
+// so it doesn't pull in a GraphQL library.
+//
+//	type Query   { rinks: [Rink!]!  rink(name: String): Rink }
+//	type Rink    { name: String!  label: String!  day(date: String): Day  days(start: String, end: String): [Day!]! }
+//	type Day     { date: String!  closed: Boolean!  totalSpots: Int!  sessions(includeSoldOut: Boolean): [Session!]! }
+//	type Session { time: String!  spots: Int!  limited: Boolean!  surface: String!  accessible: Boolean!  waitlistAvailable: Boolean! }
+
+// gqlField is one selected field, with its arguments already resolved against the variables
+type gqlField struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	selections []gqlField
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   *gqlObject     `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// graphQLHandler takes {"query", "variables"} as a POST body, or ?query= on a GET
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, `Expected {"query": "...", "variables": {...}}`)
+			return
+		}
+	} else {
+		req.Query = r.URL.Query().Get("query")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			json.Unmarshal([]byte(variables), &req.Variables)
+		}
+	}
+
+	fields, err := parseGraphQL(req.Query, req.Variables)
+	if err != nil {
+		writeJSONResponse(w, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+	data, err := resolveQuery(r.Context(), fields)
+	if err != nil {
+		writeJSONResponse(w, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+	writeJSONResponse(w, graphQLResponse{Data: data})
+}
+
+func resolveQuery(ctx context.Context, fields []gqlField) (*gqlObject, error) {
+	return resolveObject("Query", fields, func(field gqlField) (interface{}, error) {
+		switch field.name {
+		case "rinks":
+			var rinks []interface{}
+			for _, rk := range configuredRinks() {
+				resolved, err := resolveRink(ctx, rk, field.selections)
+				if err != nil {
+					return nil, err
+				}
+				rinks = append(rinks, resolved)
+			}
+			return rinks, nil
+		case "rink":
+			rk := rinkFromContext(ctx)
+			if name, ok := field.args["name"].(string); ok {
+				rk, ok = configuredRinks()[strings.ToLower(name)]
+				if !ok {
+					return nil, nil
+				}
+			}
+			return resolveRink(ctx, rk, field.selections)
+		}
+		return nil, errUnknownField
+	})
+}
+
+func resolveRink(ctx context.Context, rk rink, fields []gqlField) (*gqlObject, error) {
+	ctx = withRink(ctx, rk)
+	return resolveObject("Rink", fields, func(field gqlField) (interface{}, error) {
+		switch field.name {
+		case "name":
+			return rk.name, nil
+		case "label":
+			return rk.label(), nil
+		case "day", "days":
+			start, _ := field.args["date"].(string)
+			end := start
+			if field.name == "days" {
+				start, _ = field.args["start"].(string)
+				end, _ = field.args["end"].(string)
+			}
+			days, err := resolveDays(ctx, start, end, field.selections)
+			if err != nil || field.name == "days" {
+				return days, err
+			}
+			return days[0], nil
+		}
+		return nil, errUnknownField
+	})
+}
+
+// resolveDays fetches start..end in one range lookup, both default to today
+func resolveDays(ctx context.Context, start string, end string, fields []gqlField) ([]interface{}, error) {
+	today := time.Now().In(venueLocation()).Format("2006-01-02")
+	if start == "" {
+		start = today
+	}
+	if end == "" {
+		end = start
+	}
+	_, startObj, err := normalizeDate(start)
+	if err != nil {
+		return nil, errors.New("bad date " + start)
+	}
+	_, endObj, err := normalizeDate(end)
+	if err != nil || endObj.Before(startObj) {
+		return nil, errors.New("bad end date " + end)
+	}
+	dates := rangeDates(startObj, endObj)
+	if len(dates) > maxRangeDays {
+		return nil, errors.New("date range is limited to " + strconv.Itoa(maxRangeDays) + " days")
+	}
+	skateTimesMap, waitlists, err := queryOpenDays(ctx, dates)
+	if err != nil {
+		return nil, errors.New("could not get availability from Xola")
+	}
+
+	var days []interface{}
+	for _, day := range dates {
+		date := day.Format("2006-01-02")
+		resolved, err := resolveObject("Day", fields, func(field gqlField) (interface{}, error) {
+			switch field.name {
+			case "date":
+				return date, nil
+			case "closed":
+				return isClosedDay(rinkFromContext(ctx), day), nil
+			case "totalSpots":
+				return summarizeDay(day, skateTimesMap, formatOptions{}).TotalSpots, nil
+			case "sessions":
+				opts := formatOptions{rink: rinkFromContext(ctx), waitlists: waitlists[date]}
+				opts.includeSoldOut, _ = field.args["includeSoldOut"].(bool)
+				var sessions []interface{}
+				for _, slot := range buildAvailability(date, skateTimesMap, opts).Slots {
+					resolved, err := resolveSession(slot, field.selections)
+					if err != nil {
+						return nil, err
+					}
+					sessions = append(sessions, resolved)
+				}
+				return sessions, nil
+			}
+			return nil, errUnknownField
+		})
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, resolved)
+	}
+	return days, nil
+}
+
+func resolveSession(slot timeSlot, fields []gqlField) (*gqlObject, error) {
+	return resolveObject("Session", fields, func(field gqlField) (interface{}, error) {
+		switch field.name {
+		case "time":
+			return slot.Time, nil
+		case "spots":
+			return slot.Spots, nil
+		case "limited":
+			return slot.Limited, nil
+		case "surface":
+			return slot.Surface, nil
+		case "accessible":
+			return slot.Accessible, nil
+		case "waitlistAvailable":
+			return slot.WaitlistAvailable, nil
+		}
+		return nil, errUnknownField
+	})
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// gqlObject is a resolved object, it marshals its fields in the order they were selected
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (object *gqlObject) set(key string, value interface{}) {
+	if _, ok := object.values[key]; !ok {
+		object.keys = append(object.keys, key)
+	}
+	object.values[key] = value
+}
+
+func (object *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range object.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(object.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(key) + ":")
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// resolveObject resolves each selected field, keyed by its alias. Object fields need a selection
+// set and scalars mustn't have one, which the resolvers check by type.
+func resolveObject(typeName string, fields []gqlField, resolve func(gqlField) (interface{}, error)) (*gqlObject, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("a selection of fields is required on " + typeName)
+	}
+	result := &gqlObject{values: map[string]interface{}{}}
+	for _, field := range fields {
+		if field.name == "__typename" {
+			result.set(field.alias, typeName)
+			continue
+		}
+		value, err := resolve(field)
+		if err == errUnknownField {
+			return nil, errors.New(`cannot query field "` + field.name + `" on type ` + typeName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.set(field.alias, value)
+	}
+	return result, nil
+}
+
+// parseGraphQL parses a query document into the selected fields of its one operation
+func parseGraphQL(query string, variables map[string]interface{}) ([]gqlField, error) {
+	p := &gqlParser{tokens: lexGraphQL(query), variables: variables}
+	if p.peek() == "query" {
+		p.next()
+		if p.peek() != "{" && p.peek() != "(" {
+			p.next() // operation name
+		}
+		if p.peek() == "(" {
+			// variable definitions, the values come from variables as they are
+			for p.peek() != ")" && p.peek() != "" {
+				p.next()
+			}
+			p.next()
+		}
+	} else if p.peek() == "mutation" || p.peek() == "subscription" {
+		return nil, errors.New("only queries are supported")
+	}
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, errors.New("only one operation per request is supported")
+	}
+	return fields, nil
+}
+
+type gqlParser struct {
+	tokens    []string
+	pos       int
+	variables map[string]interface{}
+}
+
+func (p *gqlParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *gqlParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return errors.New("syntax error: expected " + token + ", got " + strconv.Quote(got))
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []gqlField
+	for p.peek() != "}" {
+		if p.peek() == "" || p.peek() == "..." {
+			return nil, errors.New("syntax error: unexpected " + strconv.Quote(p.peek()))
+		}
+		field := gqlField{name: p.next(), args: map[string]interface{}{}}
+		field.alias = field.name
+		if p.peek() == ":" {
+			p.next()
+			field.name = p.next()
+		}
+		if p.peek() == "(" {
+			p.next()
+			for p.peek() != ")" {
+				name := p.next()
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				value, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				field.args[name] = value
+			}
+			p.next()
+		}
+		if p.peek() == "{" {
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			field.selections = selections
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+	return fields, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	token := p.next()
+	switch {
+	case token == "$":
+		return p.variables[p.next()], nil
+	case strings.HasPrefix(token, `"`):
+		return strconv.Unquote(token)
+	case token == "true" || token == "false":
+		return token == "true", nil
+	case token == "null":
+		return nil, nil
+	}
+	if number, err := strconv.ParseFloat(token, 64); err == nil {
+		return number, nil
+	}
+	return nil, errors.New("syntax error: unsupported value " + strconv.Quote(token))
+}
+
+// lexGraphQL splits a document into names, numbers, quoted strings and punctuation, dropping
+// whitespace, commas and # comments (GraphQL treats commas as whitespace)
+func lexGraphQL(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			j := i + 1
+			for j < len(query) && query[j] != '"' {
+				if query[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		case strings.HasPrefix(query[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("{}():$!=[]@", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(query) && strings.IndexByte(" \t\n\r,#\"{}():$!=[]@", query[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
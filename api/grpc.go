@@ -0,0 +1,152 @@
+//go:build grpc
+
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/proto/skatepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServeGRPC serves the Availability service from skate.proto on addr until it fails, or until ctx
+// is done, then lets the calls in flight finish and returns nil
+func ServeGRPC(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcUnaryAuth), grpc.StreamInterceptor(grpcStreamAuth))
+	skatepb.RegisterAvailabilityServer(server, availabilityServer{})
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	return server.Serve(listener)
+}
+
+type availabilityServer struct {
+	skatepb.UnimplementedAvailabilityServer
+}
+
+func (availabilityServer) ListRinks(ctx context.Context, req *skatepb.ListRinksRequest) (*skatepb.ListRinksResponse, error) {
+	resp := &skatepb.ListRinksResponse{}
+	for _, rk := range configuredRinks() {
+		resp.Rinks = append(resp.Rinks, &skatepb.Rink{Name: rk.name, Label: rk.label()})
+	}
+	return resp, nil
+}
+
+func (availabilityServer) GetDay(ctx context.Context, req *skatepb.GetDayRequest) (*skatepb.Day, error) {
+	var day *skatepb.Day
+	err := grpcDays(ctx, req.Rink, req.Date, req.Date, req.IncludeSoldOut, func(d *skatepb.Day) error {
+		day = d
+		return nil
+	})
+	return day, err
+}
+
+func (availabilityServer) GetDays(req *skatepb.GetDaysRequest, stream skatepb.Availability_GetDaysServer) error {
+	return grpcDays(stream.Context(), req.Rink, req.Start, req.End, req.IncludeSoldOut, stream.Send)
+}
+
+// grpcDays looks up start..end (both default to today) the way /api?endDate= does and hands
+// each day to send in order
+func grpcDays(ctx context.Context, rinkName string, start string, end string, includeSoldOut bool, send func(*skatepb.Day) error) error {
+	rk := rinkFromContext(ctx)
+	if rinkName != "" {
+		var ok bool
+		if rk, ok = configuredRinks()[strings.ToLower(rinkName)]; !ok {
+			return status.Error(codes.NotFound, "unknown rink "+rinkName)
+		}
+	}
+	ctx = withRink(ctx, rk)
+
+	if start == "" {
+		start = time.Now().In(venueLocation()).Format("2006-01-02")
+	}
+	if end == "" {
+		end = start
+	}
+	_, startObj, err := normalizeDate(start)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "bad date "+start)
+	}
+	_, endObj, err := normalizeDate(end)
+	if err != nil || endObj.Before(startObj) {
+		return status.Error(codes.InvalidArgument, "bad end date "+end)
+	}
+	dates := rangeDates(startObj, endObj)
+	if len(dates) > maxRangeDays {
+		return status.Errorf(codes.InvalidArgument, "date range is limited to %d days", maxRangeDays)
+	}
+	skateTimesMap, waitlists, err := queryOpenDays(ctx, dates)
+	if err != nil {
+		return status.Error(codes.Unavailable, "could not get availability from Xola")
+	}
+
+	for _, dayObj := range dates {
+		date := dayObj.Format("2006-01-02")
+		opts := formatOptions{rink: rk, waitlists: waitlists[date], includeSoldOut: includeSoldOut}
+		day := &skatepb.Day{
+			Rink:       rk.name,
+			Date:       date,
+			Closed:     isClosedDay(rk, dayObj),
+			TotalSpots: int32(summarizeDay(dayObj, skateTimesMap, formatOptions{}).TotalSpots),
+		}
+		for _, slot := range buildAvailability(date, skateTimesMap, opts).Slots {
+			day.Sessions = append(day.Sessions, &skatepb.Session{
+				Time:              slot.Time,
+				Spots:             int32(slot.Spots),
+				Limited:           slot.Limited,
+				Surface:           slot.Surface,
+				Accessible:        slot.Accessible,
+				WaitlistAvailable: slot.WaitlistAvailable,
+			})
+		}
+		if err := send(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grpcAuthorized checks the call's metadata like authorized checks HTTP headers, so the same API
+// keys (or JWTs) work, sent as e.g. `token` metadata. AUTH_MODE=hmac isn't supported over gRPC.
+func grpcAuthorized(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := (&http.Request{Header: http.Header{}, URL: &url.URL{}}).WithContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	r, ok := authorized(r)
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "Forbidden")
+	}
+	return r.Context(), nil
+}
+
+func grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := grpcAuthorized(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, req)
+}
+
+func grpcStreamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	if _, err := grpcAuthorized(stream.Context()); err != nil {
+		return err
+	}
+	return next(srv, stream)
+}
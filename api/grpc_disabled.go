@@ -0,0 +1,13 @@
+//go:build !grpc
+
+package handler
+
+import (
+	"context"
+	"errors"
+)
+
+// ServeGRPC is a stub for builds without the grpc tag, which pulls in grpc and the generated stubs
+func ServeGRPC(ctx context.Context, addr string) error {
+	return errors.New("gRPC is not available in this build, build with -tags grpc")
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// health is the /healthz body. Status is "ok" while Xola answers, "degraded" when the last call
+// failed or the breaker is open, so a monitor can tell this service being down (no answer at all)
+// from Xola being down.
+type health struct {
+	Status string      `json:"status"`
+	Xola   xolaHealth  `json:"xola"`
+	Cache  cacheHealth `json:"cache"`
+}
+
+type xolaHealth struct {
+	// OK is whether the last call succeeded, true before the first one
+	OK          bool       `json:"ok"`
+	Circuit     string     `json:"circuit"`
+	LastCall    *time.Time `json:"lastCall,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+type cacheHealth struct {
+	Store   string `json:"store"`
+	Entries int    `json:"entries"`
+	Fresh   int    `json:"fresh"`
+}
+
+// healthHandler is public like /openapi.json, it only reports timestamps and counts
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	xola := xolaState()
+	result := health{Status: "ok", Xola: xola, Cache: cacheState()}
+	if !xola.OK || xola.Circuit != "closed" {
+		result.Status = "degraded"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONResponse(w, result)
+}
+
+// livezHandler answers as long as the process can serve at all, a failure means restart it
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+// readiness is the /readyz body, one line per check
+type readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// readyzHandler is 200 once the config is loaded and the instance can answer with data: Xola was
+// reachable on the last call (or hasn't been called yet), or there's fresh cached data to serve
+// while it isn't. Otherwise 503, so a rollout holds traffic back from this instance. An unready
+// instance gets no traffic to find out Xola is back, so the probe itself retries today's lookup
+// (the breaker still spaces those out once it's open).
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	result := readiness{Ready: true, Checks: map[string]string{}}
+
+	result.Checks["config"] = "ok"
+	if !configLoaded() {
+		result.Checks["config"], result.Ready = "not loaded", false
+	}
+
+	xola, cache := xolaState(), cacheState()
+	upstream := xola.Circuit != "open" && xola.OK
+	if !upstream && cache.Fresh == 0 {
+		today := time.Now().In(venueLocation()).Format("2006-01-02")
+		if _, _, err := querySkateTimesAPI(r.Context(), today); err == nil {
+			cache = cacheState()
+			upstream = true
+		}
+	}
+	result.Checks["upstream"] = "ok"
+	if !upstream {
+		result.Checks["upstream"] = "unreachable, circuit " + xola.Circuit
+	}
+	result.Checks["cache"] = strconv.Itoa(cache.Fresh) + " fresh of " + strconv.Itoa(cache.Entries)
+	if !upstream && cache.Fresh == 0 {
+		result.Ready = false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !result.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	writeJSONResponse(w, result)
+}
+
+// xolaState snapshots what the breaker knows about the last Xola call
+func xolaState() xolaHealth {
+	xolaBreaker.Lock()
+	xola := xolaHealth{OK: xolaBreaker.lastError == "", LastError: xolaBreaker.lastError}
+	if !xolaBreaker.lastCall.IsZero() {
+		lastCall := xolaBreaker.lastCall
+		xola.LastCall = &lastCall
+	}
+	if !xolaBreaker.lastSuccess.IsZero() {
+		lastSuccess := xolaBreaker.lastSuccess
+		xola.LastSuccess = &lastSuccess
+	}
+	xolaBreaker.Unlock()
+	xola.Circuit = xolaBreaker.state()
+	return xola
+}
+
+// cacheState counts the cached ranges and how many are still fresh
+func cacheState() cacheHealth {
+	cache := cacheHealth{Store: "memory"}
+	store := cacheStore()
+	if _, ok := store.(kvStore); ok {
+		cache.Store = "kv"
+	}
+	for _, key := range store.keys() {
+		if entry, ok := store.get(key); ok {
+			cache.Entries++
+			if entry.fresh() {
+				cache.Fresh++
+			}
+		}
+	}
+	return cache
+}
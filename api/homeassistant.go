@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// homeAssistantSensor is laid out for Home Assistant's REST sensor: state is the sensor value and
+// every other top-level field can be pulled in with json_attributes
+type homeAssistantSensor struct {
+	State             int                    `json:"state"`
+	UnitOfMeasurement string                 `json:"unit_of_measurement"`
+	FriendlyName      string                 `json:"friendly_name"`
+	Date              string                 `json:"date"`
+	Closed            bool                   `json:"closed"`
+	SoldOut           bool                   `json:"sold_out"`
+	NextSession       string                 `json:"next_session"`
+	NextSessionSpots  int                    `json:"next_session_spots"`
+	Sessions          []homeAssistantSession `json:"sessions"`
+	BookURL           string                 `json:"book_url"`
+	UpdatedAt         string                 `json:"updated_at"`
+}
+
+type homeAssistantSession struct {
+	Date  string `json:"date"`
+	Time  string `json:"time"`
+	Spots int    `json:"spots"`
+}
+
+// homeAssistantHandler is /api as a Home Assistant sensor, today unless ?date= (or a range) says
+// otherwise. Home Assistant can send the API key as a header, ?token= works too.
+func homeAssistantHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Set("format", "homeassistant")
+	r.URL.RawQuery = query.Encode()
+	skateTimesHandler(w, r)
+}
+
+// renderHomeAssistant sums the open spots of every session into the state. The next session is the
+// first one still to start with spots left, "" when there's none. Limited sessions count as 0
+// spots, like the JSON format.
+func renderHomeAssistant(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	now := time.Now().In(venueLocation())
+	sensor := homeAssistantSensor{
+		UnitOfMeasurement: "spots",
+		Date:              view.start,
+		Sessions:          []homeAssistantSession{},
+		UpdatedAt:         now.Format(time.RFC3339),
+	}
+	closed := len(view.days) > 0
+	for i, day := range view.days {
+		if i == 0 {
+			sensor.FriendlyName = day.opts.rink.label() + " open skating"
+			sensor.BookURL = bookingURL(day.opts.rink, day.date)
+		}
+		closed = closed && isClosedDay(day.opts.rink, day.dateObj)
+		keys, cleanedMap := day.opts.skateTimes(day.date, view.skateTimesMap)
+		for _, skateTime := range day.opts.filter(keys) {
+			spots := cleanedMap[skateTime]
+			if isLimited(spots) {
+				spots = 0
+			}
+			timeObj, _ := time.Parse("1504", skateTime)
+			sensor.Sessions = append(sensor.Sessions, homeAssistantSession{Date: day.date, Time: timeObj.Format("15:04"), Spots: spots})
+			sensor.State += spots
+
+			start := time.Date(day.dateObj.Year(), day.dateObj.Month(), day.dateObj.Day(), timeObj.Hour(), timeObj.Minute(), 0, 0, now.Location())
+			if sensor.NextSession == "" && cleanedMap[skateTime] > 0 && start.After(now) {
+				sensor.NextSession, sensor.NextSessionSpots = timeObj.Format("15:04"), spots
+				if view.end != "" {
+					sensor.NextSession = day.date + " " + sensor.NextSession
+				}
+			}
+		}
+	}
+	sensor.Closed = closed
+	sensor.SoldOut = sensor.State == 0 && !closed
+	data, _ := json.Marshal(sensor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
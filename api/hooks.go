@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kvHooksHash is the KV hash registered webhooks live in, one field per ID
+const kvHooksHash = "bp-skate:webhooks"
+
+// registeredHook is a URL registered through /admin/webhooks. Dates are dates or weekdays like
+// ALERT_DATES, every date when empty. Secret signs each delivery the way WEBHOOK_SECRET does.
+type registeredHook struct {
+	ID         string     `json:"id"`
+	URL        string     `json:"url"`
+	Dates      []string   `json:"dates,omitempty"`
+	OpenedOnly bool       `json:"openedOnly,omitempty"`
+	Secret     string     `json:"secret,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+}
+
+// watches reports whether the hook wants changes on the date
+func (hook registeredHook) watches(dateObj time.Time) bool {
+	if len(hook.Dates) == 0 {
+		return true
+	}
+	for _, entry := range hook.Dates {
+		if entry == dateObj.Format("2006-01-02") {
+			return true
+		}
+		if weekday, ok := parseWeekday(strings.ToLower(entry)); ok && weekday == dateObj.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// hookStore is where registrations are kept, the KV store when one is configured, otherwise memory
+type hookStore interface {
+	all() []registeredHook
+	put(hook registeredHook)
+	remove(id string) bool
+}
+
+func registeredHooks() hookStore {
+	if store, ok := kvStoreFromEnv(); ok {
+		return kvHookStore{store}
+	}
+	return localHooks
+}
+
+type memoryHookStore struct {
+	sync.Mutex
+	byID map[string]registeredHook
+}
+
+var localHooks = &memoryHookStore{byID: map[string]registeredHook{}}
+
+func (store *memoryHookStore) all() []registeredHook {
+	store.Lock()
+	defer store.Unlock()
+	hooks := make([]registeredHook, 0, len(store.byID))
+	for _, hook := range store.byID {
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+func (store *memoryHookStore) put(hook registeredHook) {
+	store.Lock()
+	defer store.Unlock()
+	store.byID[hook.ID] = hook
+}
+
+func (store *memoryHookStore) remove(id string) bool {
+	store.Lock()
+	defer store.Unlock()
+	_, ok := store.byID[id]
+	delete(store.byID, id)
+	return ok
+}
+
+type kvHookStore struct {
+	kv kvStore
+}
+
+func (store kvHookStore) all() []registeredHook {
+	var fields []string
+	if err := store.kv.command(&fields, "HGETALL", kvHooksHash); err != nil {
+		slog.Warn("KV webhook lookup failed", "error", err)
+		return nil
+	}
+	var hooks []registeredHook
+	for i := 0; i+1 < len(fields); i += 2 {
+		var hook registeredHook
+		if json.Unmarshal([]byte(fields[i+1]), &hook) == nil {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+func (store kvHookStore) put(hook registeredHook) {
+	data, _ := json.Marshal(hook)
+	var added int
+	if err := store.kv.command(&added, "HSET", kvHooksHash, hook.ID, string(data)); err != nil {
+		slog.Warn("KV webhook save failed", "error", err)
+	}
+}
+
+func (store kvHookStore) remove(id string) bool {
+	var removed int
+	if err := store.kv.command(&removed, "HDEL", kvHooksHash, id); err != nil {
+		slog.Warn("KV webhook delete failed", "error", err)
+	}
+	return removed > 0
+}
+
+// changedSlot is one session in an availability_changed event. Previous is 0 for sessions that
+// were sold out or not listed before.
+type changedSlot struct {
+	Time     string `json:"time"`
+	Spots    int    `json:"spots"`
+	Previous int    `json:"previous"`
+}
+
+// availabilityChangedEvent is the body POSTed to registered webhooks, kept flat so Zapier, IFTTT
+// and n8n can map its fields without code. Event is spots_opened when every change is an opening.
+type availabilityChangedEvent struct {
+	Event     string        `json:"event"`
+	Rink      string        `json:"rink"`
+	Date      string        `json:"date"`
+	Slots     []changedSlot `json:"slots"`
+	BookURL   string        `json:"bookUrl"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// notifyHooks POSTs the date's changes to every registered webhook watching it. Delivery runs in
+// the background, one hook after the other.
+func notifyHooks(rk rink, date string, changes []availabilityChange) {
+	dateObj, err := time.Parse("2006-01-02", date)
+	if err != nil || len(changes) == 0 {
+		return
+	}
+	var watching []registeredHook
+	for _, hook := range registeredHooks().all() {
+		if hook.watches(dateObj) {
+			watching = append(watching, hook)
+		}
+	}
+	if len(watching) == 0 {
+		return
+	}
+	opened := openings(changes)
+	event := func(changes []availabilityChange) availabilityChangedEvent {
+		event := availabilityChangedEvent{Event: "availability_changed", Rink: rk.name, Date: date, BookURL: bookingURL(rk, date), Timestamp: time.Now().Unix()}
+		if len(changes) == len(opened) {
+			event.Event = "spots_opened"
+		}
+		for _, change := range changes {
+			timeObj, _ := time.Parse("1504", change.Time)
+			event.Slots = append(event.Slots, changedSlot{Time: timeObj.Format("15:04"), Spots: change.Spots, Previous: change.Previous})
+		}
+		return event
+	}
+	all, openedOnly := event(changes), event(opened)
+	goBackground(func() {
+		for _, hook := range watching {
+			payload := all
+			if hook.OpenedOnly {
+				if len(opened) == 0 {
+					continue
+				}
+				payload = openedOnly
+			}
+			if err := postWebhook(webhookClient, hook.URL, hook.Secret, payload, payload.Timestamp); err != nil {
+				slog.Warn("registered webhook delivery failed", "id", hook.ID, "date", date, "error", err)
+			}
+		}
+	})
+}
+
+// adminWebhooksHandler manages the registered webhooks: GET lists them (without their secrets),
+// POST {"url", "dates", "openedOnly"} registers one and returns its signing secret, DELETE ?id=
+// removes one
+func adminWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	store := registeredHooks()
+
+	switch r.Method {
+	case http.MethodGet:
+		listed := store.all()
+		for i := range listed {
+			listed[i].Secret = ""
+		}
+		sort.Slice(listed, func(i, j int) bool { return listed[i].ID < listed[j].ID })
+		writeJSONResponse(w, listed)
+	case http.MethodPost:
+		var hook registeredHook
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil || validURL(hook.URL) != nil {
+			writeJSONError(w, http.StatusBadRequest, `Expected {"url": "https://...", "dates": ["2024-01-20", "sat"], "openedOnly": true}, dates and openedOnly are optional`)
+			return
+		}
+		if parsed, _ := url.Parse(hook.URL); parsed.Scheme != "https" && parsed.Scheme != "http" {
+			writeJSONError(w, http.StatusBadRequest, "The url must be http or https")
+			return
+		}
+		if err := validAlertDates(strings.Join(hook.Dates, ",")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Bad dates: "+err.Error())
+			return
+		}
+		id, idErr := newAPIKey()
+		secret, secretErr := newAPIKey()
+		if idErr != nil || secretErr != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Could not generate an ID")
+			return
+		}
+		now := time.Now().UTC()
+		hook.ID, hook.Secret, hook.CreatedAt = id[:12], secret, &now
+		store.put(hook)
+		writeJSONResponse(w, hook)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if !store.remove(id) {
+			writeJSONError(w, http.StatusNotFound, "No webhook with ID "+id)
+			return
+		}
+		writeJSONResponse(w, map[string]string{"removed": id})
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to list, POST to register or DELETE to remove")
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// htmlDay is one day's section on the HTML page
+type htmlDay struct {
+	Heading  string
+	Message  string
+	Sessions []htmlSession
+	Booking  string
+}
+
+type htmlSession struct {
+	Time    string
+	Spots   string
+	SoldOut bool
+}
+
+// htmlPage is a single phone-sized page, no scripts or external stylesheets so it loads fast from
+// a bookmark
+var htmlPage = template.Must(template.New("view").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, system-ui, sans-serif; margin: 0 auto; max-width: 32rem; padding: 1rem; color: #222; }
+h1 { font-size: 1.3rem; }
+h2 { font-size: 1.1rem; margin-top: 1.5rem; }
+ul { list-style: none; padding: 0; }
+li { display: flex; justify-content: space-between; padding: .6rem 0; border-bottom: 1px solid #ddd; }
+.sold-out { color: #999; }
+.spots { font-weight: 600; }
+a { color: #0a58ca; }
+@media (prefers-color-scheme: dark) { body { background: #111; color: #eee; } li { border-color: #333; } a { color: #6ea8fe; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Days}}<h2>{{.Heading}}</h2>
+{{if .Message}}<p>{{.Message}}</p>
+{{else}}<ul>
+{{range .Sessions}}<li{{if .SoldOut}} class="sold-out"{{end}}><span>{{.Time}}</span><span class="spots">{{.Spots}}</span></li>
+{{end}}</ul>
+<p><a href="{{.Booking}}">Book</a></p>
+{{end}}{{end}}<p><small>Updated {{.Updated}}</small></p>
+</body>
+</html>
+`))
+
+// newHTMLDay lays out one day with the same filtering and empty-day messages as the text format
+func newHTMLDay(date string, dateObj time.Time, skateTimesMap map[string]map[string]int, opts formatOptions, heading string) htmlDay {
+	keys, cleanedMap := opts.skateTimes(date, skateTimesMap)
+	keys = opts.filter(keys)
+	day := htmlDay{Heading: heading, Booking: bookingURL(opts.rink, date)}
+	if len(keys) == 0 {
+		day.Message = emptyDayMessage(opts.rink, dateObj)
+		return day
+	}
+	for _, skateTime := range keys {
+		timeObj, _ := time.Parse("1504", skateTime)
+		session := htmlSession{Time: timeObj.Format("3:04 PM"), Spots: spotsText(cleanedMap[skateTime])}
+		if cleanedMap[skateTime] == 0 {
+			session.Spots = "Sold out"
+			if opts.waitlists[skateTime] {
+				session.Spots = "Waitlist open"
+			}
+			session.SoldOut = true
+		}
+		day.Sessions = append(day.Sessions, session)
+	}
+	return day
+}
+
+// renderHTMLPage is the page for the days, also the HTML part of the email digest
+func renderHTMLPage(title string, days []htmlDay) (string, error) {
+	var sb strings.Builder
+	err := htmlPage.Execute(&sb, struct {
+		Title   string
+		Days    []htmlDay
+		Updated string
+	}{title, days, time.Now().In(venueLocation()).Format("Jan 2, 3:04 PM")})
+	return sb.String(), err
+}
+
+func writeHTMLResponse(w http.ResponseWriter, title string, days []htmlDay) {
+	page, err := renderHTMLPage(title, days)
+	if err != nil {
+		slog.Error("could not render page", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(page))
+}
+
+// viewHandler is /view, the HTML page under a path that's easy to bookmark
+func viewHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Set("format", "html")
+	r.URL.RawQuery = query.Encode()
+	skateTimesHandler(w, r)
+}
@@ -1,97 +1,178 @@
 package handler
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
 
 // Handler code entrypoint
 func Handler(w http.ResponseWriter, r *http.Request) {
+	r, endSpan := startRequestSpan(r)
+	r = withRequestID(w, r)
+	w, r, finishMetrics := instrumentRequest(w, r)
+	defer func() { endSpan(finishMetrics()) }()
+	w, closeBody := compressResponse(w, r)
+	defer closeBody()
+
+	if !allowedIP(r) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Forbidden"))
+		return
+	}
+	if applyCORS(w, r) {
+		return
+	}
+
+	w.Header().Set("API-Version", currentVersion)
+	route := routePath(r.URL.Path)
+
+	// the spec and health checks are public, they're the same for everyone
+	switch route {
+	case "/openapi.json":
+		openAPIHandler(w, r)
+		return
+	case "/healthz":
+		healthHandler(w, r)
+		return
+	case "/livez":
+		livezHandler(w, r)
+		return
+	case "/readyz":
+		readyzHandler(w, r)
+		return
+	case "/metrics":
+		metricsHandler(w, r)
+		return
+	}
+	// admin routes check ADMIN_TOKEN instead
+	if admin, ok := adminRoutes[route]; ok {
+		if adminAuthorized(r) {
+			noteRequestKey(r.Context(), adminKeyName)
+		}
+		admin(w, r)
+		return
+	}
+	if route == pprofPrefix || strings.HasPrefix(route, pprofPrefix+"/") {
+		pprofHandler(w, r)
+		return
+	}
+	if integration, ok := integrationRoutes[route]; ok {
+		integration(w, r)
+		return
+	}
+
 	// Basic validation, exits early if not authorized
-	//origin := r.Header.Get("token")
-	//if origin != "Andrew" {
-	//	w.WriteHeader(http.StatusForbidden)
-	//	w.Write([]byte("Forbidden"))
-	//	return
-	//}
+	if feedRoutes[route] {
+		r = withFeedToken(r)
+	}
+	r, ok := authorized(r)
+	noteRequestKey(r.Context(), apiKeyName(r.Context()))
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Forbidden"))
+		return
+	}
+	if !allowKey(w, r) {
+		return
+	}
 
-	// Get date and make request
-	date := r.Header.Get("startDate")
-	dateObj, dateParseError := time.Parse("2006-01-02", date)
-	if dateParseError != nil {
-		log.Println("WARNING: bad date input - inputted date:" + date)
+	// ?fresh=true skips the availability cache for this request
+	if fresh := r.URL.Query().Get("fresh"); fresh == "true" || fresh == "1" {
+		r = r.WithContext(withFreshData(r.Context()))
 	}
-	var rawResponse = querySkateTimesAPI(date, w)
-	sb := getFormattedTimes(date, dateObj, rawResponse)
 
-	// Write outgoing formatted response
-	writeSuccessResponse(w, &sb)
-}
+	ctx, age := withDataAge(r.Context())
+	r = r.WithContext(ctx)
+	w = &ageWriter{ResponseWriter: w, age: age}
 
-func writeSuccessResponse(w http.ResponseWriter, sb *strings.Builder) {
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte(sb.String()))
+	rk, ok := requestRink(r)
+	if !ok {
+		writeUnknownRink(w, r.URL.Query().Get("rink"))
+		return
+	}
+	r = r.WithContext(withRink(r.Context(), rk))
+
+	if problem := validateRequest(route, r); problem != "" {
+		writeJSONError(w, http.StatusBadRequest, problem)
+		return
+	}
+	if endpoint, ok := apiRoutes[route]; ok {
+		endpoint(w, r)
+		return
+	}
+	skateTimesHandler(w, r)
 }
 
-func getFormattedTimes(date string, dateObj time.Time, skateTimesMap map[string]map[string]int) strings.Builder {
-	// Remove values where time slot count is 0
-	var skateTimesMapNoZeroValues = map[string]int{}
-	for k, v := range skateTimesMap[date] {
-		if v > 0 {
-			if len(k) == 3 {
-				k = "0" + k
-			}
-			skateTimesMapNoZeroValues[k] = v
+func skateTimesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get date and make request
+	date, dateObj, dateParseError := requestDate(r)
+	if dateParseError != nil {
+		writeBadDate(w, date)
+		return
+	}
+	if format := responseFormat(r); !formatEnabled(format) {
+		writeNotAcceptable(w, format)
+		return
+	}
+	if r.URL.Query().Get("qr") == "1" {
+		writeQRCode(w, rinkFromContext(r.Context()), date)
+		return
+	}
+	if end := requestEndDate(r); end != "" {
+		rangeHandler(w, r, dateObj, end)
+		return
+	}
+
+	// closed days skip the upstream call entirely and are rendered as "Closed"
+	var rawResponse = map[string]map[string]int{}
+	var waitlists = map[string]map[string]bool{}
+	if !isClosedDay(rinkFromContext(r.Context()), dateObj) {
+		_, endXolaSpan := startSpan(r.Context(), "xola.availability")
+		var err error
+		rawResponse, waitlists, err = querySkateTimesAPI(r.Context(), date)
+		endXolaSpan()
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
 		}
 	}
+	setCacheControl(w, r)
 
-	// Go Maps do not iterate in insertion order, so we have to hack it to do so
-	// create slice and store keys
-	var keys = make([]string, 0, len(skateTimesMapNoZeroValues))
-	for k := range skateTimesMapNoZeroValues {
-		keys = append(keys, k)
+	// polling clients pass back the last digest they saw and get 204 while nothing has changed
+	digest := availabilityDigest(date, rawResponse)
+	w.Header().Set(digestHeader, digest)
+	if since := r.URL.Query().Get("since"); since != "" && since == digest {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if notModified(w, r, date, digest) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-	// sort the slice by keys
-	sort.Strings(keys)
 
-	return formatSkateTimes(dateObj, keys, skateTimesMapNoZeroValues)
-}
+	opts := formatOptionsFromRequest(r)
+	opts.waitlists = waitlists[date]
+	if !dateRequested(r) {
+		opts.previousDate, opts.previousTimes = lateSessions(r.Context(), date, time.Now().In(venueLocation()))
+	}
+	if pricingEnabled() && len(rawResponse) > 0 {
+		opts.price = queryExperiencePrice(r.Context())
+	}
 
-func querySkateTimesAPI(date string, w http.ResponseWriter) map[string]map[string]int {
-	// Query BP API for times
-	res, err := http.Get("https://xola.com/api/experiences/61536b244f19be5b3c6e4241/availability?start=" + date + "&end=" + date + "&privacy=public")
-	log.Println("Successfully made outbound request")
+	_, endFormatSpan := startSpan(r.Context(), "format")
+	defer endFormatSpan()
 
-	// check for response error
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Fatal(err) // exit early
+	if r.URL.Query().Get("extremes") == "1" {
+		writeExtremes(w, r, date, dateObj, rawResponse, opts)
+		return
 	}
 
-	// read all response body into string and close stream
-	data, _ := ioutil.ReadAll(res.Body)
-	res.Body.Close()
-
-	// unpack response into { date: { time: count } } map
-	skateTimesMap := map[string]map[string]int{}
-	json.Unmarshal(data, &skateTimesMap)
-	return skateTimesMap
+	renderAvailability(w, r, availabilityView{start: date, days: []viewDay{{date, dateObj, opts}}, skateTimesMap: rawResponse})
 }
 
-func formatSkateTimes(dateObj time.Time, keys []string, cleanedMap map[string]int) strings.Builder {
-	var sb strings.Builder
-	sb.WriteString("For " + dateObj.Format("Jan 2, 2006") + ":\n")
-	// iterate by sorted keys
-	for _, skateTime := range keys {
-		timeObj, _ := time.Parse("1504", skateTime)
-		sb.WriteString(timeObj.Format("3:04 PM") + " has " + strconv.Itoa(cleanedMap[skateTime]) + " spots\n")
-	}
-	return sb
+func writeSuccessResponse(w http.ResponseWriter, sb *strings.Builder) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(sb.String()))
 }
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSinceDigest(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	first := get(t, "/api?date=2024-01-02")
+	digest := first.Header().Get(digestHeader)
+	if first.Code != http.StatusOK || digest == "" {
+		t.Fatalf("status %d, digest %q", first.Code, digest)
+	}
+
+	tests := []struct {
+		name   string
+		since  string
+		status int
+	}{
+		{"unchanged", digest, http.StatusNoContent},
+		{"unchanged as JSON", digest + "&format=json", http.StatusNoContent},
+		{"stale digest", "0123456789abcdef", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := get(t, "/api?date=2024-01-02&since="+test.since)
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d", w.Code, test.status)
+			}
+			if test.status == http.StatusNoContent && w.Body.Len() != 0 {
+				t.Errorf("204 with a body %q", w.Body.String())
+			}
+		})
+	}
+
+	stub.answer(http.StatusOK, `{"2024-01-02": {"1500": 2}}`)
+	changed := get(t, "/api?date=2024-01-02&fresh=1&since="+digest)
+	if changed.Code != http.StatusOK || changed.Body.String() != "For Jan 2, 2024:\n3:00 PM has 2 spots\n" {
+		t.Errorf("after a change: status %d, body %q", changed.Code, changed.Body.String())
+	}
+	if changed.Header().Get(digestHeader) == digest {
+		t.Error("the digest header didn't change with the data")
+	}
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefresh is how long fetched JWKS keys are reused before fetching them again
+const jwksRefresh = time.Hour
+
+// jwtHeader and jwtClaims are the parts of a token we look at
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+// validJWT checks an `Authorization: Bearer <jwt>` token (AUTH_MODE=jwt) and returns its subject.
+// HS256 tokens are checked against JWT_SECRET, RS256 against JWT_PUBLIC_KEY (PEM) or the keys at
+// JWT_JWKS_URL. Tokens must expire (exp) and, when JWT_ISSUER / JWT_AUDIENCE are set, match them.
+func validJWT(r *http.Request, now time.Time) (string, error) {
+	value := r.Header.Get("Authorization")
+	if len(value) < len(bearerScheme) || !strings.EqualFold(value[:len(bearerScheme)], bearerScheme) {
+		return "", errors.New("no bearer token")
+	}
+	parts := strings.Split(strings.TrimSpace(value[len(bearerScheme):]), ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed signature")
+	}
+	// the algorithm is checked against the configured keys, never trusted from the token alone
+	switch header.Alg {
+	case "HS256":
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return "", errors.New("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return "", errors.New("bad signature")
+		}
+	case "RS256":
+		key, err := rsaKey(header.Kid)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return "", errors.New("bad signature")
+		}
+	default:
+		return "", errors.New("unsupported alg " + header.Alg)
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	if claims.ExpiresAt == nil || now.Unix() >= *claims.ExpiresAt {
+		return "", errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Unix() < *claims.NotBefore {
+		return "", errors.New("token not valid yet")
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" && claims.Issuer != issuer {
+		return "", errors.New("wrong issuer")
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" && !claims.hasAudience(audience) {
+		return "", errors.New("wrong audience")
+	}
+	return claims.Subject, nil
+}
+
+func decodeJWTPart(part string, into interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// hasAudience handles aud being either one string or a list
+func (claims jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(claims.Audience, &single) == nil {
+		return single == audience
+	}
+	var list []string
+	json.Unmarshal(claims.Audience, &list)
+	for _, candidate := range list {
+		if candidate == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// rsaKey is JWT_PUBLIC_KEY when set, otherwise the JWKS key with the token's kid
+func rsaKey(kid string) (*rsa.PublicKey, error) {
+	if encoded := os.Getenv("JWT_PUBLIC_KEY"); encoded != "" {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, errors.New("JWT_PUBLIC_KEY is not PEM")
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("JWT_PUBLIC_KEY is not an RSA key")
+		}
+		return key, nil
+	}
+	if os.Getenv("JWT_JWKS_URL") == "" {
+		return nil, errors.New("RS256 tokens are not accepted")
+	}
+	keys, err := jwksKeys()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[kid]
+	if !ok {
+		return nil, errors.New("unknown kid " + kid)
+	}
+	return key, nil
+}
+
+// jwks caches the keys from JWT_JWKS_URL
+var jwks = struct {
+	sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}{}
+
+func jwksKeys() (map[string]*rsa.PublicKey, error) {
+	jwks.Lock()
+	defer jwks.Unlock()
+	if jwks.keys != nil && time.Since(jwks.fetchedAt) < jwksRefresh {
+		return jwks.keys, nil
+	}
+	keys, err := fetchJWKS(os.Getenv("JWT_JWKS_URL"))
+	if err != nil {
+		if jwks.keys != nil {
+			// keep using the old keys rather than locking everyone out
+			return jwks.keys, nil
+		}
+		return nil, err
+	}
+	jwks.keys, jwks.fetchedAt = keys, time.Now()
+	return keys, nil
+}
+
+// jwksClient fetches the key set, it's on the auth path of every request after a refresh
+var jwksClient = &http.Client{Timeout: 5 * time.Second}
+
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	res, err := jwksClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.New("bad JWKS: " + err.Error())
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
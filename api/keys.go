@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kvKeysHash is the KV hash the managed API keys live in, one field per key name
+const kvKeysHash = "bp-skate:keys"
+
+// lastUsedResolution limits how often a key's last-used time is written back to the store
+const lastUsedResolution = time.Minute
+
+// storedKey is an API key created through /admin/keys. Only a hash of the key is kept, the key
+// itself is shown once when it's created.
+type storedKey struct {
+	Name       string     `json:"name"`
+	Label      string     `json:"label,omitempty"`
+	Hash       string     `json:"hash,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	// Source is "env" for keys from AUTH_TOKEN / API_KEYS, which can't be revoked here
+	Source string `json:"source,omitempty"`
+}
+
+// keyStore is where managed keys are persisted: the KV store when one is configured (so keys work
+// on every function instance), otherwise this instance's memory. all fails when the store can't be
+// read, which must not look like there being no keys.
+type keyStore interface {
+	all() (map[string]storedKey, error)
+	put(key storedKey)
+	remove(name string) bool
+}
+
+func managedKeys() keyStore {
+	if store, ok := kvStoreFromEnv(); ok {
+		return kvKeyStore{store}
+	}
+	return localKeys
+}
+
+type memoryKeyStore struct {
+	sync.Mutex
+	byName map[string]storedKey
+}
+
+var localKeys = &memoryKeyStore{byName: map[string]storedKey{}}
+
+func (store *memoryKeyStore) all() (map[string]storedKey, error) {
+	store.Lock()
+	defer store.Unlock()
+	keys := map[string]storedKey{}
+	for name, key := range store.byName {
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+func (store *memoryKeyStore) put(key storedKey) {
+	store.Lock()
+	defer store.Unlock()
+	store.byName[key.Name] = key
+}
+
+func (store *memoryKeyStore) remove(name string) bool {
+	store.Lock()
+	defer store.Unlock()
+	_, ok := store.byName[name]
+	delete(store.byName, name)
+	return ok
+}
+
+type kvKeyStore struct {
+	kv kvStore
+}
+
+// managedKeysTTL is how long an instance reuses the managed keys it read, so requests don't each
+// read the whole hash. A key created or revoked on another instance takes up to this long here.
+const managedKeysTTL = 30 * time.Second
+
+// kvKeysCache is this instance's copy of the KV keys, kept up to date with its own changes
+var kvKeysCache struct {
+	sync.Mutex
+	keys    map[string]storedKey
+	fetched time.Time
+}
+
+func (store kvKeyStore) all() (map[string]storedKey, error) {
+	kvKeysCache.Lock()
+	defer kvKeysCache.Unlock()
+	if kvKeysCache.keys == nil || time.Since(kvKeysCache.fetched) > managedKeysTTL {
+		keys, err := store.fetch()
+		if err != nil {
+			return nil, err
+		}
+		kvKeysCache.keys, kvKeysCache.fetched = keys, time.Now()
+	}
+	keys := map[string]storedKey{}
+	for name, key := range kvKeysCache.keys {
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+func (store kvKeyStore) fetch() (map[string]storedKey, error) {
+	var fields []string
+	if err := store.kv.command(&fields, "HGETALL", kvKeysHash); err != nil {
+		slog.Warn("KV key lookup failed", "error", err)
+		return nil, err
+	}
+	keys := map[string]storedKey{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		var key storedKey
+		if json.Unmarshal([]byte(fields[i+1]), &key) == nil {
+			keys[fields[i]] = key
+		}
+	}
+	return keys, nil
+}
+
+func (store kvKeyStore) put(key storedKey) {
+	data, _ := json.Marshal(key)
+	var added int
+	if err := store.kv.command(&added, "HSET", kvKeysHash, key.Name, string(data)); err != nil {
+		slog.Warn("KV key save failed", "error", err)
+		return
+	}
+	kvKeysCache.Lock()
+	if kvKeysCache.keys != nil {
+		kvKeysCache.keys[key.Name] = key
+	}
+	kvKeysCache.Unlock()
+}
+
+func (store kvKeyStore) remove(name string) bool {
+	var removed int
+	if err := store.kv.command(&removed, "HDEL", kvKeysHash, name); err != nil {
+		slog.Warn("KV key delete failed", "error", err)
+	}
+	kvKeysCache.Lock()
+	delete(kvKeysCache.keys, name)
+	kvKeysCache.Unlock()
+	return removed > 0
+}
+
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchStoredKey finds the managed key the token belongs to, comparing hashes in constant time,
+// and records that it was used
+func matchStoredKey(token string, keys map[string]storedKey) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	hash := hashKey(token)
+	var matched storedKey
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) == 1 {
+			matched = key
+		}
+	}
+	if matched.Name == "" {
+		return "", false
+	}
+	if now := time.Now().UTC(); matched.LastUsedAt == nil || now.Sub(*matched.LastUsedAt) > lastUsedResolution {
+		matched.LastUsedAt = &now
+		managedKeys().put(matched)
+	}
+	return matched.Name, true
+}
+
+// newAPIKey is 32 random bytes, URL-safe so it can go in a header or a Shortcut as is
+func newAPIKey() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// createdKey is the /admin/keys POST response, the only time the key itself is shown
+type createdKey struct {
+	storedKey
+	Key string `json:"key"`
+}
+
+// adminKeysHandler manages API keys at runtime: GET lists them (without the keys themselves),
+// POST {"name", "label"} creates one, DELETE ?name= revokes one
+func adminKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	store := managedKeys()
+	stored, err := store.all()
+	if err != nil && r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusBadGateway, "Could not read the managed keys: "+err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listed := []storedKey{}
+		for name := range apiKeys() {
+			listed = append(listed, storedKey{Name: name, Source: "env"})
+		}
+		for _, key := range stored {
+			key.Hash = ""
+			listed = append(listed, key)
+		}
+		sort.Slice(listed, func(i, j int) bool { return listed[i].Name < listed[j].Name })
+		writeJSONResponse(w, listed)
+	case http.MethodPost:
+		var body struct {
+			Name  string `json:"name"`
+			Label string `json:"label"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Name) == "" || strings.ContainsAny(body.Name, "=,") {
+			writeJSONError(w, http.StatusBadRequest, `Expected {"name": "...", "label": "..."}, names can't contain "=" or ","`)
+			return
+		}
+		name := strings.TrimSpace(body.Name)
+		if _, exists := stored[name]; exists || apiKeys()[name] != "" {
+			writeJSONError(w, http.StatusConflict, "A key named "+name+" already exists")
+			return
+		}
+		token, err := newAPIKey()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Could not generate a key")
+			return
+		}
+		now := time.Now().UTC()
+		key := storedKey{Name: name, Label: body.Label, Hash: hashKey(token), CreatedAt: &now}
+		store.put(key)
+		key.Hash = ""
+		writeJSONResponse(w, createdKey{storedKey: key, Key: token})
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if !store.remove(name) {
+			writeJSONError(w, http.StatusNotFound, "No managed key named "+name)
+			return
+		}
+		writeJSONResponse(w, map[string]string{"revoked": name})
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to list, POST to create or DELETE to revoke")
+	}
+}
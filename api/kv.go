@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kvKeyPrefix namespaces our keys in a KV database that may be shared with other projects
+const kvKeyPrefix = "bp-skate:availability:"
+
+// kvRetention is how long entries live in KV. It's much longer than the cache TTL on purpose,
+// stale entries are what the circuit breaker serves while Xola is down.
+const kvRetention = 24 * time.Hour
+
+// kvClient talks to the KV REST API, a slow cache mustn't be slower than just asking Xola
+var kvClient = &http.Client{Timeout: 2 * time.Second}
+
+// kvStore is a Redis database behind the Upstash REST API, which is also what Vercel KV exposes.
+// Commands are POSTed as a JSON array, e.g. ["GET", "key"], and answer {"result": ...}.
+type kvStore struct {
+	url   string
+	token string
+}
+
+// kvStoreFromEnv reads KV_REST_API_URL / KV_REST_API_TOKEN (set by Vercel when a KV store is linked),
+// falling back to UPSTASH_REDIS_REST_URL / UPSTASH_REDIS_REST_TOKEN
+func kvStoreFromEnv() (kvStore, bool) {
+	store := kvStore{url: os.Getenv("KV_REST_API_URL"), token: os.Getenv("KV_REST_API_TOKEN")}
+	if store.url == "" {
+		store = kvStore{url: os.Getenv("UPSTASH_REDIS_REST_URL"), token: os.Getenv("UPSTASH_REDIS_REST_TOKEN")}
+	}
+	return store, store.url != ""
+}
+
+// kvEntry is how a cacheEntry is stored in KV
+type kvEntry struct {
+	SkateTimes map[string]map[string]int  `json:"skateTimes"`
+	Waitlists  map[string]map[string]bool `json:"waitlists"`
+	FetchedAt  int64                      `json:"fetchedAt"`
+}
+
+// get treats any KV failure as a miss, the request just goes to Xola instead
+func (store kvStore) get(key string) (cacheEntry, bool) {
+	var value *string
+	if err := store.command(&value, "GET", kvKeyPrefix+key); err != nil {
+		slog.Warn("KV get failed", "error", err)
+		return cacheEntry{}, false
+	}
+	if value == nil {
+		return cacheEntry{}, false
+	}
+	var stored kvEntry
+	if err := json.Unmarshal([]byte(*value), &stored); err != nil {
+		slog.Warn("ignoring bad KV entry", "key", key, "error", err)
+		return cacheEntry{}, false
+	}
+	return cacheEntry{skateTimesMap: stored.SkateTimes, waitlists: stored.Waitlists, fetchedAt: time.Unix(stored.FetchedAt, 0)}, true
+}
+
+func (store kvStore) set(key string, entry cacheEntry) {
+	data, err := json.Marshal(kvEntry{SkateTimes: entry.skateTimesMap, Waitlists: entry.waitlists, FetchedAt: entry.fetchedAt.Unix()})
+	if err != nil {
+		return
+	}
+	var result string
+	if err := store.command(&result, "SET", kvKeyPrefix+key, string(data), "EX", strconv.Itoa(int(kvRetention.Seconds()))); err != nil {
+		slog.Warn("KV set failed", "error", err)
+	}
+}
+
+func (store kvStore) keys() []string {
+	var keys []string
+	if err := store.command(&keys, "KEYS", kvKeyPrefix+"*"); err != nil {
+		slog.Warn("KV keys failed", "error", err)
+		return nil
+	}
+	for i := range keys {
+		keys[i] = strings.TrimPrefix(keys[i], kvKeyPrefix)
+	}
+	return keys
+}
+
+func (store kvStore) remove(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, kvKeyPrefix+key)
+	}
+	var removed int
+	if err := store.command(&removed, args...); err != nil {
+		slog.Warn("KV delete failed", "error", err)
+	}
+}
+
+// command runs one Redis command and decodes its result into result
+func (store kvStore) command(result interface{}, args ...string) error {
+	body, _ := json.Marshal(args)
+	req, err := http.NewRequest(http.MethodPost, store.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+store.token)
+	res, err := kvClient.Do(req)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return errors.New("KV returned status " + strconv.Itoa(res.StatusCode))
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return json.Unmarshal(reply.Result, result)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// setupLogging installs the default slog logger: text lines, or one JSON object per line with
+// LOG_FORMAT=json (what Vercel log drains want), at LOG_LEVEL and above, tagged with the request ID. slog.SetDefault also
+// sends anything still using the log package through it.
+func setupLogging() {
+	opts := &slog.HandlerOptions{Level: logLevel()}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(requestIDHandler{h}))
+}
+
+// logLevel reads LOG_LEVEL (debug, info, warn or error), info by default
+func logLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// fatal logs at error level and exits, for the few problems that should stop startup
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// logRequest writes the access log line for a finished request
+func logRequest(r *http.Request, route string, key string, status int, elapsed time.Duration) {
+	attrs := []any{"method", r.Method, "path", r.URL.Path, "route", route, "status", status, "duration_ms", elapsed.Milliseconds()}
+	if date := requestedDate(r); date != "" {
+		attrs = append(attrs, "date", date)
+	}
+	if key != "" {
+		attrs = append(attrs, "key", key)
+	}
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(r.Context(), level, "request", attrs...)
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricDefs are the series /metrics exports, in the Prometheus text format. Everything is per
+// instance, so on Vercel each warm instance only knows about its own requests.
+var metricDefs = map[string]struct{ kind, help string }{
+	"bpskate_http_requests_total":           {"counter", "Requests served, by route and status code."},
+	"bpskate_http_request_duration_seconds": {"histogram", "Time to serve a request, by route."},
+	"bpskate_xola_requests_total":           {"counter", "Requests made to Xola, by status code (or error)."},
+	"bpskate_xola_request_duration_seconds": {"histogram", "Time for Xola to answer, by status code (or error)."},
+	"bpskate_cache_lookups_total":           {"counter", "Availability lookups, by result: hit, stale, fallback (served while the breaker is open) or miss."},
+	"bpskate_errors_total":                  {"counter", "Upstream failures answered with an error, by kind."},
+	"bpskate_today_sessions":                {"gauge", "Today's sessions as of the last Xola answer, by rink and state (open or sold_out)."},
+}
+
+// latencyBuckets are the histogram upper bounds in seconds, Prometheus' usual defaults
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// metricsRegistry holds every series by name, then by rendered label set
+var metricsRegistry = struct {
+	sync.Mutex
+	values     map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}{values: map[string]map[string]float64{}, histograms: map[string]map[string]*histogram{}}
+
+// metricLabels renders key, value pairs as {key="value",...}
+func metricLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+"="+strconv.Quote(pairs[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func incCounter(name string, labels ...string) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	if metricsRegistry.values[name] == nil {
+		metricsRegistry.values[name] = map[string]float64{}
+	}
+	metricsRegistry.values[name][metricLabels(labels...)]++
+}
+
+func setGauge(name string, value float64, labels ...string) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	if metricsRegistry.values[name] == nil {
+		metricsRegistry.values[name] = map[string]float64{}
+	}
+	metricsRegistry.values[name][metricLabels(labels...)] = value
+}
+
+func observe(name string, elapsed time.Duration, labels ...string) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	if metricsRegistry.histograms[name] == nil {
+		metricsRegistry.histograms[name] = map[string]*histogram{}
+	}
+	key := metricLabels(labels...)
+	h := metricsRegistry.histograms[name][key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		metricsRegistry.histograms[name][key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metricsHandler writes every series in the text exposition format. It's public like /healthz,
+// it's only counts and timings.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+
+	names := make([]string, 0, len(metricDefs))
+	for name := range metricDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		def := metricDefs[name]
+		sb.WriteString("# HELP " + name + " " + def.help + "\n")
+		sb.WriteString("# TYPE " + name + " " + def.kind + "\n")
+		if def.kind != "histogram" {
+			for _, labels := range sortedLabels(metricsRegistry.values[name]) {
+				sb.WriteString(name + labels + " " + formatMetric(metricsRegistry.values[name][labels]) + "\n")
+			}
+			continue
+		}
+		for _, labels := range sortedHistogramLabels(metricsRegistry.histograms[name]) {
+			h := metricsRegistry.histograms[name][labels]
+			// the bucket label goes last, after the series' own labels
+			prefix := "{"
+			if labels != "" {
+				prefix = strings.TrimSuffix(labels, "}") + ","
+			}
+			for i, bound := range latencyBuckets {
+				sb.WriteString(name + "_bucket" + prefix + `le="` + formatMetric(bound) + `"} ` + strconv.FormatUint(h.counts[i], 10) + "\n")
+			}
+			sb.WriteString(name + "_bucket" + prefix + `le="+Inf"} ` + strconv.FormatUint(h.count, 10) + "\n")
+			sb.WriteString(name + "_sum" + labels + " " + formatMetric(h.sum) + "\n")
+			sb.WriteString(name + "_count" + labels + " " + strconv.FormatUint(h.count, 10) + "\n")
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(sb.String()))
+}
+
+func formatMetric(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedLabels(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHistogramLabels(histograms map[string]*histogram) []string {
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statusWriter remembers the status code for the request metrics
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// instrumentRequest counts, times, logs and audits the request once finish is called, labelled
+// with its route. finish returns the status code sent.
+func instrumentRequest(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func() int) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	rec := &requestRecord{}
+	r = r.WithContext(context.WithValue(r.Context(), requestRecordContextKey{}, rec))
+	return sw, r, func() int {
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		route := metricsRoute(r.URL.Path)
+		incCounter("bpskate_http_requests_total", "route", route, "code", strconv.Itoa(sw.status))
+		observe("bpskate_http_request_duration_seconds", time.Since(start), "route", route)
+		rec.Lock()
+		key := rec.key
+		rec.Unlock()
+		logRequest(r, route, key, sw.status, time.Since(start))
+		auditRequest(r, rec, sw.status)
+		return sw.status
+	}
+}
+
+// metricsRoute is the route label: the versionless path for known routes, "other" for anything
+// else so scanners can't grow the series without bound
+func metricsRoute(path string) string {
+	route := routePath(path)
+	if route == "" {
+		return "/"
+	}
+	if _, ok := apiRoutes[route]; ok {
+		return route
+	}
+	if _, ok := adminRoutes[route]; ok {
+		return route
+	}
+	switch route {
+	case "/openapi.json", "/healthz", "/livez", "/readyz", "/metrics":
+		return route
+	}
+	return "other"
+}
+
+// observeXolaCall records one request to Xola
+func observeXolaCall(start time.Time, res *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	incCounter("bpskate_xola_requests_total", "status", status)
+	observe("bpskate_xola_request_duration_seconds", time.Since(start), "status", status)
+}
+
+// observeSessions updates today's open and sold out session counts for the rink
+func observeSessions(rinkName string, skateTimesMap map[string]map[string]int) {
+	slots, ok := skateTimesMap[time.Now().In(venueLocation()).Format("2006-01-02")]
+	if !ok {
+		return
+	}
+	open, soldOut := 0, 0
+	for _, spots := range slots {
+		if spots > 0 {
+			open++
+		} else {
+			soldOut++
+		}
+	}
+	setGauge("bpskate_today_sessions", float64(open), "rink", rinkName, "state", "open")
+	setGauge("bpskate_today_sessions", float64(soldOut), "rink", rinkName, "state", "sold_out")
+}
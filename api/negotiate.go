@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// availabilityView is what the renderers get: the day or days asked for and the upstream
+// availability covering them. End is "" for a single-day request.
+type availabilityView struct {
+	start         string
+	end           string
+	days          []viewDay
+	skateTimesMap map[string]map[string]int
+}
+
+// viewDay is one day with its own copy of the request's display options (waitlists differ per day)
+type viewDay struct {
+	date    string
+	dateObj time.Time
+	opts    formatOptions
+}
+
+// renderer draws an availabilityView in one output format. mediaTypes are what it answers to in
+// Accept, formats with none can only be picked with ?format=.
+type renderer struct {
+	mediaTypes []string
+	render     func(w http.ResponseWriter, r *http.Request, view availabilityView)
+}
+
+// renderers are every availability format, keyed by its ?format= name. supportedFormats lists
+// them in the order Accept wildcards like text/* are resolved.
+var renderers = map[string]renderer{
+	"text":          {mediaTypes: []string{"text/plain"}, render: renderText},
+	"json":          {mediaTypes: []string{"application/json"}, render: renderJSON},
+	"yaml":          {mediaTypes: []string{"application/x-yaml", "application/yaml"}, render: renderYAML},
+	"voice":         {render: renderVoice},
+	"csv":           {mediaTypes: []string{"text/csv"}, render: renderCSV},
+	"html":          {mediaTypes: []string{"text/html"}, render: renderHTML},
+	"slack":         {render: renderSlack},
+	"ics":           {mediaTypes: []string{"text/calendar"}, render: renderICS},
+	"shortcut":      {render: renderShortcut},
+	"homeassistant": {render: renderHomeAssistant},
+}
+
+// renderAvailability writes the view in the negotiated format. Callers have already checked the
+// format is enabled.
+func renderAvailability(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	renderers[responseFormat(r)].render(w, r, view)
+}
+
+// responseFormat picks the output format: ?format= when given, otherwise the best match for the Accept
+// header among the enabled formats, defaulting to text. An Accept that only names disabled formats
+// gets the first of them, so the caller answers 406 rather than something the client didn't ask for.
+func responseFormat(r *http.Request) string {
+	return negotiateFormat(r, supportedFormats)
+}
+
+// negotiateFormat is responseFormat for an endpoint that only renders the offered formats, which
+// always include text. Formats it doesn't offer count as disabled.
+func negotiateFormat(r *http.Request, offered []string) string {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		return format
+	}
+	var disabled string
+	for _, mediaType := range acceptedTypes(r.Header.Get("Accept")) {
+		if mediaType == "*/*" {
+			return "text"
+		}
+		for _, format := range supportedFormats {
+			if !matchesMediaType(mediaType, renderers[format].mediaTypes) {
+				continue
+			}
+			if formatEnabled(format) && offersFormat(offered, format) {
+				return format
+			}
+			if disabled == "" {
+				disabled = format
+			}
+		}
+	}
+	if disabled != "" {
+		return disabled
+	}
+	return "text"
+}
+
+// endpointFormat negotiates among the formats an endpoint renders itself (batch, week, ...) and
+// answers 406 when none of them will do
+func endpointFormat(w http.ResponseWriter, r *http.Request, offered ...string) (string, bool) {
+	format := negotiateFormat(r, offered)
+	if formatEnabled(format) && offersFormat(offered, format) {
+		return format, true
+	}
+	var available []string
+	for _, candidate := range offered {
+		if formatEnabled(candidate) {
+			available = append(available, candidate)
+		}
+	}
+	writeFormatsNotAcceptable(w, format, available)
+	return "", false
+}
+
+func offersFormat(offered []string, format string) bool {
+	for _, candidate := range offered {
+		if candidate == format {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedTypes lists the media types in an Accept header, most preferred first. Ties keep the
+// header's order and q=0 entries are dropped.
+func acceptedTypes(accept string) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var types []accepted
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			types = append(types, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool { return types[i].q > types[j].q })
+	mediaTypes := make([]string, len(types))
+	for i, t := range types {
+		mediaTypes[i] = t.mediaType
+	}
+	return mediaTypes
+}
+
+// matchesMediaType checks an Accept entry, which may be a type/* wildcard, against a renderer's types
+func matchesMediaType(accepted string, mediaTypes []string) bool {
+	for _, mediaType := range mediaTypes {
+		if accepted == mediaType || (strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderText(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	var sb strings.Builder
+	for i, day := range view.days {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(getFormattedTimes(day.date, day.dateObj, view.skateTimesMap, day.opts).String())
+	}
+	writeSuccessResponse(w, &sb)
+}
+
+func renderJSON(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	if body, ok := structuredView(w, r, view); ok {
+		writeJSONResponse(w, body)
+	}
+}
+
+func renderYAML(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	if body, ok := structuredView(w, r, view); ok {
+		writeYAMLResponse(w, body)
+	}
+}
+
+// structuredView is the JSON/YAML body: the single-day shape, or {start, end, days} for a range,
+// paged with ?pageSize= either way. A bad page token has already been answered when ok is false.
+func structuredView(w http.ResponseWriter, r *http.Request, view availabilityView) (interface{}, bool) {
+	var err error
+	var body interface{}
+	if view.end == "" {
+		day := view.days[0]
+		result := buildAvailability(day.date, view.skateTimesMap, day.opts)
+		err = paginateAvailability(&result, r)
+		body = result
+	} else {
+		result := availabilityRange{Start: view.start, End: view.end, Days: view.availability()}
+		err = paginateRange(&result, r)
+		body = result
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return nil, false
+	}
+	return body, true
+}
+
+func renderVoice(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	var sentences []string
+	now := time.Now().In(venueLocation())
+	for _, day := range view.days {
+		keys, cleanedMap := xola.CleanSkateTimes(day.date, view.skateTimesMap)
+		sentences = append(sentences, formatVoiceSummary(day.opts.rink, day.dateObj, day.opts.filter(keys), cleanedMap, now))
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.Join(sentences, " ") + "\n")
+	writeSuccessResponse(w, &sb)
+}
+
+func renderCSV(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	writeCSVResponse(w, view.availability())
+}
+
+func renderHTML(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	days := make([]htmlDay, 0, len(view.days))
+	for _, day := range view.days {
+		days = append(days, newHTMLDay(day.date, day.dateObj, view.skateTimesMap, day.opts, day.dateObj.Format("Monday, Jan 2")))
+	}
+	writeHTMLResponse(w, rinkFromContext(r.Context()).label()+" skating", days)
+}
+
+func renderSlack(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	days := make([][]slackBlock, 0, len(view.days))
+	for _, day := range view.days {
+		days = append(days, slackDay(day.date, day.dateObj, view.skateTimesMap, day.opts))
+	}
+	writeJSONResponse(w, newSlackMessage(days))
+}
+
+func renderICS(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	var sessions []feedSession
+	for _, day := range view.days {
+		sessions = append(sessions, daySessions(day.dateObj, view.skateTimesMap, day.opts)...)
+	}
+	writeICSResponse(w, r, sessions)
+}
+
+// availability is the structured form of each day, in order
+func (view availabilityView) availability() []availability {
+	days := make([]availability, 0, len(view.days))
+	for _, day := range view.days {
+		days = append(days, buildAvailability(day.date, view.skateTimesMap, day.opts))
+	}
+	return days
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// defaultNextAvailableDays is how far ahead /api/nextAvailable looks when neither ?days= nor NEXT_AVAILABLE_DAYS is set
+const defaultNextAvailableDays = 14
+
+// nextAvailableChunkDays is how many days are fetched per Xola request while scanning, so a
+// session in the next few days is found with one request
+const nextAvailableChunkDays = 7
+
+// nextAvailable is the structured /api/nextAvailable response, Slot is nil when nothing is open within the horizon
+type nextAvailable struct {
+	Date string    `json:"date,omitempty"`
+	Slot *timeSlot `json:"slot"`
+	Days int       `json:"searchedDays"`
+}
+
+// nextAvailableHorizon is the number of days to search from ?days=, NEXT_AVAILABLE_DAYS or the default, capped at maxRangeDays
+func nextAvailableHorizon(r *http.Request) int {
+	horizon := defaultNextAvailableDays
+	for _, value := range []string{r.URL.Query().Get("days"), os.Getenv("NEXT_AVAILABLE_DAYS")} {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			horizon = days
+			break
+		}
+	}
+	if horizon > maxRangeDays {
+		horizon = maxRangeDays
+	}
+	return horizon
+}
+
+// firstOpenSession finds the earliest open session for the date, skipping sessions that already started when the date is today
+func firstOpenSession(date string, skateTimesMap map[string]map[string]int, opts formatOptions, now time.Time) string {
+	keys, _ := xola.CleanSkateTimes(date, skateTimesMap)
+	for _, skateTime := range opts.filter(keys) {
+		if date == now.Format("2006-01-02") && skateTime < now.Format("1504") {
+			continue
+		}
+		return skateTime
+	}
+	return ""
+}
+
+// nextAvailableHandler scans forward from today a chunk of days at a time and stops at the first open session
+func nextAvailableHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := endpointFormat(w, r, "text", "json", "yaml")
+	if !ok {
+		return
+	}
+	now := time.Now().In(venueLocation())
+	today, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	horizon := nextAvailableHorizon(r)
+	dates := rangeDates(today, today.AddDate(0, 0, horizon-1))
+	opts := formatOptionsFromRequest(r)
+
+	result := nextAvailable{Days: horizon}
+	var dateObj time.Time
+	var skateTime string
+	var spots int
+	for start := 0; start < len(dates) && skateTime == ""; start += nextAvailableChunkDays {
+		end := start + nextAvailableChunkDays
+		if end > len(dates) {
+			end = len(dates)
+		}
+		skateTimesMap, waitlists, err := queryOpenDays(r.Context(), dates[start:end])
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+		for _, day := range dates[start:end] {
+			date := day.Format("2006-01-02")
+			if skateTime = firstOpenSession(date, skateTimesMap, opts, now); skateTime != "" {
+				if pricingEnabled() {
+					opts.price = queryExperiencePrice(r.Context())
+				}
+				opts.waitlists = waitlists[date]
+				result.Date = date
+				result.Slot = findSlot(date, skateTimesMap, opts, skateTime)
+				_, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+				spots = cleanedMap[skateTime]
+				dateObj = day
+				break
+			}
+		}
+	}
+
+	switch format {
+	case "json":
+		writeJSONResponse(w, result)
+	case "yaml":
+		writeYAMLResponse(w, result)
+	default:
+		var sb strings.Builder
+		if result.Slot == nil {
+			sb.WriteString("No open sessions in the next " + pluralize(horizon, "day") + "\n")
+		} else {
+			timeObj, _ := time.Parse("1504", skateTime)
+			sb.WriteString("Next available: " + dateObj.Format("Mon Jan 2") + " at " + timeObj.Format("3:04 PM") + " with " + spotsText(spots) + "\n")
+		}
+		writeSuccessResponse(w, &sb)
+	}
+}
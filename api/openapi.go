@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiParam is one query parameter or header of an operation. Values are checked against type
+// ("string" or "integer") and, when set, enum.
+type apiParam struct {
+	name        string
+	in          string
+	kind        string
+	enum        []string
+	description string
+}
+
+// apiOperation describes one public route for /openapi.json and request validation. response is
+// a value of the JSON response type, it's only used for its shape.
+type apiOperation struct {
+	route    string
+	methods  []string
+	summary  string
+	params   []apiParam
+	response interface{}
+}
+
+// flagValues are what the on/off query parameters accept, only "1" turns them on
+var flagValues = []string{"0", "1"}
+
+// shared parameters, every availability route takes the date, rink and cache bypass
+var (
+	dateParams = []apiParam{
+		{name: "date", in: "query", kind: "string", description: "YYYY-MM-DD, MM/DD/YYYY, Jan 2, 2006 or a casual date like tomorrow or next saturday. Defaults to today."},
+		{name: "start", in: "query", kind: "string", description: "Same as date."},
+		{name: "startDate", in: "header", kind: "string", description: "Same as date, how the Apple Shortcut sends it."},
+	}
+	commonParams = []apiParam{
+		{name: "rink", in: "query", kind: "string", description: "One of the configured rinks, bp by default."},
+		{name: "fresh", in: "query", kind: "string", enum: []string{"0", "1", "false", "true"}, description: "Skip the availability cache."},
+	}
+	slotParams = []apiParam{
+		// no enum, formats that aren't enabled get 406 from the handler rather than 400
+		{name: "format", in: "query", kind: "string", description: "text, json, yaml, voice, csv, html, slack, ics, shortcut or homeassistant (as enabled by ENABLED_FORMATS), also negotiated with Accept."},
+		{name: "accessibleOnly", in: "query", kind: "string", enum: flagValues, description: "Only ACCESSIBLE_SESSIONS."},
+		{name: "includeSoldOut", in: "query", kind: "string", enum: flagValues, description: "Also list sold out sessions."},
+		{name: "surface", in: "query", kind: "string", enum: []string{surfaceOutdoor, surfaceIndoor}, description: "Only sessions on this rink surface."},
+		{name: "iso", in: "query", kind: "string", enum: flagValues, description: "Full ISO 8601 datetimes in slot times."},
+		{name: "relative", in: "query", kind: "string", enum: flagValues, description: "Relative day in the text header."},
+	}
+)
+
+// apiOperations are the public routes, relative to the version prefix (see routes.go)
+var apiOperations = []apiOperation{
+	{
+		route: "", methods: []string{http.MethodGet}, summary: "Sessions with open spots for a date, or every day of a date range",
+		params: concatParams(dateParams, commonParams, slotParams, []apiParam{
+			{name: "end", in: "query", kind: "string", description: "Last day of a range, up to 62 days."},
+			{name: "endDate", in: "header", kind: "string", description: "Same as end."},
+			{name: "group", in: "query", kind: "string", enum: flagValues, description: "Text split under daypart headers."},
+			{name: "extremes", in: "query", kind: "string", enum: flagValues, description: "Only the quietest and fullest sessions."},
+			{name: "qr", in: "query", kind: "string", enum: flagValues, description: "PNG QR code for the booking page."},
+			{name: "since", in: "query", kind: "string", description: "Digest from a previous response, 204 while it still matches."},
+			{name: "pageSize", in: "query", kind: "integer", description: "Slots per page, counted across days for ranges. A day split between pages shows up on both."},
+			{name: "pageToken", in: "query", kind: "string", description: "nextToken from the previous page."},
+		}),
+		response: availability{},
+	},
+	{route: "/digest", methods: []string{http.MethodGet}, summary: "Short hash of the date's availability", params: concatParams(dateParams, commonParams)},
+	{
+		route: "/history", methods: []string{http.MethodGet}, summary: "Total open spots for the date across stored snapshots",
+		params:   concatParams(dateParams, commonParams, []apiParam{{name: "slots", in: "query", kind: "string", enum: flagValues, description: "Per-slot counts too."}}),
+		response: history{},
+	},
+	{
+		route: "/batch", methods: []string{http.MethodGet, http.MethodPost}, summary: "Several specific dates in one response",
+		params:   concatParams(commonParams, slotParams, []apiParam{{name: "dates", in: "query", kind: "string", description: "Comma-separated dates, up to 31. POST {\"dates\": [...]} works too."}}),
+		response: availabilityBatch{},
+	},
+	{
+		route: "/nextAvailable", methods: []string{http.MethodGet}, summary: "The first session with open spots from now on",
+		params:   concatParams(commonParams, slotParams, []apiParam{{name: "days", in: "query", kind: "integer", description: "How many days ahead to search."}}),
+		response: nextAvailable{},
+	},
+	{route: "/week", methods: []string{http.MethodGet}, summary: "One line per day for the next 7 days", params: concatParams(commonParams, slotParams), response: weekSummary{}},
+	{
+		route: "/shortcut", methods: []string{http.MethodGet}, summary: "Flat list of sessions for iOS Shortcuts",
+		params: concatParams(dateParams, commonParams, slotParams, []apiParam{
+			{name: "end", in: "query", kind: "string", description: "Last day of a range, up to 62 days."},
+			{name: "token", in: "query", kind: "string", description: "API key, for Shortcuts that don't send headers."},
+		}),
+		response: []shortcutSession{},
+	},
+	{
+		route: "/homeassistant", methods: []string{http.MethodGet}, summary: "Open spots as a Home Assistant REST sensor",
+		params: concatParams(dateParams, commonParams, slotParams, []apiParam{
+			{name: "end", in: "query", kind: "string", description: "Last day of a range, up to 62 days."},
+			{name: "token", in: "query", kind: "string", description: "API key, when not sent as a header."},
+		}),
+		response: homeAssistantSensor{},
+	},
+}
+
+func concatParams(groups ...[]apiParam) []apiParam {
+	var params []apiParam
+	for _, group := range groups {
+		params = append(params, group...)
+	}
+	return params
+}
+
+// validateRequest checks the request's query parameters and headers against the route's
+// operation. Parameters the spec doesn't know are left alone.
+func validateRequest(route string, r *http.Request) string {
+	for _, op := range apiOperations {
+		if op.route != route {
+			continue
+		}
+		query := r.URL.Query()
+		for _, param := range op.params {
+			value := query.Get(param.name)
+			if param.in == "header" {
+				value = r.Header.Get(param.name)
+			}
+			if value == "" {
+				continue
+			}
+			if param.kind == "integer" {
+				if _, err := strconv.Atoi(value); err != nil {
+					return param.name + " must be a whole number"
+				}
+			}
+			if len(param.enum) > 0 && !containsString(param.enum, strings.ToLower(value)) {
+				return param.name + " must be one of " + strings.Join(param.enum, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIHandler serves the OpenAPI 3 document for the public routes, built from apiOperations
+// and the response structs' json tags so it can't drift from what the handlers send
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{"summary": op.summary}
+		var params []map[string]interface{}
+		for _, param := range op.params {
+			schema := map[string]interface{}{"type": param.kind}
+			if len(param.enum) > 0 {
+				schema["enum"] = param.enum
+			}
+			params = append(params, map[string]interface{}{"name": param.name, "in": param.in, "description": param.description, "schema": schema})
+		}
+		operation["parameters"] = params
+		content := map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]string{"type": "string"}}}
+		if op.response != nil {
+			content["application/json"] = map[string]interface{}{"schema": openAPISchema(reflect.TypeOf(op.response), schemas)}
+		}
+		operation["responses"] = map[string]interface{}{
+			"200": map[string]interface{}{"description": "OK", "content": content},
+			"400": map[string]interface{}{"description": "Bad date or parameter"},
+			"403": map[string]interface{}{"description": "Missing or wrong API key"},
+			"502": map[string]interface{}{"description": "Xola answered with an error"},
+		}
+
+		item := map[string]interface{}{}
+		for _, method := range op.methods {
+			item[strings.ToLower(method)] = operation
+		}
+		paths["/"+currentVersion+op.route] = item
+	}
+
+	writeJSONResponse(w, map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]string{"title": "Bryant Park skating availability", "version": currentVersion},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	})
+}
+
+// openAPISchema describes a Go type the way encoding/json writes it. Structs become named
+// components (by type name) and are referenced.
+func openAPISchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, done := schemas[t.Name()]; done {
+			return ref
+		}
+		// placeholder first, availability refers to itself through previousDay
+		schemas[t.Name()] = nil
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			if field.PkgPath != "" || tag[0] == "-" || tag[0] == "" {
+				continue
+			}
+			properties[tag[0]] = openAPISchema(field.Type, schemas)
+			if len(tag) == 1 {
+				required = append(required, tag[0])
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+	return map[string]interface{}{"type": "string"}
+}
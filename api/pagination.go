@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// pageCursor is the continuation state behind a nextToken. Clients treat the token as opaque.
+type pageCursor struct {
+	Date   string `json:"d"`
+	Offset int    `json:"o"`
+}
+
+func encodePageToken(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(token string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.Offset < 0 {
+		return cursor, errors.New("bad pageToken")
+	}
+	return cursor, nil
+}
+
+// paginateAvailability trims the (already time-sorted) slots to ?pageSize starting at ?pageToken,
+// and sets NextToken when more slots remain. Without pageSize the response is left whole.
+func paginateAvailability(result *availability, r *http.Request) error {
+	query := r.URL.Query()
+	if query.Get("pageSize") == "" {
+		if query.Get("pageToken") != "" {
+			return errors.New("pageToken requires pageSize")
+		}
+		return nil
+	}
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		return errors.New("pageSize must be a positive number")
+	}
+
+	offset := 0
+	if token := query.Get("pageToken"); token != "" {
+		cursor, err := decodePageToken(token)
+		if err != nil {
+			return err
+		}
+		// a token from another date would silently skip slots
+		if cursor.Date != result.Date {
+			return errors.New("pageToken is for a different date")
+		}
+		offset = cursor.Offset
+	}
+
+	if offset > len(result.Slots) {
+		offset = len(result.Slots)
+	}
+	end := offset + pageSize
+	if end < len(result.Slots) {
+		result.NextToken = encodePageToken(pageCursor{Date: result.Date, Offset: end})
+	} else {
+		end = len(result.Slots)
+	}
+	result.Slots = result.Slots[offset:end]
+	return nil
+}
+
+// paginateRange is paginateAvailability across days: pages hold up to ?pageSize slots in day then
+// time order, and the cursor remembers the day and the offset into it. A day split across pages
+// shows up on both with its slots divided between them.
+func paginateRange(result *availabilityRange, r *http.Request) error {
+	query := r.URL.Query()
+	if query.Get("pageSize") == "" {
+		if query.Get("pageToken") != "" {
+			return errors.New("pageToken requires pageSize")
+		}
+		return nil
+	}
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		return errors.New("pageSize must be a positive number")
+	}
+
+	cursor := pageCursor{Date: result.Start}
+	if token := query.Get("pageToken"); token != "" {
+		if cursor, err = decodePageToken(token); err != nil {
+			return err
+		}
+		if cursor.Date < result.Start || cursor.Date > result.End {
+			return errors.New("pageToken is for a different date range")
+		}
+	}
+
+	var page []availability
+	remaining := pageSize
+	for _, day := range result.Days {
+		if day.Date < cursor.Date {
+			continue
+		}
+		offset := 0
+		if day.Date == cursor.Date {
+			offset = cursor.Offset
+		}
+		if offset > len(day.Slots) {
+			offset = len(day.Slots)
+		}
+		if remaining == 0 {
+			// page is full, continue from the start of this day (or where we left off in it)
+			result.NextToken = encodePageToken(pageCursor{Date: day.Date, Offset: offset})
+			break
+		}
+		end := offset + remaining
+		if end > len(day.Slots) {
+			end = len(day.Slots)
+		}
+		remaining -= end - offset
+		if end < len(day.Slots) {
+			result.NextToken = encodePageToken(pageCursor{Date: day.Date, Offset: end})
+		}
+		day.Slots = day.Slots[offset:end]
+		page = append(page, day)
+		if result.NextToken != "" {
+			break
+		}
+	}
+	if page == nil {
+		page = []availability{}
+	}
+	result.Days = page
+	return nil
+}
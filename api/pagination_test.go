@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPaginateDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageSize string
+		pages    []string
+	}{
+		{"two per page", "2", []string{"09:00 10:00", "11:00 12:00", "13:00"}},
+		{"page bigger than the day", "10", []string{"09:00 10:00 11:00 12:00 13:00"}},
+		{"exact fit", "5", []string{"09:00 10:00 11:00 12:00 13:00"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"1300": 1, "900": 1, "1100": 1, "1000": 1, "1200": 1}}`)
+			var pages []string
+			token := ""
+			for i := 0; i < 10; i++ {
+				target := "/api?date=2024-01-02&format=json&pageSize=" + test.pageSize
+				if token != "" {
+					target += "&pageToken=" + url.QueryEscape(token)
+				}
+				var page availability
+				w := get(t, target)
+				if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+					t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+				}
+				var times []string
+				for _, slot := range page.Slots {
+					times = append(times, slot.Time)
+				}
+				pages = append(pages, strings.Join(times, " "))
+				if token = page.NextToken; token == "" {
+					break
+				}
+			}
+			if strings.Join(pages, " | ") != strings.Join(test.pages, " | ") {
+				t.Errorf("pages %q, want %q", pages, test.pages)
+			}
+		})
+	}
+}
+
+func TestPaginateRange(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"900": 1, "1000": 1, "1100": 1}, "2024-01-03": {}, "2024-01-04": {"900": 1, "1000": 1}}`)
+	var pages []string
+	token := ""
+	for i := 0; i < 10; i++ {
+		target := "/api?date=2024-01-02&end=2024-01-04&format=json&pageSize=2"
+		if token != "" {
+			target += "&pageToken=" + url.QueryEscape(token)
+		}
+		var page availabilityRange
+		w := get(t, target)
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+		}
+		var days []string
+		for _, day := range page.Days {
+			var times []string
+			for _, slot := range day.Slots {
+				times = append(times, slot.Time)
+			}
+			days = append(days, day.Date[8:]+"="+strings.Join(times, ","))
+		}
+		pages = append(pages, strings.Join(days, " "))
+		if token = page.NextToken; token == "" {
+			break
+		}
+	}
+	// pages count slots, a split day shows up on both sides of the split
+	want := []string{"02=09:00,10:00", "02=11:00 03= 04=09:00", "04=10:00"}
+	if strings.Join(pages, " | ") != strings.Join(want, " | ") {
+		t.Errorf("pages %q, want %q", pages, want)
+	}
+}
+
+func TestPaginationErrors(t *testing.T) {
+	otherDay := encodePageToken(pageCursor{Date: "2024-01-09", Offset: 1})
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"token without a size", "/api?date=2024-01-02&format=json&pageToken=" + otherDay},
+		{"zero size", "/api?date=2024-01-02&format=json&pageSize=0"},
+		{"garbage token", "/api?date=2024-01-02&format=json&pageSize=2&pageToken=!!!"},
+		{"token for another date", "/api?date=2024-01-02&format=json&pageSize=2&pageToken=" + otherDay},
+		{"token outside the range", "/api?date=2024-01-02&end=2024-01-04&format=json&pageSize=2&pageToken=" + otherDay},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"900": 1}}`)
+			if w := get(t, test.target); w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d (%s), want 400", w.Code, w.Body.String())
+			}
+		})
+	}
+}
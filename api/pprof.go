@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+)
+
+// pprofPrefix is where the profiles are served, the path net/http/pprof expects
+const pprofPrefix = "/debug/pprof"
+
+// pprofHandler serves net/http/pprof when PPROF=1, to the ADMIN_TOKEN only. Profiles are 404
+// otherwise, nobody should be able to tell they exist.
+func pprofHandler(w http.ResponseWriter, r *http.Request) {
+	if os.Getenv("PPROF") != "1" {
+		http.NotFound(w, r)
+		return
+	}
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	// pprof.Index reads the profile name off the path, so strip any /v1 or /api in front
+	name := strings.TrimPrefix(strings.TrimPrefix(routePath(r.URL.Path), pprofPrefix), "/")
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = pprofPrefix + "/" + name
+	switch name {
+	case "cmdline":
+		pprof.Cmdline(w, r2)
+	case "profile":
+		pprof.Profile(w, r2)
+	case "symbol":
+		pprof.Symbol(w, r2)
+	case "trace":
+		pprof.Trace(w, r2)
+	default:
+		pprof.Index(w, r2)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// experiencePricing is the part of the Xola experience payload that carries the session price
+type experiencePricing struct {
+	Price    *float64 `json:"price"`
+	Currency string   `json:"currency"`
+}
+
+// currencySymbols covers the currencies Xola venues we care about are likely to use
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"CAD": "CA$",
+	"EUR": "€",
+	"GBP": "£",
+}
+
+// commaDecimalLocales write prices as 1.234,50 instead of 1,234.50
+var commaDecimalLocales = map[string]bool{
+	"de": true,
+	"es": true,
+	"fr": true,
+	"it": true,
+	"nl": true,
+}
+
+func pricingEnabled() bool {
+	return os.Getenv("SHOW_PRICES") == "1"
+}
+
+// experiencePrices caches each rink's formatted price for the availability TTL, prices change far
+// less often than spots. Past it, an entry is still served while the circuit breaker is open.
+var experiencePrices = struct {
+	sync.Mutex
+	byRink map[string]cachedPrice
+}{byRink: map[string]cachedPrice{}}
+
+type cachedPrice struct {
+	price     string
+	fetchedAt time.Time
+}
+
+// queryExperiencePrice is the rink's experience price from Xola, formatted, or "" when Xola has no
+// price so callers can leave it out. Only the experience's base price is read, there are no
+// per-session prices, so every slot shows the same "from" price.
+func queryExperiencePrice(ctx context.Context) string {
+	rinkName := rinkFromContext(ctx).name
+	experiencePrices.Lock()
+	cached, ok := experiencePrices.byRink[rinkName]
+	experiencePrices.Unlock()
+	// price lookups never take the half-open probe (allow() would, and only an availability
+	// lookup records the result), they just wait for the breaker to close again
+	closed := xolaBreaker.state() == "closed"
+	if ok && (time.Since(cached.fetchedAt) < cacheTTL() || !closed) {
+		return cached.price
+	}
+	if !closed {
+		return ""
+	}
+	res, err := getWithTimeout(ctx, experienceURL(ctx))
+	if err != nil {
+		slog.WarnContext(ctx, "could not fetch experience price", "error", err)
+		return cached.price
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	price := ""
+	var pricing experiencePricing
+	if err := json.Unmarshal(data, &pricing); err == nil && pricing.Price != nil {
+		price = formatExperiencePrice(pricing)
+	}
+	experiencePrices.Lock()
+	experiencePrices.byRink[rinkName] = cachedPrice{price: price, fetchedAt: time.Now()}
+	experiencePrices.Unlock()
+	return price
+}
+
+// formatExperiencePrice is the price as shown, in PRICE_CURRENCY and PRICE_LOCALE
+func formatExperiencePrice(pricing experiencePricing) string {
+	// PRICE_CURRENCY overrides whatever currency Xola reports
+	currency := os.Getenv("PRICE_CURRENCY")
+	if currency == "" {
+		currency = pricing.Currency
+	}
+	return formatPrice(*pricing.Price, currency, os.Getenv("PRICE_LOCALE"))
+}
+
+// formatPrice renders the amount with the currency symbol, dropping cents for whole amounts ("$25", "$25.50")
+func formatPrice(amount float64, currency string, locale string) string {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+
+	decimals := 2
+	if amount == math.Trunc(amount) {
+		decimals = 0
+	}
+	number := strconv.FormatFloat(amount, 'f', decimals, 64)
+	whole, cents := number, ""
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		whole, cents = number[:i], number[i+1:]
+	}
+
+	thousands, decimal := ",", "."
+	language := strings.ToLower(strings.SplitN(strings.Replace(locale, "_", "-", 1), "-", 2)[0])
+	if commaDecimalLocales[language] {
+		thousands, decimal = ".", ","
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+	if cents != "" {
+		grouped.WriteString(decimal + cents)
+	}
+	return symbol + grouped.String()
+}
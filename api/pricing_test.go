@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		locale   string
+		want     string
+	}{
+		{25, "USD", "", "$25"},
+		{25.5, "usd", "", "$25.50"},
+		{1234.5, "USD", "en-US", "$1,234.50"},
+		{1234.5, "EUR", "de-DE", "€1.234,50"},
+		{1234.5, "EUR", "fr_FR", "€1.234,50"},
+		{18, "", "", "$18"},
+		{18, "CAD", "", "CA$18"},
+		{18, "CHF", "", "CHF 18"},
+	}
+	for _, test := range tests {
+		if got := formatPrice(test.amount, test.currency, test.locale); got != test.want {
+			t.Errorf("formatPrice(%v, %q, %q) = %q, want %q", test.amount, test.currency, test.locale, got, test.want)
+		}
+	}
+}
+
+func TestPricesInResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		experience string
+		currency   string
+		text       string
+		price      string
+	}{
+		{"price from Xola", `{"price": 25, "currency": "USD"}`, "", "3:00 PM — from $25 — 4 spots", "$25"},
+		{"currency overridden", `{"price": 25, "currency": "USD"}`, "GBP", "3:00 PM — from £25 — 4 spots", "£25"},
+		{"no price", `{"name": "Free skate"}`, "", "3:00 PM has 4 spots", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+			stub.price(test.experience)
+			t.Setenv("SHOW_PRICES", "1")
+			t.Setenv("PRICE_CURRENCY", test.currency)
+
+			if w := get(t, "/api?date=2024-01-02"); !strings.Contains(w.Body.String(), test.text+"\n") {
+				t.Errorf("text = %q, want a line %q", w.Body.String(), test.text)
+			}
+			var body struct {
+				Slots []struct {
+					Price string `json:"price"`
+				} `json:"slots"`
+			}
+			w := get(t, "/api?date=2024-01-02&format=json")
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Slots) != 1 || body.Slots[0].Price != test.price {
+				t.Errorf("json = %s, want price %q", w.Body.String(), test.price)
+			}
+			if stub.experienceCalls() != 1 {
+				t.Errorf("the experience was fetched %d times, want once", stub.experienceCalls())
+			}
+		})
+	}
+}
+
+func TestPriceOffByDefault(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	stub.price(`{"price": 25}`)
+	if w := get(t, "/api?date=2024-01-02"); strings.Contains(w.Body.String(), "$") {
+		t.Errorf("text = %q, want no price without SHOW_PRICES", w.Body.String())
+	}
+	if stub.experienceCalls() != 0 {
+		t.Errorf("the experience was fetched %d times without SHOW_PRICES", stub.experienceCalls())
+	}
+}
+
+func TestPriceWhileCircuitOpen(t *testing.T) {
+	stub := newXolaStub(t, `{}`)
+	stub.price(`{"price": 25}`)
+	ctx := withRink(context.Background(), configuredRinks()[defaultRink])
+	openBreaker := func() {
+		xolaBreaker.Lock()
+		xolaBreaker.open, xolaBreaker.openedAt = true, time.Now()
+		xolaBreaker.Unlock()
+	}
+
+	openBreaker()
+	if price := queryExperiencePrice(ctx); price != "" || stub.experienceCalls() != 0 {
+		t.Errorf("price %q after %d calls with the breaker open and nothing cached", price, stub.experienceCalls())
+	}
+
+	resetAvailability(t)
+	t.Setenv("XOLA_BASE_URL", stub.URL)
+	t.Setenv("CACHE_TTL_SECONDS", "0")
+	if price := queryExperiencePrice(ctx); price != "$25" {
+		t.Fatalf("price = %q, want $25", price)
+	}
+	openBreaker()
+	if price := queryExperiencePrice(ctx); price != "$25" || stub.experienceCalls() != 1 {
+		t.Errorf("price %q after %d calls, want the cached $25 without asking Xola again", price, stub.experienceCalls())
+	}
+}
+
+func TestPriceLeavesTheProbe(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	stub.price(`{"price": 25}`)
+	t.Setenv("SHOW_PRICES", "1")
+	// open, with the cooldown over: the next call to Xola is the half-open probe
+	xolaBreaker.Lock()
+	xolaBreaker.open, xolaBreaker.openedAt = true, time.Now().Add(-time.Hour)
+	xolaBreaker.Unlock()
+
+	ctx := withRink(context.Background(), configuredRinks()[defaultRink])
+	if price := queryExperiencePrice(ctx); price != "" || stub.experienceCalls() != 0 {
+		t.Errorf("price %q after %d calls, want the price lookup to leave the probe alone", price, stub.experienceCalls())
+	}
+	if w := get(t, "/api?date=2024-01-02"); w.Code != http.StatusOK || stub.calls() != 1 {
+		t.Fatalf("status %d after %d Xola calls, want the availability lookup to probe", w.Code, stub.calls())
+	}
+	if state := xolaBreaker.state(); state != "closed" {
+		t.Errorf("breaker %s after a good probe", state)
+	}
+	if price := queryExperiencePrice(ctx); price != "$25" {
+		t.Errorf("price = %q once the breaker closed", price)
+	}
+}
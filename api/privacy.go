@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// spotsFloor reads SPOTS_FLOOR, counts at or below it are shown as "limited" instead of the exact number.
+// 0 (the default) shows every count.
+func spotsFloor() int {
+	value := os.Getenv("SPOTS_FLOOR")
+	if value == "" {
+		return 0
+	}
+	floor, err := strconv.Atoi(value)
+	if err != nil || floor < 0 {
+		slog.Warn("ignoring bad setting", "key", "SPOTS_FLOOR", "value", value)
+		return 0
+	}
+	return floor
+}
+
+// isLimited reports whether an open slot's count should be masked. Sold out slots are never masked.
+func isLimited(count int) bool {
+	return count > 0 && count <= spotsFloor()
+}
+
+// spotsText is "4 spots", or "limited spots" when the count is masked
+func spotsText(count int) string {
+	if isLimited(count) {
+		return "limited spots"
+	}
+	return strconv.Itoa(count) + " spots"
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSpotsFloor(t *testing.T) {
+	tests := []struct {
+		floor string
+		count int
+		want  string
+	}{
+		{"", 1, "1 spots"},
+		{"3", 0, "0 spots"},
+		{"3", 1, "limited spots"},
+		{"3", 3, "limited spots"},
+		{"3", 4, "4 spots"},
+		{"-1", 1, "1 spots"},
+		{"lots", 1, "1 spots"},
+	}
+	for _, test := range tests {
+		resetAvailability(t)
+		t.Setenv("SPOTS_FLOOR", test.floor)
+		if got := spotsText(test.count); got != test.want {
+			t.Errorf("SPOTS_FLOOR=%q: spotsText(%d) = %q, want %q", test.floor, test.count, got, test.want)
+		}
+	}
+}
+
+func TestSpotsFloorInResponses(t *testing.T) {
+	payload := `{"2024-01-02": {"1500": 2, "1600": 3, "1700": 9, "1800": 0}}`
+	newXolaStub(t, payload)
+	t.Setenv("SPOTS_FLOOR", "3")
+
+	want := "For Jan 2, 2024:\n3:00 PM has limited spots\n4:00 PM has limited spots\n5:00 PM has 9 spots\n6:00 PM SOLD OUT\n"
+	if w := get(t, "/api?date=2024-01-02&includeSoldOut=1"); w.Body.String() != want {
+		t.Errorf("text = %q, want %q", w.Body.String(), want)
+	}
+
+	var body availability
+	if err := json.Unmarshal(get(t, "/api?date=2024-01-02&includeSoldOut=1&format=json").Body.Bytes(), &body); err != nil || len(body.Slots) != 4 {
+		t.Fatalf("slots %+v, %v", body.Slots, err)
+	}
+	for i, want := range []struct {
+		spots   int
+		limited bool
+	}{{0, true}, {0, true}, {9, false}, {0, false}} {
+		if slot := body.Slots[i]; slot.Spots != want.spots || slot.Limited != want.limited {
+			t.Errorf("%s = %d spots, limited %v, want %d, %v", slot.Time, slot.Spots, slot.Limited, want.spots, want.limited)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kvPushHash is the KV hash push subscriptions live in, one field per endpoint, rink and date
+const kvPushHash = "bp-skate:push"
+
+// pushTTL is how long a push service holds a message for a browser that's offline
+const pushTTL = 24 * time.Hour
+
+// pushRecordSize is the record size in the aes128gcm header, messages are a single short record
+const pushRecordSize = 4096
+
+// pushSubscription is a browser's PushSubscription (as its toJSON() gives it) watching one date at
+// one rink. Subscriptions from before rinks have no Rink and watch bp.
+type pushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	Date      string    `json:"date"`
+	Rink      string    `json:"rink,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (sub pushSubscription) rinkName() string {
+	if sub.Rink == "" {
+		return defaultRink
+	}
+	return sub.Rink
+}
+
+// id keeps the endpoint|date shape for bp so subscriptions made before rinks keep their field
+func (sub pushSubscription) id() string {
+	if sub.rinkName() == defaultRink {
+		return sub.Endpoint + "|" + sub.Date
+	}
+	return sub.Endpoint + "|" + sub.rinkName() + "|" + sub.Date
+}
+
+// pushStore is where subscriptions are kept: the KV store when one is configured, otherwise this
+// instance's memory, same as the managed API keys
+type pushStore interface {
+	all() []pushSubscription
+	put(sub pushSubscription)
+	remove(id string) bool
+}
+
+func pushSubscriptions() pushStore {
+	if store, ok := kvStoreFromEnv(); ok {
+		return kvPushStore{store}
+	}
+	return localPush
+}
+
+type memoryPushStore struct {
+	sync.Mutex
+	byID map[string]pushSubscription
+}
+
+var localPush = &memoryPushStore{byID: map[string]pushSubscription{}}
+
+func (store *memoryPushStore) all() []pushSubscription {
+	store.Lock()
+	defer store.Unlock()
+	subs := make([]pushSubscription, 0, len(store.byID))
+	for _, sub := range store.byID {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (store *memoryPushStore) put(sub pushSubscription) {
+	store.Lock()
+	defer store.Unlock()
+	store.byID[sub.id()] = sub
+}
+
+func (store *memoryPushStore) remove(id string) bool {
+	store.Lock()
+	defer store.Unlock()
+	_, ok := store.byID[id]
+	delete(store.byID, id)
+	return ok
+}
+
+type kvPushStore struct {
+	kv kvStore
+}
+
+func (store kvPushStore) all() []pushSubscription {
+	var fields []string
+	if err := store.kv.command(&fields, "HGETALL", kvPushHash); err != nil {
+		slog.Warn("KV push subscription lookup failed", "error", err)
+		return nil
+	}
+	var subs []pushSubscription
+	for i := 0; i+1 < len(fields); i += 2 {
+		var sub pushSubscription
+		if json.Unmarshal([]byte(fields[i+1]), &sub) == nil {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+func (store kvPushStore) put(sub pushSubscription) {
+	data, _ := json.Marshal(sub)
+	var added int
+	if err := store.kv.command(&added, "HSET", kvPushHash, sub.id(), string(data)); err != nil {
+		slog.Warn("KV push subscription save failed", "error", err)
+	}
+}
+
+func (store kvPushStore) remove(id string) bool {
+	var removed int
+	if err := store.kv.command(&removed, "HDEL", kvPushHash, id); err != nil {
+		slog.Warn("KV push subscription delete failed", "error", err)
+	}
+	return removed > 0
+}
+
+// vapidKey is VAPID_PRIVATE_KEY (the raw 32 byte scalar, base64url like web-push prints it) with
+// the public key it belongs to
+func vapidKey() (*ecdsa.PrivateKey, []byte, error) {
+	scalar, err := base64.RawURLEncoding.DecodeString(os.Getenv("VAPID_PRIVATE_KEY"))
+	if err != nil {
+		return nil, nil, errors.New("VAPID_PRIVATE_KEY isn't base64url")
+	}
+	private, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, nil, errors.New("VAPID_PRIVATE_KEY isn't a P-256 key")
+	}
+	public := private.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])},
+		D:         new(big.Int).SetBytes(scalar),
+	}
+	return key, public, nil
+}
+
+// vapidAuthorization is the Authorization header for a push service: an ES256 JWT for its origin,
+// signed with the VAPID key, and the public key to check it with (RFC 8292)
+func vapidAuthorization(endpoint string, now time.Time) (string, error) {
+	key, public, err := vapidKey()
+	if err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" {
+		return "", errors.New("push endpoint isn't an https URL")
+	}
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:admin@example.com"
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{"aud": parsed.Scheme + "://" + parsed.Host, "exp": now.Add(12 * time.Hour).Unix(), "sub": subject})
+	signingInput := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return "vapid t=" + signingInput + "." + encode(signature) + ", k=" + encode(public), nil
+}
+
+// hkdf is HKDF-SHA256 for a single block of output, all Web Push ever needs
+func hkdf(salt []byte, secret []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// encryptPush encrypts the payload for the subscription, aes128gcm content coding (RFC 8291, 8188)
+func encryptPush(sub pushSubscription, payload []byte) ([]byte, error) {
+	userPublic, err := base64.RawURLEncoding.DecodeString(sub.Keys.P256dh)
+	if err != nil {
+		return nil, errors.New("bad p256dh key")
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(sub.Keys.Auth)
+	if err != nil {
+		return nil, errors.New("bad auth secret")
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userPublic)
+	if err != nil {
+		return nil, errors.New("bad p256dh key")
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), userPublic...), serverPublic...)
+	ikm := hkdf(authSecret, shared, keyInfo, 32)
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	contentKey := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record
+	sealed := gcm.Seal(nil, nonce, append(payload, 2), nil)
+
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(pushRecordSize))
+	body.WriteByte(byte(len(serverPublic)))
+	body.Write(serverPublic)
+	body.Write(sealed)
+	return body.Bytes(), nil
+}
+
+// errPushGone is a subscription the push service says no longer exists
+var errPushGone = errors.New("push subscription expired")
+
+// sendPush delivers one message
+func sendPush(ctx context.Context, sub pushSubscription, payload []byte) error {
+	body, err := encryptPush(sub, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := vapidAuthorization(sub.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushGone
+	case resp.StatusCode >= 300:
+		return errors.New("push service answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// pushMessage is what the service worker gets, it shows title and body and opens url on click
+type pushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+}
+
+// pushOpenings notifies the browsers watching the date at the rink when sessions on it open up. Subscriptions
+// the push service has dropped, and ones for dates that have passed, are removed on the way.
+func pushOpenings(rk rink, date string, changes []availabilityChange) {
+	opened := openings(changes)
+	if len(opened) == 0 || os.Getenv("VAPID_PRIVATE_KEY") == "" {
+		return
+	}
+	store := pushSubscriptions()
+	today := time.Now().In(venueLocation()).Format("2006-01-02")
+	var watching []pushSubscription
+	for _, sub := range store.all() {
+		if sub.Date < today {
+			store.remove(sub.id())
+		} else if sub.Date == date && strings.EqualFold(sub.rinkName(), rk.name) {
+			watching = append(watching, sub)
+		}
+	}
+	if len(watching) == 0 {
+		return
+	}
+	dateObj, _ := time.Parse("2006-01-02", date)
+	title := "Skating spots opened for " + dateObj.Format("Mon Jan 2")
+	if multiRink() {
+		title = rk.label() + " s" + title[1:]
+	}
+	payload, _ := json.Marshal(pushMessage{
+		Title: title,
+		Body:  openedSessions(opened) + ". Tap to book.",
+		URL:   bookingURL(rk, date),
+	})
+	goBackground(func() {
+		for _, sub := range watching {
+			err := sendPush(context.Background(), sub, payload)
+			if err == errPushGone {
+				store.remove(sub.id())
+			} else if err != nil {
+				slog.Warn("push delivery failed", "rink", rk.name, "date", date, "error", err)
+			}
+		}
+	})
+}
+
+// pushWatching is the GET answer: dates stays the plain list it always was, subscriptions says
+// which rink each is at
+type pushWatching struct {
+	Dates         []string    `json:"dates"`
+	Subscriptions []pushWatch `json:"subscriptions"`
+}
+
+type pushWatch struct {
+	Date string `json:"date"`
+	Rink string `json:"rink"`
+}
+
+// pushKeyHandler is the VAPID public key browsers pass to pushManager.subscribe as the
+// applicationServerKey. Web Push is off (404) until VAPID_PRIVATE_KEY is set.
+func pushKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if os.Getenv("VAPID_PRIVATE_KEY") == "" {
+		http.NotFound(w, r)
+		return
+	}
+	_, public, err := vapidKey()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSONResponse(w, map[string]string{"publicKey": base64.RawURLEncoding.EncodeToString(public)})
+}
+
+// pushSubscriptionsHandler registers a browser for a date (POST {"subscription": ..., "date": ...,
+// "rink": ...}, rink defaulting to bp), lists the dates one is watching (GET ?endpoint=, ?rink= for
+// just one rink) or unregisters it (DELETE ?endpoint=, all of its dates or just ?date= and ?rink=)
+func pushSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if os.Getenv("VAPID_PRIVATE_KEY") == "" {
+		http.NotFound(w, r)
+		return
+	}
+	store := pushSubscriptions()
+	endpoint := r.URL.Query().Get("endpoint")
+	rinkName := strings.ToLower(r.URL.Query().Get("rink"))
+
+	switch r.Method {
+	case http.MethodGet:
+		dates := []string{}
+		watching := []pushWatch{}
+		seen := map[string]bool{}
+		for _, sub := range store.all() {
+			if sub.Endpoint == endpoint && (rinkName == "" || sub.rinkName() == rinkName) {
+				if !seen[sub.Date] {
+					dates = append(dates, sub.Date)
+					seen[sub.Date] = true
+				}
+				watching = append(watching, pushWatch{Date: sub.Date, Rink: sub.rinkName()})
+			}
+		}
+		sort.Strings(dates)
+		sort.Slice(watching, func(i, j int) bool {
+			if watching[i].Date != watching[j].Date {
+				return watching[i].Date < watching[j].Date
+			}
+			return watching[i].Rink < watching[j].Rink
+		})
+		writeJSONResponse(w, pushWatching{Dates: dates, Subscriptions: watching})
+	case http.MethodPost:
+		var body struct {
+			Subscription pushSubscription `json:"subscription"`
+			Date         string           `json:"date"`
+			Rink         string           `json:"rink"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		sub := body.Subscription
+		if err != nil || sub.Endpoint == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+			writeJSONError(w, http.StatusBadRequest, `Expected {"subscription": <PushSubscription JSON>, "date": "YYYY-MM-DD", "rink": "..."}`)
+			return
+		}
+		if parsed, err := url.Parse(sub.Endpoint); err != nil || parsed.Scheme != "https" {
+			writeJSONError(w, http.StatusBadRequest, "The subscription endpoint must be an https URL")
+			return
+		}
+		date, _, err := normalizeDate(body.Date)
+		if err != nil {
+			writeBadDate(w, body.Date)
+			return
+		}
+		subRink := strings.ToLower(body.Rink)
+		if subRink == "" {
+			subRink = defaultRink
+		}
+		if _, ok := configuredRinks()[subRink]; !ok {
+			writeJSONError(w, http.StatusBadRequest, "Unknown rink "+body.Rink)
+			return
+		}
+		sub.Date, sub.Rink, sub.CreatedAt = date, subRink, time.Now().UTC()
+		store.put(sub)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		data, _ := json.Marshal(map[string]string{"date": date, "rink": subRink})
+		w.Write(data)
+	case http.MethodDelete:
+		removed := 0
+		for _, sub := range store.all() {
+			if sub.Endpoint == endpoint && (r.URL.Query().Get("date") == "" || sub.Date == r.URL.Query().Get("date")) && (rinkName == "" || sub.rinkName() == rinkName) && store.remove(sub.id()) {
+				removed++
+			}
+		}
+		writeJSONResponse(w, map[string]int{"removed": removed})
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET, POST or DELETE")
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pushService is a fake browser push service counting the messages it's sent
+type pushService struct {
+	*httptest.Server
+	sync.Mutex
+	received int
+}
+
+// newPushService starts one and has pushes delivered to it, with VAPID configured
+func newPushService(t *testing.T) *pushService {
+	t.Helper()
+	service := &pushService{}
+	service.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || !strings.HasPrefix(r.Header.Get("Authorization"), "vapid t=") {
+			t.Errorf("push without encryption or VAPID: %v", r.Header)
+		}
+		service.Lock()
+		service.received++
+		service.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(service.Close)
+	original := webhookClient
+	webhookClient = service.Client()
+	t.Cleanup(func() { webhookClient = original })
+
+	vapid, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAPID_PRIVATE_KEY", base64.RawURLEncoding.EncodeToString(vapid.Bytes()))
+	localPush.Lock()
+	localPush.byID = map[string]pushSubscription{}
+	localPush.Unlock()
+	return service
+}
+
+// subscription is the JSON a browser would register with
+func (service *pushService) subscription(t *testing.T) string {
+	t.Helper()
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	encode := base64.RawURLEncoding.EncodeToString
+	return `{"endpoint": "` + service.URL + `/push/1", "keys": {"p256dh": "` + encode(browser.PublicKey().Bytes()) + `", "auth": "` + encode(auth) + `"}}`
+}
+
+func (service *pushService) messages() int {
+	background.wg.Wait()
+	service.Lock()
+	defer service.Unlock()
+	return service.received
+}
+
+func TestPushAtMixedCaseRinks(t *testing.T) {
+	date := time.Now().In(venueLocation()).AddDate(0, 0, 3).Format("2006-01-02")
+	tests := []struct {
+		name  string
+		rinks string
+		rink  string
+	}{
+		{"bp", "", ""},
+		{"lowercase name", "lasker=lasker-experience", "lasker"},
+		{"mixed case name", "Wollman=wollman-experience", "Wollman"},
+		{"asked for in another case", "Wollman=wollman-experience", "WOLLMAN"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{"`+date+`": {"1500": 0}}`)
+			t.Setenv("RINKS", test.rinks)
+			service := newPushService(t)
+
+			created := send(t, http.MethodPost, "/api/push/subscriptions", `{"subscription": `+service.subscription(t)+`, "date": "`+date+`", "rink": "`+test.rink+`"}`)
+			if created.Code != http.StatusCreated {
+				t.Fatalf("status %d, body %s", created.Code, created.Body.String())
+			}
+			get(t, "/api?date="+date+"&rink="+test.rink)
+			stub.answer(http.StatusOK, `{"`+date+`": {"1500": 4}}`)
+			get(t, "/api?date="+date+"&fresh=1&rink="+test.rink)
+
+			if received := service.messages(); received != 1 {
+				t.Errorf("the push service got %d messages, want one for the opening", received)
+			}
+		})
+	}
+}
+
+func TestPushOnlyForItsRink(t *testing.T) {
+	date := time.Now().In(venueLocation()).AddDate(0, 0, 3).Format("2006-01-02")
+	stub := newXolaStub(t, `{"`+date+`": {"1500": 0}}`)
+	t.Setenv("RINKS", "Wollman=wollman-experience")
+	service := newPushService(t)
+
+	send(t, http.MethodPost, "/api/push/subscriptions", `{"subscription": `+service.subscription(t)+`, "date": "`+date+`", "rink": "Wollman"}`)
+	get(t, "/api?date="+date)
+	stub.answer(http.StatusOK, `{"`+date+`": {"1500": 4}}`)
+	get(t, "/api?date="+date+"&fresh=1")
+	if received := service.messages(); received != 0 {
+		t.Errorf("a Wollman subscription got %d messages for bp opening up", received)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build qr
+
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrSize is the PNG width/height in pixels, big enough to scan off a printed page
+const qrSize = 512
+
+// writeQRCode responds with a PNG QR code linking to the rink's booking page for the date
+func writeQRCode(w http.ResponseWriter, rk rink, date string) {
+	if os.Getenv("QR_CODES") != "1" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("QR codes are disabled"))
+		return
+	}
+	png, err := qrcode.Encode(bookingURL(rk, date), qrcode.Medium, qrSize)
+	if err != nil {
+		slog.Error("could not encode QR code", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
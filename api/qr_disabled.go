@@ -0,0 +1,11 @@
+//go:build !qr
+
+package handler
+
+import "net/http"
+
+// writeQRCode is a stub for builds without the qr tag, which pulls in the QR encoder
+func writeQRCode(w http.ResponseWriter, rk rink, date string) {
+	w.WriteHeader(http.StatusNotImplemented)
+	w.Write([]byte("QR codes are not available in this build"))
+}
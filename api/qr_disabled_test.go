@@ -0,0 +1,16 @@
+//go:build !qr
+
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestQRCodeNotBuilt(t *testing.T) {
+	newXolaStub(t, `{}`)
+	t.Setenv("QR_CODES", "1")
+	if w := get(t, "/api?date=2024-01-02&qr=1"); w.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501 without the qr tag", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build qr
+
+package handler
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+func TestQRCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled string
+		target  string
+		want    int
+		url     string
+	}{
+		{"booking page for the date", "1", "/api?date=2024-01-02&qr=1", http.StatusOK, "https://checkout.xola.com/index.html#experience/test-experience?date=2024-01-02"},
+		{"another rink", "1", "/api?date=2024-01-02&qr=1&rink=wollman", http.StatusOK, "https://checkout.xola.com/index.html#experience/wollman-experience?date=2024-01-02"},
+		{"off without QR_CODES", "", "/api?date=2024-01-02&qr=1", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{}`)
+			t.Setenv("QR_CODES", test.enabled)
+			t.Setenv("RINKS", "Wollman=wollman-experience")
+			w := get(t, test.target)
+			if w.Code != test.want {
+				t.Fatalf("status = %d, want %d", w.Code, test.want)
+			}
+			if stub.calls() != 0 {
+				t.Errorf("asked Xola %d times for a QR code", stub.calls())
+			}
+			if test.want != http.StatusOK {
+				return
+			}
+			image, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+			if err != nil || w.Header().Get("Content-Type") != "image/png" {
+				t.Fatalf("not a PNG (%s): %v", w.Header().Get("Content-Type"), err)
+			}
+			if size := image.Bounds().Size(); size.X != qrSize || size.Y != qrSize {
+				t.Errorf("image is %v, want %dx%d", size, qrSize, qrSize)
+			}
+			// encoding is deterministic, so the same PNG means the same URL is in it
+			want, _ := qrcode.Encode(test.url, qrcode.Medium, qrSize)
+			if !bytes.Equal(w.Body.Bytes(), want) {
+				t.Errorf("the QR code doesn't encode %s", test.url)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRangeDays caps how many days one range request can cover
+const maxRangeDays = 62
+
+// availabilityRange is the structured form of a multi-day request, one entry per day in order
+type availabilityRange struct {
+	Start     string         `json:"start"`
+	End       string         `json:"end"`
+	Days      []availability `json:"days"`
+	NextToken string         `json:"nextToken,omitempty"`
+}
+
+// rangeDates lists every date from start to end inclusive
+func rangeDates(startObj time.Time, endObj time.Time) []time.Time {
+	var dates []time.Time
+	for day := startObj; !day.After(endObj); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day)
+	}
+	return dates
+}
+
+// queryOpenDays fetches the range from Xola, one request per run of consecutive open days so
+// closed weekdays are never asked about
+func queryOpenDays(ctx context.Context, dates []time.Time) (map[string]map[string]int, map[string]map[string]bool, error) {
+	skateTimesMap := map[string]map[string]int{}
+	waitlists := map[string]map[string]bool{}
+	for i := 0; i < len(dates); i++ {
+		if isClosedDay(rinkFromContext(ctx), dates[i]) {
+			continue
+		}
+		runStart := i
+		for i+1 < len(dates) && !isClosedDay(rinkFromContext(ctx), dates[i+1]) {
+			i++
+		}
+		runMap, runWaitlists, err := querySkateTimesRange(ctx, dates[runStart].Format("2006-01-02"), dates[i].Format("2006-01-02"))
+		if err != nil {
+			return skateTimesMap, waitlists, err
+		}
+		for date, slots := range runMap {
+			skateTimesMap[date] = slots
+		}
+		for date, slots := range runWaitlists {
+			waitlists[date] = slots
+		}
+	}
+	return skateTimesMap, waitlists, nil
+}
+
+// rangeHandler serves start..end grouped per day, every format renders each day the same way a single-day request would
+func rangeHandler(w http.ResponseWriter, r *http.Request, startObj time.Time, endInput string) {
+	end, endObj, err := normalizeDate(endInput)
+	if err != nil || startObj.IsZero() || endObj.Before(startObj) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Bad date range, expected a start date on or before the end date"))
+		return
+	}
+	dates := rangeDates(startObj, endObj)
+	if len(dates) > maxRangeDays {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Date range is limited to " + strconv.Itoa(maxRangeDays) + " days"))
+		return
+	}
+
+	_, endXolaSpan := startSpan(r.Context(), "xola.availability")
+	skateTimesMap, waitlists, err := queryOpenDays(r.Context(), dates)
+	endXolaSpan()
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	opts := formatOptionsFromRequest(r)
+	if pricingEnabled() && len(skateTimesMap) > 0 {
+		opts.price = queryExperiencePrice(r.Context())
+	}
+
+	_, endFormatSpan := startSpan(r.Context(), "format")
+	defer endFormatSpan()
+
+	view := availabilityView{start: startObj.Format("2006-01-02"), end: end, skateTimesMap: skateTimesMap}
+	for _, day := range dates {
+		dayOpts := opts
+		dayOpts.waitlists = waitlists[day.Format("2006-01-02")]
+		view.days = append(view.days, viewDay{day.Format("2006-01-02"), day, dayOpts})
+	}
+	renderAvailability(w, r, view)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitPerMinute is each API key's budget when RATE_LIMIT_PER_MINUTE is unset
+const defaultRateLimitPerMinute = 60
+
+// defaultXolaRateLimitPerMinute is the outbound budget toward Xola when XOLA_RATE_LIMIT_PER_MINUTE is unset
+const defaultXolaRateLimitPerMinute = 120
+
+// errXolaBudget is returned instead of calling Xola when the outbound budget is spent
+var errXolaBudget = errors.New("Xola request budget exceeded")
+
+// tokenBucket allows perMinute requests a minute, and bursts of up to a minute's worth
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take spends a token if there is one. Otherwise it returns how long until the next one.
+func (bucket *tokenBucket) take(perMinute int, now time.Time) (bool, time.Duration) {
+	capacity := float64(perMinute)
+	if bucket.updated.IsZero() {
+		bucket.tokens = capacity
+	} else {
+		bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Minutes()*capacity)
+	}
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / capacity * float64(time.Minute))
+}
+
+// keyBuckets are the per API key buckets of this instance
+var keyBuckets = struct {
+	sync.Mutex
+	byKey map[string]*tokenBucket
+}{byKey: map[string]*tokenBucket{}}
+
+// keyRateLimit is the key's override in RATE_LIMITS (`name=perMinute` pairs), or RATE_LIMIT_PER_MINUTE
+func keyRateLimit(name string) int {
+	for _, entry := range listItems(os.Getenv("RATE_LIMITS")) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] != name {
+			continue
+		}
+		if limit, err := strconv.Atoi(parts[1]); err == nil && limit >= 0 {
+			return limit
+		}
+	}
+	return envInt("RATE_LIMIT_PER_MINUTE", defaultRateLimitPerMinute)
+}
+
+// allowKey applies the per-key rate limit, answering 429 with Retry-After once the key is over it.
+// Requests without a key (auth disabled) and keys with a limit of 0 are not limited.
+func allowKey(w http.ResponseWriter, r *http.Request) bool {
+	name := apiKeyName(r.Context())
+	limit := keyRateLimit(name)
+	if name == "" || limit == 0 {
+		return true
+	}
+	keyBuckets.Lock()
+	bucket, ok := keyBuckets.byKey[name]
+	if !ok {
+		bucket = &tokenBucket{}
+		keyBuckets.byKey[name] = bucket
+	}
+	allowed, wait := bucket.take(limit, time.Now())
+	keyBuckets.Unlock()
+	if allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeJSONError(w, http.StatusTooManyRequests, "Rate limit of "+strconv.Itoa(limit)+" requests per minute exceeded")
+	return false
+}
+
+// xolaBucket is the budget for every outbound request to Xola from this instance
+var xolaBucket = struct {
+	sync.Mutex
+	tokenBucket
+}{}
+
+// waitForXolaBudget blocks until the outbound budget (XOLA_RATE_LIMIT_PER_MINUTE, 0 turns it off)
+// allows another Xola request. When the wait would run past the request's deadline it gives up
+// right away with errXolaBudget instead.
+func waitForXolaBudget(ctx context.Context) error {
+	limit := envInt("XOLA_RATE_LIMIT_PER_MINUTE", defaultXolaRateLimitPerMinute)
+	if limit == 0 {
+		return nil
+	}
+	for {
+		xolaBucket.Lock()
+		allowed, wait := xolaBucket.take(limit, time.Now())
+		xolaBucket.Unlock()
+		if allowed {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return errXolaBudget
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// defaultRefreshDays is how many days from today the refresher keeps warm when REFRESH_DAYS is unset
+const defaultRefreshDays = 7
+
+// init starts the background refresher when REFRESH_INTERVAL_SECONDS is set. It's meant for the
+// long-running server (cmd/server), a serverless instance is frozen between requests and would
+// only refresh sporadically.
+func init() {
+	loadConfig()
+	interval := time.Duration(envInt("REFRESH_INTERVAL_SECONDS", 0)) * time.Second
+	if interval == 0 {
+		return
+	}
+	go runRefresher(interval)
+}
+
+// runRefresher re-fetches today and the next REFRESH_DAYS-1 days at every rink every interval, so interactive
+// requests for those dates are always answered from a warm cache. It stops at Shutdown, after
+// finishing the pass it's in.
+func runRefresher(interval time.Duration) {
+	slog.Info("starting background refresh", "days", envInt("REFRESH_DAYS", defaultRefreshDays), "interval", interval.String())
+	for {
+		done := make(chan struct{})
+		if !goBackground(func() { refreshHotDates(); close(done) }) {
+			return
+		}
+		<-done
+		select {
+		case <-time.After(interval):
+		case <-background.stop:
+			return
+		}
+	}
+}
+
+// refreshHotDates warms every configured rink, not just bp
+func refreshHotDates() {
+	now := time.Now().In(venueLocation())
+	today, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	for _, rk := range configuredRinks() {
+		ctx := withFreshData(withRink(context.Background(), rk))
+		for _, day := range rangeDates(today, today.AddDate(0, 0, envInt("REFRESH_DAYS", defaultRefreshDays)-1)) {
+			if isClosedDay(rk, day) {
+				continue
+			}
+			// cached per date, the same key a single-day request looks up
+			if _, _, err := querySkateTimesAPI(ctx, day.Format("2006-01-02")); err != nil {
+				slog.Warn("background refresh failed", "rink", rk.name, "date", day.Format("2006-01-02"), "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRefreshHotDatesEveryRink(t *testing.T) {
+	tests := []struct {
+		name  string
+		rinks string
+		want  []string
+	}{
+		{"bp only", "", []string{"test-experience"}},
+		{"every rink", "Wollman=wollman-experience,lasker=lasker-experience", []string{"lasker-experience", "test-experience", "wollman-experience"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{}`)
+			t.Setenv("RINKS", test.rinks)
+			t.Setenv("REFRESH_DAYS", "2")
+			refreshHotDates()
+
+			asked := map[string]int{}
+			for _, r := range stub.requests {
+				asked[strings.Split(strings.TrimPrefix(r.URL.Path, "/api/experiences/"), "/")[0]]++
+			}
+			var experiences []string
+			for experience, days := range asked {
+				if days != 2 {
+					t.Errorf("%s was refreshed for %d days, want 2", experience, days)
+				}
+				experiences = append(experiences, experience)
+			}
+			sort.Strings(experiences)
+			if strings.Join(experiences, " ") != strings.Join(test.want, " ") {
+				t.Errorf("refreshed %v, want %v", experiences, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// availability is the structured (JSON) form of a day's open sessions
+type availability struct {
+	Rink      string     `json:"rink,omitempty"`
+	Date      string     `json:"date"`
+	Closed    bool       `json:"closed,omitempty"`
+	Slots     []timeSlot `json:"slots"`
+	NextToken string     `json:"nextToken,omitempty"`
+	// PreviousDay holds yesterday's still-running sessions during the overnight grace window
+	PreviousDay *availability `json:"previousDay,omitempty"`
+}
+
+// timeSlot is a single session, Time is 24h "15:04" (or ISO 8601 with ?iso=1). Spots is 0 when Limited is set (see SPOTS_FLOOR),
+// and WaitlistAvailable is only set on sold out slots, which are only included with ?includeSoldOut=1
+type timeSlot struct {
+	Time              string `json:"time"`
+	Spots             int    `json:"spots"`
+	Limited           bool   `json:"limited,omitempty"`
+	Price             string `json:"price,omitempty"`
+	Accessible        bool   `json:"accessible,omitempty"`
+	Surface           string `json:"surface"`
+	WaitlistAvailable bool   `json:"waitlistAvailable,omitempty"`
+}
+
+// formatOptions are the per-request display settings shared by every output format
+type formatOptions struct {
+	price          string
+	accessibleOnly bool
+	includeSoldOut bool
+	grouped        bool
+	// previousDate/previousTimes are yesterday's still-running sessions, see lateSessions
+	previousDate  string
+	previousTimes map[string]map[string]int
+	// surface limits the slots to one rink surface (?surface=outdoor|indoor), "" includes all
+	surface string
+	// isoTimes puts a full ISO 8601 datetime with the venue offset in each slot's time (?iso=1)
+	isoTimes bool
+	// relativeTo is the current venue time when the header should use relative labels (?relative=1)
+	relativeTo time.Time
+	// waitlists are the slot times (HHMM) with an open waitlist, from the upstream response
+	waitlists map[string]bool
+	// rink is the rink the request asked for (?rink=)
+	rink rink
+	// emoji marks each session with its urgency and flags sold out and plentiful days (?emoji=1)
+	emoji bool
+}
+
+func formatOptionsFromRequest(r *http.Request) formatOptions {
+	query := r.URL.Query()
+	opts := formatOptions{
+		accessibleOnly: query.Get("accessibleOnly") == "1",
+		includeSoldOut: query.Get("includeSoldOut") == "1",
+		grouped:        query.Get("group") == "1",
+		isoTimes:       query.Get("iso") == "1",
+		emoji:          query.Get("emoji") == "1",
+		surface:        strings.ToLower(query.Get("surface")),
+		rink:           rinkFromContext(r.Context()),
+	}
+	if query.Get("relative") == "1" {
+		opts.relativeTo = time.Now().In(venueLocation())
+	}
+	return opts
+}
+
+// skateTimes is xola.CleanSkateTimes, keeping sold out slots when the request asked for them
+func (opts formatOptions) skateTimes(date string, skateTimesMap map[string]map[string]int) ([]string, map[string]int) {
+	return xola.SortedSkateTimes(date, skateTimesMap, opts.includeSoldOut)
+}
+
+// filter drops the sorted slot times the request asked to hide
+func (opts formatOptions) filter(keys []string) []string {
+	if !opts.accessibleOnly && opts.surface == "" {
+		return keys
+	}
+	accessible := accessibleSessions()
+	indoor := sessionTimes("INDOOR_SESSIONS")
+	var filtered = make([]string, 0, len(keys))
+	for _, skateTime := range keys {
+		if opts.accessibleOnly && !accessible[skateTime] {
+			continue
+		}
+		if opts.surface != "" && slotSurface(skateTime, indoor) != opts.surface {
+			continue
+		}
+		filtered = append(filtered, skateTime)
+	}
+	return filtered
+}
+
+func buildAvailability(date string, skateTimesMap map[string]map[string]int, opts formatOptions) availability {
+	keys, cleanedMap := opts.skateTimes(date, skateTimesMap)
+	keys = opts.filter(keys)
+	accessible := accessibleSessions()
+	indoor := sessionTimes("INDOOR_SESSIONS")
+	dateObj, _ := time.Parse("2006-01-02", date)
+	result := availability{Rink: opts.rink.name, Date: date, Closed: isClosedDay(opts.rink, dateObj), Slots: make([]timeSlot, 0, len(keys))}
+	for _, skateTime := range keys {
+		timeObj, _ := time.Parse("1504", skateTime)
+		slotTime := timeObj.Format("15:04")
+		if opts.isoTimes {
+			slotTime = isoSlotTime(dateObj, timeObj)
+		}
+		spots := cleanedMap[skateTime]
+		limited := isLimited(spots)
+		if limited {
+			spots = 0
+		}
+		result.Slots = append(result.Slots, timeSlot{
+			Time:              slotTime,
+			Spots:             spots,
+			Limited:           limited,
+			Price:             opts.price,
+			Accessible:        accessible[skateTime],
+			Surface:           slotSurface(skateTime, indoor),
+			WaitlistAvailable: cleanedMap[skateTime] == 0 && opts.waitlists[skateTime],
+		})
+	}
+	if opts.previousDate != "" {
+		previousOpts := opts
+		previousOpts.previousDate = ""
+		previous := buildAvailability(opts.previousDate, opts.previousTimes, previousOpts)
+		result.PreviousDay = &previous
+	}
+	return result
+}
+
+// findSlot builds the structured slot for one session time (HHMM), nil when the time isn't in the response
+func findSlot(date string, skateTimesMap map[string]map[string]int, opts formatOptions, skateTime string) *timeSlot {
+	if skateTime == "" {
+		return nil
+	}
+	// buildAvailability lays out one slot per key in the same order
+	result := buildAvailability(date, skateTimesMap, opts)
+	keys, _ := opts.skateTimes(date, skateTimesMap)
+	for i, key := range opts.filter(keys) {
+		if key == skateTime {
+			return &result.Slots[i]
+		}
+	}
+	return nil
+}
+
+// isoSlotTime combines the date and slot time in the venue timezone, e.g. "2024-01-02T15:00:00-05:00".
+// The offset comes from the zone rules for that date so it follows DST.
+func isoSlotTime(dateObj time.Time, timeObj time.Time) string {
+	return time.Date(dateObj.Year(), dateObj.Month(), dateObj.Day(), timeObj.Hour(), timeObj.Minute(), 0, 0, venueLocation()).Format(time.RFC3339)
+}
+
+// errorResponse is the JSON body of every error that isn't the client's fault
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(errorResponse{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeJSONResponse(w http.ResponseWriter, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("could not encode response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
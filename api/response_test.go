@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWaitlists(t *testing.T) {
+	payload := `{"2024-01-02": {"1500": {"available": 0, "waitlist": true}, "1600": 0, "1700": {"available": 3, "waitlist": true}}}`
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"sold out hidden by default", "/api?date=2024-01-02", "For Jan 2, 2024:\n5:00 PM has 3 spots\n"},
+		{"includeSoldOut", "/api?date=2024-01-02&includeSoldOut=1", "For Jan 2, 2024:\n3:00 PM SOLD OUT (waitlist open)\n4:00 PM SOLD OUT\n5:00 PM has 3 spots\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, payload)
+			if w := get(t, test.target); w.Body.String() != test.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestWaitlistsJSON(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": {"available": 0, "waitlist": true}, "1600": 0, "1700": {"available": 3, "waitlist": true}}}`)
+	var body availability
+	if err := json.Unmarshal(get(t, "/api?date=2024-01-02&includeSoldOut=1&format=json").Body.Bytes(), &body); err != nil || len(body.Slots) != 3 {
+		t.Fatalf("slots %+v, %v", body.Slots, err)
+	}
+	// only sold out slots carry the flag, an open session with a waitlist is just open
+	for i, want := range []bool{true, false, false} {
+		if body.Slots[i].WaitlistAvailable != want {
+			t.Errorf("%s waitlistAvailable = %v, want %v", body.Slots[i].Time, body.Slots[i].WaitlistAvailable, want)
+		}
+	}
+}
+
+func TestISOTimes(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    string
+		payload string
+		want    []string
+	}{
+		{"winter", "2024-01-02", `{"2024-01-02": {"1500": 4}}`, []string{"2024-01-02T15:00:00-05:00"}},
+		{"summer", "2024-07-02", `{"2024-07-02": {"930": 4}}`, []string{"2024-07-02T09:30:00-04:00"}},
+		{"the day clocks go forward", "2024-03-10", `{"2024-03-10": {"100": 1, "1500": 4}}`, []string{"2024-03-10T01:00:00-05:00", "2024-03-10T15:00:00-04:00"}},
+		{"the day clocks go back", "2024-11-03", `{"2024-11-03": {"000": 1, "2300": 4}}`, []string{"2024-11-03T00:00:00-04:00", "2024-11-03T23:00:00-05:00"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, test.payload)
+			var body availability
+			if err := json.Unmarshal(get(t, "/api?format=json&iso=1&date="+test.date).Body.Bytes(), &body); err != nil || len(body.Slots) != len(test.want) {
+				t.Fatalf("slots %+v, %v", body.Slots, err)
+			}
+			for i, want := range test.want {
+				if body.Slots[i].Time != want {
+					t.Errorf("time = %s, want %s", body.Slots[i].Time, want)
+				}
+			}
+		})
+	}
+}
+
+func TestISOTimesInExtremes(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1000": 12, "1800": 1}}`)
+	var body extremes
+	if err := json.Unmarshal(get(t, "/api?date=2024-01-02&extremes=1&format=json&iso=1").Body.Bytes(), &body); err != nil || body.Quietest == nil {
+		t.Fatal(err)
+	}
+	if body.Quietest.Time != "2024-01-02T10:00:00-05:00" || body.Fullest.Time != "2024-01-02T18:00:00-05:00" {
+		t.Errorf("quietest %s, fullest %s", body.Quietest.Time, body.Fullest.Time)
+	}
+}
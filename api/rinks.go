@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// defaultRink is the rink answered when the request doesn't pick one with ?rink=
+const defaultRink = "bp"
+
+// rink is one Xola-hosted rink the service can report on. closedDays is its own closed= list from
+// RINKS when ownClosedDays is set, otherwise it's closed on CLOSED_WEEKDAYS like Bryant Park.
+type rink struct {
+	name          string
+	experienceID  string
+	closedDays    string
+	ownClosedDays bool
+}
+
+// label is how the rink is named in text and voice output
+func (rk rink) label() string {
+	if rk.name == defaultRink {
+		return venueName
+	}
+	return rk.name
+}
+
+// configuredRinks is bp (XOLA_EXPERIENCE_ID) plus every `name=experienceID` pair in RINKS, e.g.
+// RINKS=Wollman=5f1e...,Lasker=60a2...;closed=mon+tue Names are matched case-insensitively and shown
+// as written.
+func configuredRinks() map[string]rink {
+	rinks := map[string]rink{defaultRink: {name: defaultRink, experienceID: xola.ExperienceID()}}
+	for _, entry := range strings.Split(os.Getenv("RINKS"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		options := strings.Split(parts[1], ";")
+		rk := rink{name: parts[0], experienceID: strings.TrimSpace(options[0])}
+		for _, option := range options[1:] {
+			if days := strings.TrimSpace(option); strings.HasPrefix(strings.ToLower(days), "closed=") {
+				rk.closedDays, rk.ownClosedDays = days[len("closed="):], true
+			}
+		}
+		rinks[strings.ToLower(parts[0])] = rk
+	}
+	return rinks
+}
+
+// multiRink reports whether more than one rink is configured, text output names the rink then
+func multiRink() bool {
+	return len(configuredRinks()) > 1
+}
+
+// requestRink looks up ?rink=, defaulting to bp. ok is false for a rink that isn't configured.
+func requestRink(r *http.Request) (rink, bool) {
+	rinks := configuredRinks()
+	name := strings.ToLower(r.URL.Query().Get("rink"))
+	if name == "" {
+		name = defaultRink
+	}
+	rk, ok := rinks[name]
+	return rk, ok
+}
+
+// writeUnknownRink answers a ?rink= that isn't configured, listing the ones that are
+func writeUnknownRink(w http.ResponseWriter, name string) {
+	var names []string
+	for _, rk := range configuredRinks() {
+		names = append(names, rk.name)
+	}
+	sort.Strings(names)
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("Unknown rink " + name + ", expected one of " + strings.Join(names, ", ")))
+}
+
+type rinkKey struct{}
+
+func withRink(ctx context.Context, rk rink) context.Context {
+	return context.WithValue(ctx, rinkKey{}, rk)
+}
+
+// rinkFromContext is the rink the request asked for, bp when there's none on the context
+func rinkFromContext(ctx context.Context) rink {
+	if rk, ok := ctx.Value(rinkKey{}).(rink); ok {
+		return rk
+	}
+	return rink{name: defaultRink, experienceID: xola.ExperienceID()}
+}
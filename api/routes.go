@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// currentVersion is the API version every route is served under. The original /api paths are an
+// alias for it, so existing Shortcuts keep working when a /v2 comes along.
+const currentVersion = "v1"
+
+// apiRoutes are the public endpoints, relative to the version prefix. Anything else is the skate
+// times for the date, which is what bare /api has always been.
+var apiRoutes = map[string]http.HandlerFunc{
+	"/digest":             digestHandler,
+	"/history":            historyHandler,
+	"/batch":              batchHandler,
+	"/nextAvailable":      nextAvailableHandler,
+	"/week":               weekHandler,
+	"/graphql":            graphQLHandler,
+	"/skateTimes.ics":     icsHandler,
+	"/feed.xml":           feedHandler,
+	"/view":               viewHandler,
+	"/push/key":           pushKeyHandler,
+	"/push/subscriptions": pushSubscriptionsHandler,
+	"/shortcut":           shortcutHandler,
+	"/homeassistant":      homeAssistantHandler,
+	"/watches":            watchesHandler,
+}
+
+// feedRoutes are subscribed to by apps that can't send headers, so they also take the API key
+// as ?token=
+var feedRoutes = map[string]bool{
+	"/skateTimes.ics": true,
+	"/feed.xml":       true,
+	"/view":           true,
+	"/shortcut":       true,
+	"/homeassistant":  true,
+}
+
+// integrationRoutes are called by chat platforms, which sign their requests with their own secret
+// instead of sending an API key. The watch links carry the watch's token, or take an API key.
+var integrationRoutes = map[string]http.HandlerFunc{
+	"/slack/command":        slackCommandHandler,
+	"/discord/interactions": discordInteractionsHandler,
+	"/telegram/webhook":     telegramWebhookHandler,
+	"/twilio/sms":           twilioSMSHandler,
+	"/alexa":                alexaHandler,
+	"/watches/unsubscribe":  watchActionHandler("unsubscribe"),
+	"/watches/snooze":       watchActionHandler("snooze"),
+}
+
+// adminRoutes check ADMIN_TOKEN instead of the API keys, see admin.go
+var adminRoutes = map[string]http.HandlerFunc{
+	"/admin/audit":    adminAuditHandler,
+	"/admin/cache":    adminCacheHandler,
+	"/admin/digest":   adminDigestHandler,
+	"/admin/keys":     adminKeysHandler,
+	"/admin/watches":  adminWatchesHandler,
+	"/admin/webhooks": adminWebhooksHandler,
+}
+
+// routePath strips the version prefix, so /v1/digest and the legacy /api/digest are both "/digest".
+// /admin routes may be versioned too (/v1/admin/keys).
+func routePath(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	for _, prefix := range []string{"/" + currentVersion, "/api"} {
+		if path == prefix {
+			return ""
+		}
+		if strings.HasPrefix(path, prefix+"/") {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	// bundle the timezone database, the serverless runtime doesn't ship one
+	_ "time/tzdata"
+)
+
+// venueTimezone is where the rink is, "today" and session times are in this zone
+const venueTimezone = "America/New_York"
+
+// venueLocation loads the venue timezone, falling back to UTC if it can't be found
+func venueLocation() *time.Location {
+	location, err := time.LoadLocation(venueTimezone)
+	if err != nil {
+		slog.Warn("could not load the venue time zone, using UTC", "timezone", venueTimezone)
+		return time.UTC
+	}
+	return location
+}
+
+// seasonBound parses a season boundary (YYYY-MM-DD) from env, ok is false when unset or invalid
+func seasonBound(key string) (time.Time, bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return time.Time{}, false
+	}
+	bound, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		slog.Warn("ignoring bad setting", "key", key, "value", value)
+		return time.Time{}, false
+	}
+	return bound, true
+}
+
+// outOfSeasonMessage explains an empty schedule for dates outside SEASON_START..SEASON_END,
+// and is empty for dates inside the season (or when no season is configured)
+func outOfSeasonMessage(dateObj time.Time) string {
+	if dateObj.IsZero() {
+		return ""
+	}
+	if start, ok := seasonBound("SEASON_START"); ok && dateObj.Before(start) {
+		return "Season hasn't started"
+	}
+	if end, ok := seasonBound("SEASON_END"); ok && dateObj.After(end) {
+		return "Season has ended"
+	}
+	return ""
+}
+
+// closedWeekdays parses the rink's closed days into a set of weekdays it never operates: its closed=
+// list in RINKS (e.g. "mon+tue"), or CLOSED_WEEKDAYS (e.g. "Mon,Tue" or "monday") when it has none
+func closedWeekdays(rk rink) map[time.Weekday]bool {
+	setting, value := "CLOSED_WEEKDAYS", os.Getenv("CLOSED_WEEKDAYS")
+	if rk.ownClosedDays {
+		setting, value = "RINKS", rk.closedDays
+	}
+	closed := map[time.Weekday]bool{}
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '+' }) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		day, ok := parseWeekday(name)
+		if !ok {
+			slog.Warn("ignoring unknown weekday in "+setting, "rink", rk.name, "weekday", name)
+			continue
+		}
+		closed[day] = true
+	}
+	return closed
+}
+
+// isClosedDay reports whether the rink is closed on the date's weekday, in which case there is no point asking Xola
+func isClosedDay(rk rink, dateObj time.Time) bool {
+	if dateObj.IsZero() {
+		return false
+	}
+	return closedWeekdays(rk)[dateObj.Weekday()]
+}
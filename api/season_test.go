@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutOfSeason(t *testing.T) {
+	tests := []struct {
+		name string
+		date string
+		want string
+	}{
+		{"before the season", "2023-11-01", "Season hasn't started"},
+		{"first day", "2023-11-15", "Sold out"},
+		{"in season", "2024-01-02", "Sold out"},
+		{"last day", "2024-03-03", "Sold out"},
+		{"after the season", "2024-03-10", "Season has ended"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Xola answers an empty schedule either way
+			newXolaStub(t, `{}`)
+			t.Setenv("SEASON_START", "2023-11-15")
+			t.Setenv("SEASON_END", "2024-03-03")
+			w := get(t, "/api?date="+test.date)
+			if lines := strings.Split(w.Body.String(), "\n"); len(lines) < 2 || lines[1] != test.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestOutOfSeasonUnset(t *testing.T) {
+	resetAvailability(t)
+	if message := outOfSeasonMessage(mustDate(t, "1999-01-01")); message != "" {
+		t.Errorf("outOfSeasonMessage() = %q without a season configured", message)
+	}
+	t.Setenv("SEASON_START", "not a date")
+	if message := outOfSeasonMessage(mustDate(t, "1999-01-01")); message != "" {
+		t.Errorf("outOfSeasonMessage() = %q with a bad SEASON_START", message)
+	}
+}
+
+func mustDate(t *testing.T, date string) time.Time {
+	t.Helper()
+	dateObj, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dateObj
+}
+
+func TestClosedWeekdaysSkipXola(t *testing.T) {
+	tests := []struct {
+		name   string
+		rink   string
+		closed []string
+		asked  []string
+	}{
+		// Mon Dec 22 through Sun Dec 28, 2025
+		{"CLOSED_WEEKDAYS", "bp", []string{"2025-12-22", "2025-12-24"}, []string{"2025-12-23/2025-12-23", "2025-12-25/2025-12-28"}},
+		{"the rink's own closed days", "wollman", []string{"2025-12-26"}, []string{"2025-12-22/2025-12-25", "2025-12-27/2025-12-28"}},
+		{"a rink closed on no days", "lasker", nil, []string{"2025-12-22/2025-12-28"}},
+		{"a rink without closed= follows CLOSED_WEEKDAYS", "central", []string{"2025-12-22", "2025-12-24"}, []string{"2025-12-23/2025-12-23", "2025-12-25/2025-12-28"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{}`)
+			t.Setenv("CLOSED_WEEKDAYS", "Mon,wednesday")
+			t.Setenv("RINKS", "Wollman=wollman;closed=fri,Lasker=lasker;closed=,Central=central")
+			w := get(t, "/api?date=2025-12-22&end=2025-12-28&format=json&rink="+test.rink)
+			var body availabilityRange
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Days) != 7 {
+				t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+			}
+			var closed []string
+			for _, day := range body.Days {
+				if day.Closed {
+					closed = append(closed, day.Date)
+				}
+			}
+			if strings.Join(closed, " ") != strings.Join(test.closed, " ") {
+				t.Errorf("closed days %v, want %v", closed, test.closed)
+			}
+			var asked []string
+			for _, r := range stub.requests {
+				asked = append(asked, r.URL.Query().Get("start")+"/"+r.URL.Query().Get("end"))
+			}
+			if strings.Join(asked, " ") != strings.Join(test.asked, " ") {
+				t.Errorf("asked Xola for %v, want %v", asked, test.asked)
+			}
+		})
+	}
+}
+
+func TestClosedDayText(t *testing.T) {
+	stub := newXolaStub(t, `{"2025-12-22": {"1500": 4}}`)
+	t.Setenv("CLOSED_WEEKDAYS", "mon")
+	if w := get(t, "/api?date=2025-12-22"); w.Body.String() != "For Dec 22, 2025:\nClosed\n" || stub.calls() != 0 {
+		t.Errorf("body %q after %d Xola calls, want Closed without asking", w.Body.String(), stub.calls())
+	}
+}
+
+func TestValidRinks(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"Wollman=5f1e", true},
+		{"Wollman=5f1e;closed=mon+tue,Lasker=60a2", true},
+		{"Wollman=5f1e;closed=", true},
+		{"Wollman=5f1e;closed=funday", false},
+		{"Wollman=5f1e;open=mon", false},
+		{"Wollman", false},
+	}
+	for _, test := range tests {
+		if err := validRinks(test.value); (err == nil) != test.ok {
+			t.Errorf("validRinks(%q) = %v, want ok %v", test.value, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// init runs the optional startup self-check (SELF_CHECK=1). It's off by default so dev and offline
+// runs don't need xola.com, and with SELF_CHECK_STRICT=1 a failure stops the instance from starting.
+func init() {
+	loadConfig()
+	if os.Getenv("SELF_CHECK") != "1" {
+		return
+	}
+	if err := selfCheck(experienceURL(context.Background())); err != nil {
+		if os.Getenv("SELF_CHECK_STRICT") == "1" {
+			fatal("self-check failed, refusing to start", "error", err)
+		}
+		slog.Warn("self-check failed", "error", err)
+		return
+	}
+	slog.Info("self-check passed")
+}
+
+// selfCheck makes one real availability request for today and checks the response decodes
+// into the { date: { time: count } } shape the handlers rely on
+func selfCheck(baseURL string) error {
+	today := time.Now().In(venueLocation()).Format("2006-01-02")
+	res, err := getWithRetry(context.Background(), xola.AvailabilityURL(baseURL, today, today))
+	if err != nil {
+		return err
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		return errors.New("Xola returned status " + strconv.Itoa(res.StatusCode))
+	}
+	if _, _, err := xola.Decode(data); err != nil {
+		return errors.New("unexpected response shape: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSelfCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		payload string
+		wantErr bool
+	}{
+		{"good response", http.StatusOK, `{"2024-01-02": {"1500": 4}}`, false},
+		{"empty schedule", http.StatusOK, `[]`, false},
+		{"error status", http.StatusServiceUnavailable, `{}`, true},
+		{"wrong shape", http.StatusOK, `{"data": [1, 2]}`, true},
+		{"html", http.StatusOK, `<html>maintenance</html>`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, test.payload)
+			stub.answer(test.status, test.payload)
+			err := selfCheck(experienceURL(withRink(context.Background(), configuredRinks()[defaultRink])))
+			if (err != nil) != test.wantErr {
+				t.Errorf("selfCheck() = %v, wantErr %v", err, test.wantErr)
+			}
+			if stub.calls() != 1 || stub.requests[0].URL.Query().Get("start") == "" {
+				t.Errorf("asked Xola %v", stub.requests)
+			}
+		})
+	}
+}
+
+func TestSelfCheckUnreachable(t *testing.T) {
+	stub := newXolaStub(t, `{}`)
+	stub.Close()
+	if err := selfCheck(experienceURL(withRink(context.Background(), configuredRinks()[defaultRink]))); err == nil {
+		t.Error("selfCheck() passed with Xola down")
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// accessibleSessions reads ACCESSIBLE_SESSIONS, a comma-separated list of the session times the
+// venue runs as adaptive/accessibility sessions (Xola doesn't mark these itself)
+func accessibleSessions() map[string]bool {
+	return sessionTimes("ACCESSIBLE_SESSIONS")
+}
+
+// sessionTimes parses a comma-separated list of session times from env into a set of HHMM keys
+func sessionTimes(key string) map[string]bool {
+	sessions := map[string]bool{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		skateTime, ok := xola.NormalizeSlotTime(value)
+		if !ok {
+			slog.Warn("ignoring bad session time", "key", key, "value", value)
+			continue
+		}
+		sessions[skateTime] = true
+	}
+	return sessions
+}
+
+// the rink surfaces a session can be on, Bryant Park's rink is outdoor
+const (
+	surfaceOutdoor = "outdoor"
+	surfaceIndoor  = "indoor"
+)
+
+// slotSurface is indoor for the times in INDOOR_SESSIONS and outdoor otherwise
+func slotSurface(skateTime string, indoor map[string]bool) string {
+	if indoor[skateTime] {
+		return surfaceIndoor
+	}
+	return surfaceOutdoor
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// slotFlags fetches the date as JSON and lists each slot as "HH:MM flags"
+func slotFlags(t *testing.T, target string) []string {
+	t.Helper()
+	var body availability
+	w := get(t, target)
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	var slots []string
+	for _, slot := range body.Slots {
+		line := slot.Time + " " + slot.Surface
+		if slot.Accessible {
+			line += " accessible"
+		}
+		slots = append(slots, line)
+	}
+	return slots
+}
+
+func TestAccessibleSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"flagged", "/api?date=2024-01-02&format=json", []string{"09:00 outdoor accessible", "15:00 outdoor", "17:30 outdoor accessible"}},
+		{"accessibleOnly", "/api?date=2024-01-02&format=json&accessibleOnly=1", []string{"09:00 outdoor accessible", "17:30 outdoor accessible"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"900": 3, "1500": 4, "1730": 2}}`)
+			t.Setenv("ACCESSIBLE_SESSIONS", "9:00, 1730, bogus")
+			if got := slotFlags(t, test.target); strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("slots %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAccessibleSessionsText(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"900": 3, "1500": 4}}`)
+	t.Setenv("ACCESSIBLE_SESSIONS", "900")
+	want := "For Jan 2, 2024:\n9:00 AM has 3 spots (accessible)\n3:00 PM has 4 spots\n"
+	if w := get(t, "/api?date=2024-01-02"); w.Body.String() != want {
+		t.Errorf("body = %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestSurfaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"labelled", "/api?date=2024-01-02&format=json", []string{"09:00 indoor", "15:00 outdoor", "17:30 indoor"}},
+		{"indoor only", "/api?date=2024-01-02&format=json&surface=indoor", []string{"09:00 indoor", "17:30 indoor"}},
+		{"outdoor only", "/api?date=2024-01-02&format=json&surface=OUTDOOR", []string{"15:00 outdoor"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"900": 3, "1500": 4, "1730": 2}}`)
+			t.Setenv("INDOOR_SESSIONS", "9:00,1730")
+			if got := slotFlags(t, test.target); strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("slots %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSurfacesText(t *testing.T) {
+	tests := []struct {
+		name   string
+		indoor string
+		want   string
+	}{
+		{"no indoor sessions", "", "For Jan 2, 2024:\n9:00 AM has 3 spots\n3:00 PM SOLD OUT\n"},
+		{"indoor sessions", "900", "For Jan 2, 2024:\n9:00 AM has 3 spots (indoor)\n3:00 PM SOLD OUT (outdoor)\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"900": 3, "1500": 0}}`)
+			t.Setenv("INDOOR_SESSIONS", test.indoor)
+			if w := get(t, "/api?date=2024-01-02&includeSoldOut=1"); w.Body.String() != test.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.want)
+			}
+		})
+	}
+}
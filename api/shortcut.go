@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// shortcutSession is one entry of /shortcut. The shape is a promise to Shortcuts people have
+// already built, add fields but never rename or nest these.
+type shortcutSession struct {
+	Time    string `json:"time"`
+	Spots   int    `json:"spots"`
+	BookURL string `json:"bookUrl"`
+}
+
+// shortcutHandler is /api for an iOS Shortcut: a bare JSON array that "Get Contents of URL" plus
+// "Repeat with Each" can read directly. It takes the API key as ?token= so the Shortcut needs no
+// headers.
+func shortcutHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Set("format", "shortcut")
+	r.URL.RawQuery = query.Encode()
+	skateTimesHandler(w, r)
+}
+
+// renderShortcut lists every session of the view in order. Time is "7:00 PM", prefixed with the
+// day ("Sat Jan 15, 7:00 PM") for ranges. Spots is 0 on limited sessions, like the JSON format.
+func renderShortcut(w http.ResponseWriter, r *http.Request, view availabilityView) {
+	sessions := []shortcutSession{}
+	for _, day := range view.days {
+		keys, cleanedMap := day.opts.skateTimes(day.date, view.skateTimesMap)
+		for _, skateTime := range day.opts.filter(keys) {
+			timeObj, _ := time.Parse("1504", skateTime)
+			label := timeObj.Format("3:04 PM")
+			if view.end != "" {
+				label = day.dateObj.Format("Mon Jan 2") + ", " + label
+			}
+			spots := cleanedMap[skateTime]
+			if isLimited(spots) {
+				spots = 0
+			}
+			sessions = append(sessions, shortcutSession{Time: label, Spots: spots, BookURL: bookingURL(day.opts.rink, day.date)})
+		}
+	}
+	data, _ := json.Marshal(sessions)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
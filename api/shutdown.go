@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// background tracks work that outlives the request that started it (stale-while-revalidate
+// refreshes, the refresher's passes), so Shutdown can wait for it
+var background = struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	stopping bool
+	stop     chan struct{}
+}{stop: make(chan struct{})}
+
+// goBackground runs fn in a goroutine Shutdown waits for. Once shutdown has started nothing new is
+// started, it reports false instead.
+func goBackground(fn func()) bool {
+	background.Lock()
+	defer background.Unlock()
+	if background.stopping {
+		return false
+	}
+	background.wg.Add(1)
+	go func() {
+		defer background.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// Shutdown stops the refresher and waits, until ctx is done, for background refreshes in flight
+// to finish writing the cache. For the long-running server, after it has stopped taking requests.
+func Shutdown(ctx context.Context) error {
+	background.Lock()
+	if !background.stopping {
+		background.stopping = true
+		close(background.stop)
+	}
+	background.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		background.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
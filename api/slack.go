@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"strings"
+	"time"
+)
+
+// slackMessage is a Block Kit message that can be POSTed to a Slack incoming webhook as is. Text is
+// the plain fallback Slack shows in notifications.
+type slackMessage struct {
+	// ResponseType is only set on slash command replies, "in_channel" or "ephemeral"
+	ResponseType string       `json:"response_type,omitempty"`
+	Text         string       `json:"text"`
+	Blocks       []slackBlock `json:"blocks,omitempty"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func slackSection(markdown string) slackBlock {
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: markdown}}
+}
+
+// slackEmoji marks how quickly a session is likely to go, see urgency
+var slackEmoji = map[string]string{
+	urgencySoldOut: ":no_entry:",
+	urgencyLow:     ":red_circle:",
+	urgencySome:    ":large_yellow_circle:",
+	urgencyPlenty:  ":large_green_circle:",
+}
+
+// slackDay is one day as a bold header and a bulleted list of sessions
+func slackDay(date string, dateObj time.Time, skateTimesMap map[string]map[string]int, opts formatOptions) []slackBlock {
+	heading := "*For " + dateObj.Format("Jan 2, 2006") + "*"
+	if multiRink() {
+		heading = "*" + opts.rink.label() + " — " + dateObj.Format("Jan 2, 2006") + "*"
+	}
+	keys, cleanedMap := opts.skateTimes(date, skateTimesMap)
+	keys = opts.filter(keys)
+	if len(keys) == 0 {
+		return []slackBlock{slackSection(heading + "\n" + emptyDayMessage(opts.rink, dateObj))}
+	}
+	lines := []string{heading}
+	for _, skateTime := range keys {
+		timeObj, _ := time.Parse("1504", skateTime)
+		spots := spotsText(cleanedMap[skateTime])
+		if cleanedMap[skateTime] == 0 {
+			spots = "sold out"
+		}
+		lines = append(lines, "• "+timeObj.Format("3:04 PM")+" — "+spots+" "+slackEmoji[urgency(cleanedMap[skateTime])])
+	}
+	return []slackBlock{slackSection(strings.Join(lines, "\n"))}
+}
+
+// newSlackMessage puts the days one after another, with a divider between them
+func newSlackMessage(days [][]slackBlock) slackMessage {
+	var message slackMessage
+	var fallback []string
+	for i, blocks := range days {
+		if i > 0 {
+			message.Blocks = append(message.Blocks, slackBlock{Type: "divider"})
+		}
+		message.Blocks = append(message.Blocks, blocks...)
+		for _, block := range blocks {
+			fallback = append(fallback, strings.Replace(block.Text.Text, "*", "", -1))
+		}
+	}
+	message.Text = strings.Join(fallback, "\n\n")
+	return message
+}
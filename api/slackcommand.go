@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// validSlackSignature checks X-Slack-Signature, "v0=" + hex HMAC-SHA256 of "v0:<timestamp>:<body>"
+// with SLACK_SIGNING_SECRET. The timestamp must be within signatureTolerance of now.
+func validSlackSignature(secret string, r *http.Request, body []byte, now time.Time) bool {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Slack-Signature")))
+}
+
+// slackCommandHandler answers the `/skate [date] [rink]` slash command. It's signed by Slack instead
+// of carrying an API key, and is a 404 until SLACK_SIGNING_SECRET is set.
+func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
+	secret := os.Getenv("SLACK_SIGNING_SECRET")
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatBody))
+	if err != nil || !validSlackSignature(secret, r, body, time.Now()) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Bad signature"))
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Bad form"))
+		return
+	}
+
+	query, problem := parseChatQuery(form.Get("text"))
+	if problem != "" {
+		writeJSONResponse(w, slackEphemeral(problem))
+		return
+	}
+
+	// the lookup carries on after a slow response has been acknowledged, so it can't use the request's context
+	ctx := context.WithoutCancel(r.Context())
+	var message slackMessage
+	finished, started := ackWithin(chatAckTimeout,
+		func() { message = slackCommandReply(ctx, query) },
+		func() { postSlackResponse(ctx, form.Get("response_url"), message) })
+	switch {
+	case !started:
+		writeJSONResponse(w, slackEphemeral("Shutting down, try again in a moment."))
+	case finished:
+		writeJSONResponse(w, message)
+	default:
+		writeJSONResponse(w, slackEphemeral("Checking the rink…"))
+	}
+}
+
+// slackCommandReply is the availability posted to the channel, or an error only the caller sees
+func slackCommandReply(ctx context.Context, query chatQuery) slackMessage {
+	skateTimesMap, opts, err := query.lookup(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "slack command lookup failed", "date", query.date, "error", err)
+		return slackEphemeral("Couldn't reach the booking system, try again in a minute.")
+	}
+	message := newSlackMessage([][]slackBlock{slackDay(query.date, query.dateObj, skateTimesMap, opts)})
+	message.ResponseType = "in_channel"
+	return message
+}
+
+func slackEphemeral(text string) slackMessage {
+	return slackMessage{ResponseType: "ephemeral", Text: text}
+}
+
+// postSlackResponse is the delayed reply to a slash command
+func postSlackResponse(ctx context.Context, responseURL string, message slackMessage) {
+	if responseURL == "" {
+		return
+	}
+	body, _ := json.Marshal(message)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("bad slack response_url", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "slack delayed response failed", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.WarnContext(ctx, "slack delayed response rejected", "status", resp.StatusCode)
+	}
+}
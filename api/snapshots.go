@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// snapshot is one observed availability for a date, appended as a JSON line to SNAPSHOT_LOG
+type snapshot struct {
+	Date      string         `json:"date"`
+	FetchedAt time.Time      `json:"fetchedAt"`
+	Slots     map[string]int `json:"slots"`
+}
+
+// historyPoint is a single point in the /api/history series
+type historyPoint struct {
+	FetchedAt time.Time      `json:"fetchedAt"`
+	Total     int            `json:"total"`
+	Slots     map[string]int `json:"slots,omitempty"`
+}
+
+type history struct {
+	Date   string         `json:"date"`
+	Series []historyPoint `json:"series"`
+}
+
+// snapshotLogLock serializes appends from concurrent requests in the same instance
+var snapshotLogLock sync.Mutex
+
+// recordSnapshot appends the date's non-empty slots to the snapshot log, it's a no-op when SNAPSHOT_LOG is unset
+func recordSnapshot(date string, skateTimesMap map[string]map[string]int) {
+	path := os.Getenv("SNAPSHOT_LOG")
+	if path == "" || date == "" {
+		return
+	}
+	_, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+	line, _ := json.Marshal(snapshot{Date: date, FetchedAt: time.Now().UTC(), Slots: cleanedMap})
+
+	snapshotLogLock.Lock()
+	defer snapshotLogLock.Unlock()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Warn("could not open snapshot log", "error", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// loadSnapshots reads every stored snapshot for the date, oldest first
+func loadSnapshots(date string) []snapshot {
+	var snapshots []snapshot
+	path := os.Getenv("SNAPSHOT_LOG")
+	if path == "" {
+		return snapshots
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		// no log yet just means no history
+		return snapshots
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			slog.Warn("skipping bad snapshot log line")
+			continue
+		}
+		if s.Date == date {
+			snapshots = append(snapshots, s)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].FetchedAt.Before(snapshots[j].FetchedAt)
+	})
+	return snapshots
+}
+
+// historyHandler returns how total (and with ?slots=1 per-slot) spots for a date changed across stored snapshots
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	date, _, err := requestDate(r)
+	if err != nil {
+		writeBadDate(w, date)
+		return
+	}
+	includeSlots := r.URL.Query().Get("slots") == "1"
+
+	result := history{Date: date, Series: []historyPoint{}}
+	for _, s := range loadSnapshots(date) {
+		point := historyPoint{FetchedAt: s.FetchedAt}
+		for _, count := range s.Slots {
+			point.Total += count
+		}
+		if includeSlots {
+			point.Slots = s.Slots
+		}
+		result.Series = append(result.Series, point)
+	}
+	writeJSONResponse(w, result)
+}
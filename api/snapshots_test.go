@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	log := `{"date": "2024-01-02", "fetchedAt": "2024-01-01T15:00:00Z", "slots": {"1500": 4, "1600": 2}}
+{"date": "2024-01-03", "fetchedAt": "2024-01-01T15:00:00Z", "slots": {"1500": 9}}
+not json
+{"date": "2024-01-02", "fetchedAt": "2024-01-01T17:00:00Z", "slots": {"1500": 1}}
+{"date": "2024-01-02", "fetchedAt": "2024-01-01T16:00:00Z", "slots": {"1500": 3, "1600": 2}}
+`
+	tests := []struct {
+		name   string
+		target string
+		totals []int
+		slots  bool
+	}{
+		{"oldest first", "/api/history?date=2024-01-02", []int{6, 5, 1}, false},
+		{"per slot", "/api/history?date=2024-01-02&slots=1", []int{6, 5, 1}, true},
+		{"no history", "/api/history?date=2024-02-01", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{}`)
+			path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+			ioutil.WriteFile(path, []byte(log), 0644)
+			t.Setenv("SNAPSHOT_LOG", path)
+			w := get(t, test.target)
+			var body history
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+			}
+			if len(body.Series) != len(test.totals) {
+				t.Fatalf("series %+v, want totals %v", body.Series, test.totals)
+			}
+			for i, point := range body.Series {
+				if point.Total != test.totals[i] || (point.Slots != nil) != test.slots {
+					t.Errorf("point %d = %+v, want total %d", i, point, test.totals[i])
+				}
+			}
+			if i := len(body.Series) - 1; i > 0 && !body.Series[i].FetchedAt.After(body.Series[0].FetchedAt) {
+				t.Errorf("series isn't in time order: %+v", body.Series)
+			}
+		})
+	}
+}
+
+func TestSnapshotsRecordedFromXola(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4, "1600": 0}}`)
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+	t.Setenv("SNAPSHOT_LOG", path)
+	get(t, "/api?date=2024-01-02")
+	stub.answer(http.StatusOK, `{"2024-01-02": {"1500": 2, "1600": 1}}`)
+	get(t, "/api?date=2024-01-02&fresh=1")
+
+	snapshots := loadSnapshots("2024-01-02")
+	if len(snapshots) != 2 {
+		t.Fatalf("%d snapshots, want 2", len(snapshots))
+	}
+	if snapshots[0].Slots["1500"] != 4 || len(snapshots[0].Slots) != 1 || snapshots[1].Slots["1600"] != 1 {
+		t.Errorf("snapshots = %+v", snapshots)
+	}
+}
+
+func TestHistoryWithoutLog(t *testing.T) {
+	newXolaStub(t, `{}`)
+	if w := get(t, "/api/history?date=2024-01-02"); w.Body.String() != `{"date":"2024-01-02","series":[]}` {
+		t.Errorf("body = %s", w.Body.String())
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// telegramAPI is the Bot API, the bot token is appended to it
+var telegramAPI = "https://api.telegram.org/bot"
+
+// telegramCallbackPrefix starts the data of the "check tomorrow" buttons, "skate:<date>:<rink>"
+const telegramCallbackPrefix = "skate:"
+
+// telegramUpdate is the part of a webhook update the bot reads: a message, or a button press
+type telegramUpdate struct {
+	Message *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+	CallbackQuery *struct {
+		ID      string `json:"id"`
+		Data    string `json:"data"`
+		Message *struct {
+			MessageID int64 `json:"message_id"`
+			Chat      struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"message"`
+	} `json:"callback_query"`
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type telegramKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+// telegramMessage is sendMessage's body, and editMessageText's with MessageID set
+type telegramMessage struct {
+	ChatID      int64             `json:"chat_id"`
+	MessageID   int64             `json:"message_id,omitempty"`
+	Text        string            `json:"text"`
+	ReplyMarkup *telegramKeyboard `json:"reply_markup,omitempty"`
+}
+
+// telegramCommand is the text after /skate, ok is false for any other message. In groups the
+// command may be addressed to the bot, /skate@bp_skate_bot friday.
+func telegramCommand(text string) (string, bool) {
+	command, rest := text, ""
+	if i := strings.IndexAny(text, " \n"); i >= 0 {
+		command, rest = text[:i], text[i+1:]
+	}
+	if i := strings.Index(command, "@"); i >= 0 {
+		command = command[:i]
+	}
+	return strings.TrimSpace(rest), command == "/skate"
+}
+
+// telegramWebhookHandler takes the bot's updates, answering /skate messages and "check tomorrow"
+// presses. Telegram sends TELEGRAM_WEBHOOK_SECRET with every update, it's a 404 until that and
+// TELEGRAM_BOT_TOKEN are both set.
+func telegramWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	token, secret := os.Getenv("TELEGRAM_BOT_TOKEN"), os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+	if token == "" || secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")), []byte(secret)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Bad secret"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatBody))
+	var update telegramUpdate
+	if err == nil {
+		err = json.Unmarshal(body, &update)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad update")
+		return
+	}
+
+	// Telegram retries anything but a 2xx, so failures past this point are logged, not returned
+	ctx := r.Context()
+	switch {
+	case update.Message != nil:
+		text, ok := telegramCommand(update.Message.Text)
+		if !ok {
+			break
+		}
+		message := telegramReply(ctx, text)
+		message.ChatID = update.Message.Chat.ID
+		if err := telegramCall(ctx, token, "sendMessage", message); err != nil {
+			slog.WarnContext(ctx, "telegram reply failed", "error", err)
+		}
+	case update.CallbackQuery != nil:
+		callback := update.CallbackQuery
+		// stops the button's spinner
+		if err := telegramCall(ctx, token, "answerCallbackQuery", map[string]string{"callback_query_id": callback.ID}); err != nil {
+			slog.WarnContext(ctx, "telegram callback answer failed", "error", err)
+		}
+		if callback.Message == nil || !strings.HasPrefix(callback.Data, telegramCallbackPrefix) {
+			break
+		}
+		// the day the button points at replaces the message it's on
+		message := telegramReply(ctx, strings.Replace(strings.TrimPrefix(callback.Data, telegramCallbackPrefix), ":", " ", -1))
+		message.ChatID, message.MessageID = callback.Message.Chat.ID, callback.Message.MessageID
+		if err := telegramCall(ctx, token, "editMessageText", message); err != nil {
+			slog.WarnContext(ctx, "telegram edit failed", "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// telegramReply is the day in the text format, with a button for the day after it
+func telegramReply(ctx context.Context, text string) telegramMessage {
+	query, problem := parseChatQuery(text)
+	if problem != "" {
+		return telegramMessage{Text: problem}
+	}
+	skateTimesMap, opts, err := query.lookup(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "telegram command lookup failed", "date", query.date, "error", err)
+		return telegramMessage{Text: "Couldn't reach the booking system, try again in a minute."}
+	}
+	opts.emoji = true
+
+	next := query.dateObj.AddDate(0, 0, 1)
+	label := "Check " + next.Format("Mon Jan 2")
+	if _, today, _ := normalizeDate(""); next.Equal(today.AddDate(0, 0, 1)) {
+		label = "Check tomorrow"
+	}
+	button := telegramButton{Text: label + " ▶", CallbackData: telegramCallbackPrefix + next.Format("2006-01-02") + ":" + strings.ToLower(query.rink.name)}
+	return telegramMessage{
+		Text:        getFormattedTimes(query.date, query.dateObj, skateTimesMap, opts).String(),
+		ReplyMarkup: &telegramKeyboard{InlineKeyboard: [][]telegramButton{{button}}},
+	}
+}
+
+// telegramCall calls a Bot API method with a JSON body
+func telegramCall(ctx context.Context, token string, method string, body interface{}) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPI+token+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return errors.New(method + ": bad request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		// the URL has the bot token in it, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.New(method + ": " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New(method + " answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
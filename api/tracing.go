@@ -0,0 +1,23 @@
+//go:build !otel
+
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// startRequestSpan is a no-op unless built with the otel tag, see tracing_otel.go
+func startRequestSpan(r *http.Request) (*http.Request, func(status int)) {
+	return r, func(int) {}
+}
+
+// startSpan is a no-op unless built with the otel tag, see tracing_otel.go
+func startSpan(ctx context.Context, name string) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+// startClientSpan is a no-op unless built with the otel tag, see tracing_otel.go
+func startClientSpan(req *http.Request) func(res *http.Response, err error) {
+	return func(*http.Response, error) {}
+}
@@ -0,0 +1,89 @@
+//go:build otel
+
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans from this service
+const tracerName = "bp-skate"
+
+var setupTracingOnce sync.Once
+
+// setupTracing installs an OTLP exporter configured by the standard OTEL_EXPORTER_OTLP_* and
+// OTEL_SERVICE_NAME env vars, and the W3C trace context propagator for incoming requests
+func setupTracing() {
+	setupTracingOnce.Do(func() {
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			slog.Warn("tracing disabled, could not create OTLP exporter", "error", err)
+			return
+		}
+		// serverless instances can be frozen at any time, so don't hold spans in a batch
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	})
+}
+
+// startRequestSpan starts the server span for a request, continuing any trace the caller
+// propagated. The span ends with the response status.
+func startRequestSpan(r *http.Request) (*http.Request, func(status int)) {
+	setupTracing()
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.target", r.URL.Path),
+		),
+	)
+	return r.WithContext(ctx), func(status int) {
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
+
+// startSpan starts a child span of whatever span is in ctx
+func startSpan(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name)
+	return ctx, func() { span.End() }
+}
+
+// startClientSpan starts a client span for one outbound request, ended with its status or error.
+// The trace context isn't injected into the request, Xola has no use for our trace IDs.
+func startClientSpan(req *http.Request) func(res *http.Response, err error) {
+	_, span := otel.Tracer(tracerName).Start(req.Context(), req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.String()),
+		),
+	)
+	return func(res *http.Response, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
+			if res.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, res.Status)
+			}
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,80 @@
+//go:build otel
+
+package handler
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans swaps the OTLP exporter for one that keeps the spans in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	setupTracingOnce.Do(func() {})
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestRequestSpans(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	exporter := recordSpans(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	get(t, "/api?date=2024-01-02", "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	var names []string
+	for _, span := range spans {
+		byName[span.Name] = span
+		names = append(names, span.Name)
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Errorf("%s is in trace %s, want the caller's %s", span.Name, span.SpanContext.TraceID(), traceID)
+		}
+	}
+	sort.Strings(names)
+	xolaCall := "GET " + strings.TrimPrefix(stub.URL, "http://")
+	for _, want := range []string{"GET /api", "cache.lookup", "xola.availability", "xola.fetch", xolaCall, "format"} {
+		if _, ok := byName[want]; !ok {
+			t.Errorf("no %q span in %v", want, names)
+		}
+	}
+
+	parents := []struct{ child, parent string }{
+		{"xola.availability", "GET /api"},
+		{"format", "GET /api"},
+		{"cache.lookup", "GET /api"},
+		{"xola.fetch", "GET /api"},
+		{xolaCall, "xola.fetch"},
+	}
+	for _, want := range parents {
+		if byName[want.child].Parent.SpanID() != byName[want.parent].SpanContext.SpanID() {
+			t.Errorf("%s isn't a child of %s", want.child, want.parent)
+		}
+	}
+	if status := byName["GET /api"].Attributes; len(status) == 0 {
+		t.Error("the request span has no attributes")
+	}
+}
+
+func TestCachedRequestSkipsXolaSpans(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	get(t, "/api?date=2024-01-02")
+	exporter := recordSpans(t)
+	get(t, "/api?date=2024-01-02")
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "xola.fetch" {
+			t.Errorf("a cache hit still has a %s span", span.Name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// twilioAPI is the REST API messages are sent through
+var twilioAPI = "https://api.twilio.com/2010-04-01"
+
+// sendSMS sends a text from TWILIO_FROM with the TWILIO_ACCOUNT_SID / TWILIO_AUTH_TOKEN account
+func sendSMS(ctx context.Context, to string, text string) error {
+	return sendTwilioMessage(ctx, os.Getenv("TWILIO_FROM"), to, text)
+}
+
+// sendTwilioMessage creates a message on the Twilio account
+func sendTwilioMessage(ctx context.Context, from string, to string, text string) error {
+	sid, token := os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN")
+	if sid == "" || token == "" || from == "" {
+		return errors.New("Twilio isn't configured")
+	}
+	form := url.Values{"From": {from}, "To": {to}, "Body": {text}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, twilioAPI+"/Accounts/"+sid+"/Messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(sid, token)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New("Twilio answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// validTwilioSignature checks X-Twilio-Signature: base64 HMAC-SHA1, keyed with the auth token, of the
+// URL Twilio called followed by every POST parameter's name and value, sorted by name
+func validTwilioSignature(token string, webhookURL string, form url.Values, signature string) bool {
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mac := hmac.New(sha1.New, []byte(token))
+	mac.Write([]byte(webhookURL))
+	for _, name := range names {
+		for _, value := range form[name] {
+			mac.Write([]byte(name + value))
+		}
+	}
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// twilioWebhookURL is the URL Twilio signs, TWILIO_WEBHOOK_URL when the deployment sits behind
+// something that changes the host or path
+func twilioWebhookURL(r *http.Request) string {
+	if configured := os.Getenv("TWILIO_WEBHOOK_URL"); configured != "" {
+		return configured
+	}
+	return "https://" + r.Host + r.URL.RequestURI()
+}
+
+// twimlResponse is the reply Twilio texts back
+type twimlResponse struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message"`
+}
+
+// twilioSMSHandler is the incoming message webhook of the Twilio number: "SKATE SAT" (or just
+// "sat") replies with that day's sessions. It's a 404 until TWILIO_AUTH_TOKEN is set.
+func twilioSMSHandler(w http.ResponseWriter, r *http.Request) {
+	token := os.Getenv("TWILIO_AUTH_TOKEN")
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChatBody))
+	form, parseErr := url.ParseQuery(string(body))
+	if err != nil || parseErr != nil || !validTwilioSignature(token, twilioWebhookURL(r), form, r.Header.Get("X-Twilio-Signature")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Bad signature"))
+		return
+	}
+	writeTwiML(w, smsReply(r.Context(), form.Get("Body")))
+}
+
+// smsReply answers a text message with the day it names, "skate" in front is optional
+func smsReply(ctx context.Context, text string) string {
+	words := strings.Fields(text)
+	if len(words) > 0 && strings.EqualFold(words[0], "skate") {
+		words = words[1:]
+	}
+	query, problem := parseChatQuery(strings.Join(words, " "))
+	if problem != "" {
+		return problem
+	}
+	skateTimesMap, opts, err := query.lookup(ctx)
+	if err != nil {
+		return "Couldn't reach the booking system, try again in a minute."
+	}
+	opts.emoji = true
+	return strings.TrimSpace(getFormattedTimes(query.date, query.dateObj, skateTimesMap, opts).String())
+}
+
+func writeTwiML(w http.ResponseWriter, message string) {
+	data, _ := xml.Marshal(twimlResponse{Message: message})
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
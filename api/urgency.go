@@ -0,0 +1,71 @@
+package handler
+
+// urgency levels, how quickly a session is likely to go
+const (
+	urgencySoldOut = "soldOut"
+	urgencyLow     = "low"
+	urgencySome    = "some"
+	urgencyPlenty  = "plenty"
+)
+
+// lowSpots and someSpots are the upper bounds of the low (red) and some (yellow) levels
+const (
+	lowSpots  = 3
+	someSpots = 10
+)
+
+// urgencyEmoji is what ?emoji=1 puts in front of each session
+var urgencyEmoji = map[string]string{
+	urgencySoldOut: "⛔",
+	urgencyLow:     "🔴",
+	urgencySome:    "🟡",
+	urgencyPlenty:  "🟢",
+}
+
+// urgency buckets a session's spots. Masked counts (SPOTS_FLOOR) are always low.
+func urgency(spots int) string {
+	switch {
+	case spots == 0:
+		return urgencySoldOut
+	case spots <= lowSpots || isLimited(spots):
+		return urgencyLow
+	case spots <= someSpots:
+		return urgencySome
+	}
+	return urgencyPlenty
+}
+
+// dayUrgency is the level every open session of the day shares, sold out when none are open and
+// "" for a mix
+func dayUrgency(keys []string, cleanedMap map[string]int) string {
+	levels := map[string]bool{}
+	for _, skateTime := range keys {
+		if spots := cleanedMap[skateTime]; spots > 0 {
+			levels[urgency(spots)] = true
+		}
+	}
+	if len(levels) == 0 {
+		return urgencySoldOut
+	}
+	if len(levels) > 1 {
+		return ""
+	}
+	for level := range levels {
+		return level
+	}
+	return ""
+}
+
+// dayFlag sums up a day with sessions listed: plenty when every open session is green, nearly
+// sold out when every open one is red. Mixed days get no flag, the per-session emoji say enough.
+func dayFlag(keys []string, cleanedMap map[string]int) string {
+	switch dayUrgency(keys, cleanedMap) {
+	case urgencySoldOut:
+		return urgencyEmoji[urgencySoldOut] + " Sold out"
+	case urgencyPlenty:
+		return urgencyEmoji[urgencyPlenty] + " Plenty of spots"
+	case urgencyLow:
+		return urgencyEmoji[urgencyLow] + " Nearly sold out"
+	}
+	return ""
+}
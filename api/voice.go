@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+)
+
+// venueName is how the default rink is referred to in spoken responses
+const venueName = "Bryant Park"
+
+// formatVoiceSummary renders the day as one sentence meant to be read aloud by a voice assistant,
+// so it avoids symbols and lists and spells out the common cases
+func formatVoiceSummary(rk rink, dateObj time.Time, keys []string, cleanedMap map[string]int, now time.Time) string {
+	venue := rk.label()
+	day := spokenDay(dateObj, now)
+	if len(keys) == 0 {
+		if isClosedDay(rk, dateObj) {
+			return venue + " is closed " + day + "."
+		}
+		switch outOfSeasonMessage(dateObj) {
+		case "Season hasn't started":
+			return "The skating season at " + venue + " hasn't started yet."
+		case "Season has ended":
+			return "The skating season at " + venue + " has ended."
+		}
+		return venue + " is sold out " + day + "."
+	}
+
+	total := 0
+	for _, skateTime := range keys {
+		total += cleanedMap[skateTime]
+	}
+	earliest := spokenTime(keys[0])
+	spots := pluralize(total, "spot")
+	// a low total would give away the masked counts
+	if isLimited(total) {
+		spots = "limited spots"
+	}
+	if len(keys) == 1 {
+		return venue + " has " + spots + " " + day + ", in one session at " + earliest + "."
+	}
+	return venue + " has " + spots + " across " + strconv.Itoa(len(keys)) + " sessions " + day + ", with the earliest at " + earliest + "."
+}
+
+// spokenDay is "today", "tomorrow", "on Friday" for the coming week, or "on Friday, January 2" further out
+func spokenDay(dateObj time.Time, now time.Time) string {
+	switch days := daysFrom(now, dateObj); {
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "tomorrow"
+	case days == -1:
+		return "yesterday"
+	case days > 1 && days < 7:
+		return "on " + dateObj.Format("Monday")
+	}
+	return "on " + dateObj.Format("Monday, January 2")
+}
+
+// daysFrom counts calendar days from now's date to dateObj's date, ignoring the time of day
+func daysFrom(now time.Time, dateObj time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	day := time.Date(dateObj.Year(), dateObj.Month(), dateObj.Day(), 0, 0, 0, 0, time.UTC)
+	return int(day.Sub(today).Hours() / 24)
+}
+
+// spokenTime is "10 AM" on the hour and "10:30 AM" otherwise
+func spokenTime(skateTime string) string {
+	timeObj, _ := time.Parse("1504", skateTime)
+	if timeObj.Minute() == 0 {
+		return timeObj.Format("3 PM")
+	}
+	return timeObj.Format("3:04 PM")
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(count) + " " + noun + "s"
+}
+
+// relativeHeader is the text header with a relative day label, e.g. "Bryant Park — Tomorrow (Jan 3):".
+// Dates more than a week out just get the date.
+func relativeHeader(venue string, dateObj time.Time, now time.Time) string {
+	var label string
+	switch days := daysFrom(now, dateObj); {
+	case days == 0:
+		label = "Today"
+	case days == 1:
+		label = "Tomorrow"
+	case days == -1:
+		label = "Yesterday"
+	case days > 1 && days < 7:
+		label = dateObj.Format("Monday")
+	default:
+		return venue + " — " + dateObj.Format("Jan 2, 2006") + ":"
+	}
+	return venue + " — " + label + " (" + dateObj.Format("Jan 2") + "):"
+}
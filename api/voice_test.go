@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+func TestFormatVoiceSummary(t *testing.T) {
+	// a Tuesday afternoon at the rink
+	now := time.Date(2024, 1, 2, 14, 0, 0, 0, venueLocation())
+	tests := []struct {
+		name   string
+		date   string
+		counts map[string]int
+		env    map[string]string
+		want   string
+	}{
+		{"several sessions", "2024-01-03", map[string]int{"1000": 12, "1530": 15}, nil, "Bryant Park has 27 spots across 2 sessions tomorrow, with the earliest at 10 AM."},
+		{"one session", "2024-01-02", map[string]int{"1930": 1}, nil, "Bryant Park has 1 spot today, in one session at 7:30 PM."},
+		{"later this week", "2024-01-05", map[string]int{"1200": 2}, nil, "Bryant Park has 2 spots on Friday, in one session at 12 PM."},
+		{"further out", "2024-01-20", map[string]int{"1200": 2}, nil, "Bryant Park has 2 spots on Saturday, January 20, in one session at 12 PM."},
+		{"sold out", "2024-01-03", nil, nil, "Bryant Park is sold out tomorrow."},
+		{"closed", "2024-01-08", nil, map[string]string{"CLOSED_WEEKDAYS": "mon"}, "Bryant Park is closed on Monday."},
+		{"before the season", "2024-01-03", nil, map[string]string{"SEASON_START": "2024-02-01"}, "The skating season at Bryant Park hasn't started yet."},
+		{"after the season", "2024-01-03", nil, map[string]string{"SEASON_END": "2024-01-01"}, "The skating season at Bryant Park has ended."},
+		{"limited", "2024-01-03", map[string]int{"1000": 2}, map[string]string{"SPOTS_FLOOR": "3"}, "Bryant Park has limited spots tomorrow, in one session at 10 AM."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetAvailability(t)
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			keys, cleanedMap := xola.CleanSkateTimes(test.date, map[string]map[string]int{test.date: test.counts})
+			got := formatVoiceSummary(configuredRinks()[defaultRink], mustDate(t, test.date), keys, cleanedMap, now)
+			if got != test.want {
+				t.Errorf("got  %q\nwant %q", got, test.want)
+			}
+			if strings.ContainsAny(got, "—$&/") {
+				t.Errorf("%q has symbols that read poorly aloud", got)
+			}
+		})
+	}
+}
+
+func TestVoiceFormat(t *testing.T) {
+	newXolaStub(t, `{"2024-01-02": {"1500": 4, "1600": 0}}`)
+	t.Setenv("RINKS", "Wollman=wollman-experience")
+	t.Setenv("ENABLED_FORMATS", "text,voice")
+	w := get(t, "/api?date=2024-01-02&format=voice&rink=wollman")
+	if body := strings.TrimSpace(w.Body.String()); !strings.HasPrefix(body, "Wollman has 4 spots ") || !strings.HasSuffix(body, ", in one session at 3 PM.") {
+		t.Errorf("body = %q", w.Body.String())
+	}
+}
+
+func TestRelativeHeader(t *testing.T) {
+	// late on a Tuesday at the rink, still Tuesday there though it's Wednesday in UTC
+	now := time.Date(2024, 1, 2, 23, 30, 0, 0, venueLocation())
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2024-01-02", "Bryant Park — Today (Jan 2):"},
+		{"2024-01-03", "Bryant Park — Tomorrow (Jan 3):"},
+		{"2024-01-01", "Bryant Park — Yesterday (Jan 1):"},
+		{"2024-01-06", "Bryant Park — Saturday (Jan 6):"},
+		{"2024-01-09", "Bryant Park — Jan 9, 2024:"},
+		{"2023-12-25", "Bryant Park — Dec 25, 2023:"},
+	}
+	for _, test := range tests {
+		if got := relativeHeader("Bryant Park", mustDate(t, test.date), now); got != test.want {
+			t.Errorf("relativeHeader(%s) = %q, want %q", test.date, got, test.want)
+		}
+	}
+}
+
+func TestRelativeHeaderInText(t *testing.T) {
+	tomorrow := time.Now().In(venueLocation()).AddDate(0, 0, 1)
+	date := tomorrow.Format("2006-01-02")
+	newXolaStub(t, `{"`+date+`": {"1500": 4}}`)
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api?date=tomorrow&relative=1", "Bryant Park — Tomorrow (" + tomorrow.Format("Jan 2") + "):\n"},
+		{"/api?date=tomorrow", "For " + tomorrow.Format("Jan 2, 2006") + ":\n"},
+	}
+	for _, test := range tests {
+		if w := get(t, test.target); !strings.HasPrefix(w.Body.String(), test.want) {
+			t.Errorf("%s: body = %q, want it to start %q", test.target, w.Body.String(), test.want)
+		}
+	}
+}
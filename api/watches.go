@@ -0,0 +1,620 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// kvWatchesHash is the KV hash watches live in, one field per ID
+const kvWatchesHash = "bp-skate:watches"
+
+// maxWatchSpan caps how far a recurring watch runs, each of its dates is polled
+const maxWatchSpan = 92 * 24 * time.Hour
+
+// watch is someone waiting for a date, or a window of it, to open up. A recurring watch covers
+// every Every weekday from Date through Through. From and Until are HHMM session start times, both
+// inclusive and optional. The condition is AtLeast (a session with at least that many spots, 1 when
+// unset) or Below (a session dropping under that many, a sell-out warning). Matched are the sessions
+// ("<date> <HHMM>") meeting it at the last check, CheckedDates the dates checked at least once.
+// Token signs the unsubscribe and snooze links in notifications, none go out before SnoozedUntil.
+// Secret signs webhook deliveries, it's only shown when the watch is created.
+type watch struct {
+	ID           string     `json:"id"`
+	Owner        string     `json:"owner,omitempty"`
+	Rink         string     `json:"rink"`
+	Date         string     `json:"date"`
+	Every        []string   `json:"every,omitempty"`
+	Through      string     `json:"through,omitempty"`
+	From         string     `json:"from,omitempty"`
+	Until        string     `json:"until,omitempty"`
+	AtLeast      int        `json:"atLeast,omitempty"`
+	Below        int        `json:"below,omitempty"`
+	Channel      string     `json:"channel"`
+	Address      string     `json:"address"`
+	Matched      []string   `json:"matched,omitempty"`
+	CheckedDates []string   `json:"checkedDates,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	NotifiedAt   *time.Time `json:"notifiedAt,omitempty"`
+	Token        string     `json:"token,omitempty"`
+	Secret       string     `json:"secret,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozedUntil,omitempty"`
+}
+
+// inWindow reports whether a session (HHMM) starts inside the watch's window
+func (w watch) inWindow(skateTime string) bool {
+	return (w.From == "" || skateTime >= w.From) && (w.Until == "" || skateTime <= w.Until)
+}
+
+// dates are the days the watch covers, in order
+func (w watch) dates() []string {
+	if w.Through == "" {
+		return []string{w.Date}
+	}
+	start, _ := time.Parse("2006-01-02", w.Date)
+	end, _ := time.Parse("2006-01-02", w.Through)
+	var dates []string
+	for _, day := range rangeDates(start, end) {
+		for _, name := range w.Every {
+			if weekday, ok := parseWeekday(name); ok && weekday == day.Weekday() {
+				dates = append(dates, day.Format("2006-01-02"))
+				break
+			}
+		}
+	}
+	return dates
+}
+
+// lastDate is the last day the watch covers, after which it's dropped
+func (w watch) lastDate() string {
+	if w.Through != "" {
+		return w.Through
+	}
+	return w.Date
+}
+
+// matches reports whether a session with this many spots meets the watch's condition
+func (w watch) matches(spots int) bool {
+	if w.Below > 0 {
+		return spots < w.Below
+	}
+	if w.AtLeast > 1 {
+		return spots >= w.AtLeast
+	}
+	return spots > 0
+}
+
+// watchStore is where watches are kept, the KV store when one is configured, otherwise memory.
+// update re-reads the watch and saves it with change applied, so a poll and an unsubscribe or
+// snooze landing at the same time don't undo each other. It's false, saving nothing, when the watch
+// is gone.
+type watchStore interface {
+	all() []watch
+	put(w watch)
+	update(id string, change func(*watch)) (watch, bool)
+	remove(id string) bool
+}
+
+func watches() watchStore {
+	if store, ok := kvStoreFromEnv(); ok {
+		return kvWatchStore{store}
+	}
+	return localWatches
+}
+
+type memoryWatchStore struct {
+	sync.Mutex
+	byID map[string]watch
+}
+
+var localWatches = &memoryWatchStore{byID: map[string]watch{}}
+
+func (store *memoryWatchStore) all() []watch {
+	store.Lock()
+	defer store.Unlock()
+	all := make([]watch, 0, len(store.byID))
+	for _, w := range store.byID {
+		all = append(all, w)
+	}
+	return all
+}
+
+func (store *memoryWatchStore) put(w watch) {
+	store.Lock()
+	defer store.Unlock()
+	store.byID[w.ID] = w
+}
+
+func (store *memoryWatchStore) update(id string, change func(*watch)) (watch, bool) {
+	store.Lock()
+	defer store.Unlock()
+	w, ok := store.byID[id]
+	if !ok {
+		return watch{}, false
+	}
+	change(&w)
+	store.byID[id] = w
+	return w, true
+}
+
+func (store *memoryWatchStore) remove(id string) bool {
+	store.Lock()
+	defer store.Unlock()
+	_, ok := store.byID[id]
+	delete(store.byID, id)
+	return ok
+}
+
+type kvWatchStore struct {
+	kv kvStore
+}
+
+func (store kvWatchStore) all() []watch {
+	var fields []string
+	if err := store.kv.command(&fields, "HGETALL", kvWatchesHash); err != nil {
+		slog.Warn("KV watch lookup failed", "error", err)
+		return nil
+	}
+	var all []watch
+	for i := 0; i+1 < len(fields); i += 2 {
+		var w watch
+		if json.Unmarshal([]byte(fields[i+1]), &w) == nil {
+			all = append(all, w)
+		}
+	}
+	return all
+}
+
+func (store kvWatchStore) put(w watch) {
+	data, _ := json.Marshal(w)
+	var added int
+	if err := store.kv.command(&added, "HSET", kvWatchesHash, w.ID, string(data)); err != nil {
+		slog.Warn("KV watch save failed", "error", err)
+	}
+}
+
+// kvSwapScript sets a hash field only while it still holds the value it was read with, -1 otherwise
+const kvSwapScript = `if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then return redis.call("HSET", KEYS[1], ARGV[1], ARGV[3]) end return -1`
+
+// update is a compare-and-swap on the watch's field, retried a few times when someone else saved
+// it in between
+func (store kvWatchStore) update(id string, change func(*watch)) (watch, bool) {
+	for attempt := 0; attempt < 3; attempt++ {
+		var stored *string
+		if err := store.kv.command(&stored, "HGET", kvWatchesHash, id); err != nil {
+			slog.Warn("KV watch lookup failed", "error", err)
+			return watch{}, false
+		}
+		var w watch
+		if stored == nil || json.Unmarshal([]byte(*stored), &w) != nil {
+			return watch{}, false
+		}
+		change(&w)
+		data, _ := json.Marshal(w)
+		var swapped int
+		if err := store.kv.command(&swapped, "EVAL", kvSwapScript, "1", kvWatchesHash, id, *stored, string(data)); err != nil {
+			slog.Warn("KV watch save failed", "error", err)
+			return watch{}, false
+		}
+		if swapped >= 0 {
+			return w, true
+		}
+	}
+	slog.Warn("watch kept changing, update dropped", "id", id)
+	return watch{}, false
+}
+
+func (store kvWatchStore) remove(id string) bool {
+	var removed int
+	if err := store.kv.command(&removed, "HDEL", kvWatchesHash, id); err != nil {
+		slog.Warn("KV watch delete failed", "error", err)
+	}
+	return removed > 0
+}
+
+// watchEvent is what the webhook channel POSTs, a watch_opened or (for below) watch_selling_out event
+type watchEvent struct {
+	Event          string      `json:"event"`
+	WatchID        string      `json:"watchId"`
+	Rink           string      `json:"rink"`
+	Date           string      `json:"date"`
+	Slots          []watchSlot `json:"slots"`
+	Text           string      `json:"text"`
+	BookURL        string      `json:"bookUrl"`
+	UnsubscribeURL string      `json:"unsubscribeUrl,omitempty"`
+	SnoozeURL      string      `json:"snoozeUrl,omitempty"`
+	Timestamp      int64       `json:"timestamp"`
+}
+
+type watchSlot struct {
+	Time  string `json:"time"`
+	Spots int    `json:"spots"`
+}
+
+// validWatchChannel reports whether watches can notify on the channel: the alert channels, or a
+// webhook
+func validWatchChannel(channel string) bool {
+	_, ok := alertChannels[channel]
+	return ok || channel == "webhook"
+}
+
+// checkWatches is called with every fresh fetch from Xola, start through end. Each watch is
+// notified, in the background, of the sessions on its dates in there that have come to meet its
+// condition since the last check, one message per date. A sell-out warning's first check of a date
+// is only the baseline, sessions already low when it was made aren't news. Snoozed watches keep
+// tracking their matches without notifying. Watches whose matches changed are saved, unless they
+// were removed in the meantime.
+func checkWatches(rk rink, start string, end string, skateTimesMap map[string]map[string]int) {
+	store := watches()
+	for _, w := range store.all() {
+		if !strings.EqualFold(w.Rink, rk.name) || w.lastDate() < start || w.Date > end {
+			continue
+		}
+		matchedBefore := map[string]bool{}
+		for _, session := range w.Matched {
+			matchedBefore[session] = true
+		}
+		checkedBefore := map[string]bool{}
+		for _, date := range w.CheckedDates {
+			checkedBefore[date] = true
+		}
+		// matches on dates outside this fetch carry over
+		var matched []string
+		for _, session := range w.Matched {
+			if len(session) > 10 && (session[:10] < start || session[:10] > end) {
+				matched = append(matched, session)
+			}
+		}
+		var news [][]availabilityChange
+		changed := false
+		for _, date := range w.dates() {
+			if date < start || date > end {
+				continue
+			}
+			if !checkedBefore[date] {
+				w.CheckedDates = append(w.CheckedDates, date)
+				changed = true
+			}
+			// sold out sessions count too, they're below any threshold
+			keys, counts := xola.SortedSkateTimes(date, skateTimesMap, true)
+			var opened []availabilityChange
+			for _, skateTime := range keys {
+				if !w.inWindow(skateTime) || !w.matches(counts[skateTime]) {
+					continue
+				}
+				session := date + " " + skateTime
+				matched = append(matched, session)
+				if !matchedBefore[session] && (checkedBefore[date] || w.Below == 0) {
+					opened = append(opened, availabilityChange{Rink: rk, Date: date, Time: skateTime, Spots: counts[skateTime]})
+				}
+			}
+			if len(opened) > 0 {
+				news = append(news, opened)
+			}
+		}
+		sort.Strings(matched)
+		if !changed && strings.Join(matched, ",") == strings.Join(w.Matched, ",") {
+			continue
+		}
+		// only the fields the check owns are written back, onto the watch as it is now
+		now, notify := time.Now().UTC(), false
+		saved, ok := store.update(w.ID, func(current *watch) {
+			current.Matched, current.CheckedDates = matched, w.CheckedDates
+			notify = len(news) > 0 && (current.SnoozedUntil == nil || now.After(*current.SnoozedUntil))
+			if notify {
+				current.NotifiedAt = &now
+			}
+		})
+		if ok && notify {
+			goBackground(func() {
+				for _, sessions := range news {
+					notifyWatch(saved, rk, sessions)
+				}
+			})
+		}
+	}
+}
+
+// watchText is the message for sessions on one date newly meeting the watch's condition
+func watchText(w watch, rk rink, sessions []availabilityChange) string {
+	date := sessions[0].Date
+	if w.Below == 0 {
+		return alertText(rk, date, sessions)
+	}
+	dateObj, _ := time.Parse("2006-01-02", date)
+	var listed []string
+	for _, session := range sessions {
+		timeObj, _ := time.Parse("1504", session.Time)
+		left := spotsText(session.Spots) + " left"
+		if session.Spots == 0 {
+			left = "sold out"
+		}
+		listed = append(listed, timeObj.Format("3:04 PM")+" ("+left+")")
+	}
+	label := "Skating"
+	if multiRink() {
+		label = rk.label() + " skating"
+	}
+	return label + " is selling out for " + dateObj.Format("Mon Jan 2") + ": " + strings.Join(listed, ", ") + ". Book: " + bookingURL(rk, date)
+}
+
+// notifyWatch sends sessions on one date on the watch's channel
+func notifyWatch(w watch, rk rink, sessions []availabilityChange) {
+	text := watchText(w, rk, sessions)
+	date := sessions[0].Date
+	var err error
+	if w.Channel == "webhook" {
+		event := watchEvent{Event: "watch_opened", WatchID: w.ID, Rink: rk.name, Date: date, Text: text, BookURL: bookingURL(rk, date), Timestamp: time.Now().Unix()}
+		event.UnsubscribeURL, event.SnoozeURL = watchLinks(w)
+		if w.Below > 0 {
+			event.Event = "watch_selling_out"
+		}
+		for _, session := range sessions {
+			timeObj, _ := time.Parse("1504", session.Time)
+			event.Slots = append(event.Slots, watchSlot{Time: timeObj.Format("15:04"), Spots: session.Spots})
+		}
+		err = postWebhook(publicWebhookClient, w.Address, w.Secret, event, event.Timestamp)
+	} else {
+		if unsubscribe, snooze := watchLinks(w); unsubscribe != "" {
+			text += "\n\nSnooze for a day: " + snooze + "\nStop this watch: " + unsubscribe
+		}
+		err = alertChannels[w.Channel](context.Background(), w.Address, text)
+	}
+	if err != nil {
+		slog.Warn("watch notification failed", "id", w.ID, "channel", w.Channel, "date", date, "error", err)
+	}
+}
+
+// pollWatches fetches every watched date still to come fresh from Xola, which runs checkWatches for
+// it. Watches whose last date has passed are dropped. It returns how many dates were checked.
+func pollWatches(ctx context.Context) int {
+	store := watches()
+	today := time.Now().In(venueLocation()).Format("2006-01-02")
+	type watchedDay struct {
+		rink string
+		date string
+	}
+	days := map[watchedDay]bool{}
+	for _, w := range store.all() {
+		if w.lastDate() < today {
+			store.remove(w.ID)
+			continue
+		}
+		for _, date := range w.dates() {
+			if date >= today {
+				days[watchedDay{w.Rink, date}] = true
+			}
+		}
+	}
+	checked := 0
+	for day := range days {
+		rk, ok := configuredRinks()[day.rink]
+		dateObj, _ := time.Parse("2006-01-02", day.date)
+		if !ok || isClosedDay(rk, dateObj) {
+			continue
+		}
+		if _, _, err := querySkateTimesAPI(withFreshData(withRink(ctx, rk)), day.date); err != nil {
+			slog.Warn("watch poll failed", "rink", day.rink, "date", day.date, "error", err)
+			continue
+		}
+		checked++
+	}
+	return checked
+}
+
+// init starts the watch poller when WATCH_INTERVAL_SECONDS is set. Like the refresher it needs
+// the long-running server, on Vercel have a cron job POST to /admin/watches instead.
+func init() {
+	loadConfig()
+	interval := time.Duration(envInt("WATCH_INTERVAL_SECONDS", 0)) * time.Second
+	if interval == 0 {
+		return
+	}
+	go runWatchPoller(interval)
+}
+
+// runWatchPoller polls the watched dates every interval until Shutdown
+func runWatchPoller(interval time.Duration) {
+	slog.Info("starting the watch poller", "interval", interval.String())
+	for {
+		done := make(chan struct{})
+		if !goBackground(func() { pollWatches(context.Background()); close(done) }) {
+			return
+		}
+		<-done
+		select {
+		case <-time.After(interval):
+		case <-background.stop:
+			return
+		}
+	}
+}
+
+// watchesHandler manages the caller's watches, each API key only sees its own: GET lists them,
+// POST {"date", "from", "until", "atLeast" or "below", "rink", "channel", "address"} creates one, DELETE ?id= removes one
+func watchesHandler(w http.ResponseWriter, r *http.Request) {
+	store := watches()
+	owner := apiKeyName(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		listed := []watch{}
+		for _, existing := range store.all() {
+			if existing.Owner == owner {
+				existing.Secret = ""
+				listed = append(listed, existing)
+			}
+		}
+		sort.Slice(listed, func(i, j int) bool { return listed[i].Date+listed[i].ID < listed[j].Date+listed[j].ID })
+		writeJSONResponse(w, listed)
+	case http.MethodPost:
+		created, problem := newWatch(r)
+		if problem != "" {
+			writeJSONError(w, http.StatusBadRequest, problem)
+			return
+		}
+		created.Owner = owner
+		store.put(created)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		data, _ := json.Marshal(created)
+		w.Write(data)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		for _, existing := range store.all() {
+			if existing.ID == id && existing.Owner == owner && store.remove(id) {
+				writeJSONResponse(w, map[string]string{"removed": id})
+				return
+			}
+		}
+		writeJSONError(w, http.StatusNotFound, "No watch with ID "+id)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to list, POST to create or DELETE to remove")
+	}
+}
+
+// newWatch reads and checks a POSTed watch, the problem is a message for the caller
+func newWatch(r *http.Request) (watch, string) {
+	var body struct {
+		Date    string `json:"date"`
+		Every   string `json:"every"`
+		Through string `json:"through"`
+		From    string `json:"from"`
+		Until   string `json:"until"`
+		Rink    string `json:"rink"`
+		AtLeast int    `json:"atLeast"`
+		Below   int    `json:"below"`
+		Channel string `json:"channel"`
+		Address string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return watch{}, `Expected {"date": "friday", "from": "18:00", "until": "21:00", "atLeast": 4, "channel": "sms", "address": "+15551234567"}, or "every": "sat" and "through" instead of a date. Everything but the channel and address is optional`
+	}
+	date, dateObj, err := normalizeDate(body.Date)
+	if err != nil {
+		return watch{}, "Could not understand the date " + body.Date
+	}
+	if _, today, _ := normalizeDate(""); dateObj.Before(today) {
+		return watch{}, date + " has already passed"
+	}
+	rinkName := strings.ToLower(body.Rink)
+	if rinkName == "" {
+		rinkName = defaultRink
+	}
+	if _, ok := configuredRinks()[rinkName]; !ok {
+		return watch{}, "Unknown rink " + body.Rink
+	}
+	created := watch{Rink: rinkName, Date: date, Channel: strings.ToLower(body.Channel), Address: strings.TrimSpace(body.Address), CreatedAt: time.Now().UTC()}
+	if body.Through != "" && body.Every == "" {
+		return watch{}, "through only goes with every"
+	}
+	if body.Every != "" {
+		for _, name := range listItems(body.Every) {
+			weekday, ok := parseWeekday(strings.ToLower(name))
+			if !ok {
+				return watch{}, name + " is not a weekday"
+			}
+			created.Every = append(created.Every, strings.ToLower(weekday.String()))
+		}
+		through := dateObj.AddDate(0, 1, 0)
+		if body.Through != "" {
+			if _, through, err = normalizeDate(body.Through); err != nil {
+				return watch{}, "Could not understand the date " + body.Through
+			}
+		}
+		switch {
+		case through.Before(dateObj):
+			return watch{}, "through is before date"
+		case through.Sub(dateObj) > maxWatchSpan:
+			return watch{}, "A recurring watch covers at most " + strconv.Itoa(int(maxWatchSpan.Hours()/24)) + " days"
+		}
+		created.Through = through.Format("2006-01-02")
+		if len(created.dates()) == 0 {
+			return watch{}, "No " + body.Every + " between " + created.Date + " and " + created.Through
+		}
+	}
+	for _, bound := range []struct {
+		value  string
+		target *string
+	}{{body.From, &created.From}, {body.Until, &created.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		skateTime, ok := xola.NormalizeSlotTime(bound.value)
+		if !ok {
+			return watch{}, "Times are HH:MM, got " + bound.value
+		}
+		*bound.target = skateTime
+	}
+	if created.From != "" && created.Until != "" && created.From > created.Until {
+		return watch{}, "from is after until"
+	}
+	switch {
+	case body.AtLeast < 0 || body.Below < 0:
+		return watch{}, "atLeast and below can't be negative"
+	case body.AtLeast > 0 && body.Below > 0:
+		return watch{}, "Use atLeast or below, not both"
+	}
+	created.AtLeast, created.Below = body.AtLeast, body.Below
+	if !validWatchChannel(created.Channel) {
+		return watch{}, "channel must be sms, whatsapp, email or webhook"
+	}
+	if created.Address == "" || (created.Channel == "webhook" && validURL(created.Address) != nil) {
+		return watch{}, "address must be the phone number, email address or webhook URL to notify"
+	}
+	if created.Channel == "webhook" {
+		// deliveries only dial public addresses, see publicWebhookClient. Catch the obvious misses now.
+		parsed, _ := url.Parse(created.Address)
+		if parsed.Scheme != "https" {
+			return watch{}, "Webhook addresses must be https"
+		}
+		if ip := net.ParseIP(parsed.Hostname()); strings.EqualFold(parsed.Hostname(), "localhost") || (ip != nil && !publicIP(ip)) {
+			return watch{}, "Webhook addresses must be public"
+		}
+	}
+	id, idErr := newAPIKey()
+	token, tokenErr := newAPIKey()
+	secret, secretErr := newAPIKey()
+	if idErr != nil || tokenErr != nil || secretErr != nil {
+		return watch{}, "Could not generate an ID"
+	}
+	created.ID, created.Token = id[:12], token[:22]
+	if created.Channel == "webhook" {
+		created.Secret = secret
+	}
+	return created, ""
+}
+
+// adminWatchesHandler sees every watch (GET), or polls the watched dates now (POST), which is what
+// a serverless deployment's cron job calls
+func adminWatchesHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		all := watches().all()
+		for i := range all {
+			all[i].Token, all[i].Secret = "", ""
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Date+all[i].ID < all[j].Date+all[j].ID })
+		writeJSONResponse(w, all)
+	case http.MethodPost:
+		writeJSONResponse(w, map[string]int{"checked": pollWatches(r.Context())})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Use GET to list or POST to poll")
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordAlerts swaps the sms channel for one that keeps what it would have sent
+func recordAlerts(t *testing.T) func() []string {
+	var mu sync.Mutex
+	var sent []string
+	original := alertChannels["sms"]
+	alertChannels["sms"] = func(ctx context.Context, to string, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, to+": "+text)
+		return nil
+	}
+	t.Cleanup(func() { alertChannels["sms"] = original })
+	return func() []string {
+		background.wg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return sent
+	}
+}
+
+func TestWatchesAtMixedCaseRinks(t *testing.T) {
+	date := time.Now().In(venueLocation()).AddDate(0, 0, 3).Format("2006-01-02")
+	tests := []struct {
+		name  string
+		rinks string
+		rink  string
+	}{
+		{"bp", "", ""},
+		{"lowercase name", "lasker=lasker-experience", "lasker"},
+		{"mixed case name", "Wollman=wollman-experience", "Wollman"},
+		{"asked for in another case", "Wollman=wollman-experience", "WOLLMAN"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{"`+date+`": {"1500": 0}}`)
+			t.Setenv("RINKS", test.rinks)
+			localWatches.Lock()
+			localWatches.byID = map[string]watch{}
+			localWatches.Unlock()
+			sent := recordAlerts(t)
+
+			created := send(t, http.MethodPost, "/api/watches", `{"date": "`+date+`", "rink": "`+test.rink+`", "channel": "sms", "address": "+15551234567"}`)
+			if created.Code != http.StatusCreated {
+				t.Fatalf("status %d, body %s", created.Code, created.Body.String())
+			}
+			get(t, "/api?date="+date+"&rink="+test.rink)
+			stub.answer(http.StatusOK, `{"`+date+`": {"1500": 4}}`)
+			get(t, "/api?date="+date+"&fresh=1&rink="+test.rink)
+
+			messages := sent()
+			if len(messages) != 1 || !strings.HasPrefix(messages[0], "+15551234567: ") || !strings.Contains(messages[0], "3:00 PM (4 spots)") {
+				t.Errorf("sent %q, want one message for 3:00 PM", messages)
+			}
+			var listed []watch
+			if err := json.Unmarshal(get(t, "/api/watches").Body.Bytes(), &listed); err != nil || len(listed) != 1 || len(listed[0].Matched) != 1 {
+				t.Errorf("watches %+v, want the opened session matched", listed)
+			}
+		})
+	}
+}
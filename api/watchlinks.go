@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSnooze is how long the snooze link in a notification quiets the watch
+const defaultSnooze = "1d"
+
+// watchLinkPage is what the links in notifications open. The first visit only shows a button, so
+// the link checkers some mail clients run can't unsubscribe anyone by opening the link.
+var watchLinkPage = template.Must(template.New("watchLink").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, system-ui, sans-serif; margin: 0 auto; max-width: 32rem; padding: 1rem; color: #222; }
+h1 { font-size: 1.3rem; }
+button { font-size: 1rem; padding: .6rem 1.2rem; }
+@media (prefers-color-scheme: dark) { body { background: #111; color: #eee; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Button}}<form method="post"><button type="submit">{{.Button}}</button></form>
+{{end}}</body>
+</html>
+`))
+
+type watchLinkReply struct {
+	Title   string
+	Message string
+	Button  string
+}
+
+// parseSnooze reads a snooze length: hours or days like 6h or 2d, or anything time.ParseDuration
+// takes. 0 ends a snooze.
+func parseSnooze(value string) (time.Duration, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "0" {
+		return 0, true
+	}
+	length, err := time.ParseDuration(value)
+	if strings.HasSuffix(value, "d") {
+		days, convErr := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		length, err = time.Duration(days)*24*time.Hour, convErr
+	}
+	if err != nil || length <= 0 || length > maxWatchSpan {
+		return 0, false
+	}
+	return length, true
+}
+
+// watchLinks are the one-click unsubscribe and snooze links for the watch, empty without
+// PUBLIC_URL or for watches made before they had tokens
+func watchLinks(w watch) (unsubscribe string, snooze string) {
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if base == "" || w.Token == "" {
+		return "", ""
+	}
+	query := "?id=" + url.QueryEscape(w.ID) + "&token=" + url.QueryEscape(w.Token)
+	return base + "/api/watches/unsubscribe" + query, base + "/api/watches/snooze" + query + "&for=" + defaultSnooze
+}
+
+// watchActionHandler unsubscribes from (removes) or snoozes a watch. Links from notifications carry
+// the watch's token and get a page to confirm on; API callers POST with their key instead, and
+// only reach their own watches. Snoozes take ?for=, see parseSnooze.
+func watchActionHandler(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get("token")
+		reply := func(status int, result watchLinkReply, body interface{}) {
+			if token == "" {
+				if status >= 300 {
+					writeJSONError(w, status, result.Message)
+				} else {
+					writeJSONResponse(w, body)
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(status)
+			watchLinkPage.Execute(w, result)
+		}
+
+		store := watches()
+		var found *watch
+		for _, existing := range store.all() {
+			if existing.ID == query.Get("id") {
+				found = &existing
+				break
+			}
+		}
+		if token != "" {
+			if found == nil || found.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(found.Token)) != 1 {
+				reply(http.StatusNotFound, watchLinkReply{Title: "Watch not found", Message: "This watch has already been removed, or the link is incomplete."}, nil)
+				return
+			}
+		} else {
+			authed, ok := authorized(r)
+			if !ok {
+				writeJSONError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			if found == nil || found.Owner != apiKeyName(authed.Context()) {
+				writeJSONError(w, http.StatusNotFound, "No watch with ID "+query.Get("id"))
+				return
+			}
+		}
+
+		length, ok := time.Duration(0), true
+		if action == "snooze" {
+			if length, ok = parseSnooze(query.Get("for")); !ok {
+				reply(http.StatusBadRequest, watchLinkReply{Title: "Can't snooze", Message: "for takes hours or days like 6h or 2d, up to " + strconv.Itoa(int(maxWatchSpan.Hours()/24)) + " days, or 0 to end the snooze"}, nil)
+				return
+			}
+		}
+		if r.Method == http.MethodGet && token != "" {
+			confirm := watchLinkReply{Title: "Stop this watch?", Message: "You won't hear about " + watchSummary(*found) + " any more.", Button: "Unsubscribe"}
+			switch {
+			case action == "snooze" && length > 0:
+				confirm = watchLinkReply{Title: "Snooze this watch?", Message: "Notifications about " + watchSummary(*found) + " pause for " + snoozeText(length) + ", the watch stays.", Button: "Snooze"}
+			case action == "snooze":
+				confirm = watchLinkReply{Title: "End the snooze?", Message: "Notifications about " + watchSummary(*found) + " start again.", Button: "Turn back on"}
+			}
+			reply(http.StatusOK, confirm, nil)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, "Use POST to "+action)
+			return
+		}
+
+		if action == "unsubscribe" {
+			store.remove(found.ID)
+			reply(http.StatusOK, watchLinkReply{Title: "Unsubscribed", Message: "You won't hear about " + watchSummary(*found) + " any more."}, map[string]string{"removed": found.ID})
+			return
+		}
+		var until *time.Time
+		result := watchLinkReply{Title: "Snooze ended", Message: "Notifications about " + watchSummary(*found) + " are back on."}
+		if length > 0 {
+			end := time.Now().UTC().Add(length)
+			until = &end
+			result = watchLinkReply{Title: "Snoozed", Message: "No notifications about " + watchSummary(*found) + " until " + end.In(venueLocation()).Format("Mon Jan 2, 3:04 PM") + "."}
+		}
+		snoozed, ok := store.update(found.ID, func(current *watch) { current.SnoozedUntil = until })
+		if !ok {
+			reply(http.StatusNotFound, watchLinkReply{Title: "Watch not found", Message: "This watch has already been removed."}, nil)
+			return
+		}
+		snoozed.Secret = ""
+		reply(http.StatusOK, result, snoozed)
+	}
+}
+
+// watchSummary names what a watch is waiting for, e.g. "Saturdays through Nov 14" or "Fri Jan 12"
+func watchSummary(w watch) string {
+	through, _ := time.Parse("2006-01-02", w.lastDate())
+	if len(w.Every) == 0 {
+		return through.Format("Mon Jan 2")
+	}
+	var days []string
+	for _, name := range w.Every {
+		if weekday, ok := parseWeekday(name); ok {
+			days = append(days, weekday.String()+"s")
+		}
+	}
+	return strings.Join(days, " and ") + " through " + through.Format("Jan 2")
+}
+
+// snoozeText is a snooze length for people, "2 days" or "6 hours"
+func snoozeText(length time.Duration) string {
+	if length >= 24*time.Hour && length%(24*time.Hour) == 0 {
+		return pluralize(int(length/(24*time.Hour)), "day")
+	}
+	if length >= time.Hour && length%time.Hour == 0 {
+		return pluralize(int(length/time.Hour), "hour")
+	}
+	return length.String()
+}
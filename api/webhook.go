@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// signatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">" on every webhook POST,
+// and on incoming signed requests (AUTH_MODE=hmac)
+const signatureHeader = "X-Signature"
+
+// signatureTolerance is how old a signed timestamp may be before receivers should reject it as a replay
+const signatureTolerance = 5 * time.Minute
+
+// webhookClient has its own short timeout, a slow receiver mustn't hold up the response
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// publicWebhookClient is for webhook URLs any API key can set, the watches. It only dials public
+// addresses, checked after the name is resolved so it can't be pointed at localhost, the network
+// it runs in or a cloud metadata service, and it never goes through a proxy.
+var publicWebhookClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local, shared (100.64/10) and
+// unspecified addresses
+func dialPublicOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errors.New("not dialing " + host + ", it isn't a public address")
+	}
+	return nil
+}
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// spotsOpenedEvent is the JSON body POSTed to WEBHOOK_URL when sessions open up
+type spotsOpenedEvent struct {
+	Event     string     `json:"event"`
+	Date      string     `json:"date"`
+	Slots     []timeSlot `json:"slots"`
+	Timestamp int64      `json:"timestamp"`
+}
+
+// lastSeenSlots is the last availability this instance saw per date, used to spot sessions opening up
+var lastSeenSlots = struct {
+	sync.Mutex
+	byDate map[string]map[string]int
+}{byDate: map[string]map[string]int{}}
+
+// notifySpotsOpened POSTs a spots_opened event to WEBHOOK_URL for slots that were sold out (or missing)
+// the last time this date was fetched and now have spots. The first fetch of a date never notifies.
+func notifySpotsOpened(date string, skateTimesMap map[string]map[string]int) {
+	url := os.Getenv("WEBHOOK_URL")
+	if url == "" {
+		return
+	}
+	keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+
+	lastSeenSlots.Lock()
+	previous, seen := lastSeenSlots.byDate[date]
+	lastSeenSlots.byDate[date] = cleanedMap
+	lastSeenSlots.Unlock()
+	if !seen {
+		return
+	}
+
+	event := spotsOpenedEvent{Event: "spots_opened", Date: date, Timestamp: time.Now().Unix()}
+	indoor := sessionTimes("INDOOR_SESSIONS")
+	for _, skateTime := range keys {
+		if previous[skateTime] == 0 {
+			timeObj, _ := time.Parse("1504", skateTime)
+			event.Slots = append(event.Slots, timeSlot{
+				Time:    timeObj.Format("15:04"),
+				Spots:   cleanedMap[skateTime],
+				Surface: slotSurface(skateTime, indoor),
+			})
+		}
+	}
+	if len(event.Slots) == 0 {
+		return
+	}
+	deliverWebhook(url, event, event.Timestamp)
+}
+
+// deliverWebhook POSTs the payload as JSON, signed with WEBHOOK_SECRET when one is configured
+func deliverWebhook(url string, payload interface{}, timestamp int64) {
+	if err := postWebhook(webhookClient, url, os.Getenv("WEBHOOK_SECRET"), payload, timestamp); err != nil {
+		slog.Warn("webhook delivery failed", "error", err)
+	}
+}
+
+// postWebhook POSTs the payload as JSON, with the signature header when there's a secret
+func postWebhook(client *http.Client, url string, secret string, payload interface{}, timestamp int64) error {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(signatureHeader, signWebhook(secret, timestamp, body))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New("receiver answered " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+// signWebhook builds the signature header value. The timestamp is part of the signed message so that
+// a captured request can't be replayed later with a fresh timestamp.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	return "t=" + t + ",v1=" + webhookMAC(secret, t, body)
+}
+
+func webhookMAC(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature is the receiving side of signWebhook: the MAC must match and the
+// timestamp must be within signatureTolerance of now
+func verifyWebhookSignature(secret string, header string, body []byte, now time.Time) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		switch {
+		case strings.HasPrefix(part, "t="):
+			timestamp = strings.TrimPrefix(part, "t=")
+		case strings.HasPrefix(part, "v1="):
+			signature = strings.TrimPrefix(part, "v1=")
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(webhookMAC(secret, timestamp, body)))
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records every POST it gets and answers with status
+type webhookReceiver struct {
+	*httptest.Server
+	sync.Mutex
+	status     int
+	signatures []string
+	bodies     [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{status: status}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		receiver.Lock()
+		receiver.signatures = append(receiver.signatures, r.Header.Get(signatureHeader))
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.Unlock()
+		w.WriteHeader(receiver.status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func TestPostWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		status  int
+		signed  bool
+		wantErr bool
+	}{
+		{"signed", "shh", http.StatusOK, true, false},
+		{"no secret", "", http.StatusNoContent, false, false},
+		{"receiver error", "shh", http.StatusInternalServerError, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, test.status)
+			now := time.Now()
+			err := postWebhook(receiver.Client(), receiver.URL, test.secret, spotsOpenedEvent{Event: "spots_opened", Date: "2024-01-02"}, now.Unix())
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			if len(receiver.bodies) != 1 {
+				t.Fatalf("receiver got %d requests", len(receiver.bodies))
+			}
+			signature, body := receiver.signatures[0], receiver.bodies[0]
+			if (signature != "") != test.signed {
+				t.Errorf("%s = %q, want signed %v", signatureHeader, signature, test.signed)
+			}
+			if test.signed && !verifyWebhookSignature(test.secret, signature, body, now) {
+				t.Errorf("%s = %q doesn't verify against %s", signatureHeader, signature, body)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"spots_opened"}`)
+	valid := signWebhook("shh", now.Unix(), body)
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		want   bool
+	}{
+		{"valid", "shh", valid, string(body), true},
+		{"wrong secret", "other", valid, string(body), false},
+		{"body changed", "shh", valid, `{"event":"spots_closed"}`, false},
+		{"replayed later", "shh", signWebhook("shh", now.Add(-6*time.Minute).Unix(), body), string(body), false},
+		{"from the future", "shh", signWebhook("shh", now.Add(6*time.Minute).Unix(), body), string(body), false},
+		{"within tolerance", "shh", signWebhook("shh", now.Add(-4*time.Minute).Unix(), body), string(body), true},
+		{"timestamp swapped", "shh", "t=1700000001," + strings.Split(valid, ",")[1], string(body), false},
+		{"no signature", "shh", "t=1700000000", string(body), false},
+		{"garbage", "shh", "nonsense", string(body), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := verifyWebhookSignature(test.secret, test.header, []byte(test.body), now); got != test.want {
+				t.Errorf("verifyWebhookSignature(%q) = %v, want %v", test.header, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.10", false},
+		{"172.16.5.4", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+	}
+	for _, test := range tests {
+		if got := publicIP(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("publicIP(%s) = %v, want %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestPublicWebhookClientRefusesLoopback(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	err := postWebhook(publicWebhookClient, receiver.URL, "shh", spotsOpenedEvent{Event: "spots_opened"}, time.Now().Unix())
+	if err == nil || !strings.Contains(err.Error(), "isn't a public address") {
+		t.Errorf("err = %v, want the loopback receiver refused", err)
+	}
+	if len(receiver.bodies) != 0 {
+		t.Errorf("the loopback receiver got %d requests", len(receiver.bodies))
+	}
+}
+
+func TestSpotsOpenedWebhook(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 0, "1600": 3}}`)
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	t.Setenv("WEBHOOK_URL", receiver.URL)
+	t.Setenv("WEBHOOK_SECRET", "shh")
+	lastSeenSlots.Lock()
+	lastSeenSlots.byDate = map[string]map[string]int{}
+	lastSeenSlots.Unlock()
+
+	get(t, "/api?date=2024-01-02")
+	if len(receiver.bodies) != 0 {
+		t.Fatalf("the first fetch of a date notified: %s", receiver.bodies[0])
+	}
+	stub.answer(http.StatusOK, `{"2024-01-02": {"1500": 2, "1600": 3}}`)
+	get(t, "/api?date=2024-01-02&fresh=1")
+	if len(receiver.bodies) != 1 {
+		t.Fatalf("receiver got %d requests, want one for 15:00 opening", len(receiver.bodies))
+	}
+
+	var event spotsOpenedEvent
+	if err := json.Unmarshal(receiver.bodies[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != "spots_opened" || event.Date != "2024-01-02" || len(event.Slots) != 1 || event.Slots[0].Time != "15:00" || event.Slots[0].Spots != 2 {
+		t.Errorf("event = %+v, want just 15:00 with 2 spots", event)
+	}
+	if !verifyWebhookSignature("shh", receiver.signatures[0], receiver.bodies[0], time.Now()) {
+		t.Errorf("%s = %q doesn't verify", signatureHeader, receiver.signatures[0])
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// weekDays is how many days /api/week summarizes, starting today
+const weekDays = 7
+
+// daySummary is one day of the /api/week glance: whether anything is open and how many spots are left in total
+type daySummary struct {
+	Date       string `json:"date"`
+	Weekday    string `json:"weekday"`
+	Open       bool   `json:"open"`
+	Closed     bool   `json:"closed,omitempty"`
+	Sessions   int    `json:"sessions"`
+	TotalSpots int    `json:"totalSpots"`
+}
+
+type weekSummary struct {
+	Days []daySummary `json:"days"`
+}
+
+func summarizeDay(day time.Time, skateTimesMap map[string]map[string]int, opts formatOptions) daySummary {
+	date := day.Format("2006-01-02")
+	keys, cleanedMap := xola.CleanSkateTimes(date, skateTimesMap)
+	keys = opts.filter(keys)
+	summary := daySummary{Date: date, Weekday: day.Format("Monday"), Closed: isClosedDay(opts.rink, day), Sessions: len(keys)}
+	for _, skateTime := range keys {
+		summary.TotalSpots += cleanedMap[skateTime]
+	}
+	summary.Open = summary.Sessions > 0
+	return summary
+}
+
+// weekHandler summarizes today and the following six days from a single range lookup
+func weekHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := endpointFormat(w, r, "text", "json", "yaml")
+	if !ok {
+		return
+	}
+	now := time.Now().In(venueLocation())
+	today, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	dates := rangeDates(today, today.AddDate(0, 0, weekDays-1))
+	skateTimesMap, _, err := queryOpenDays(r.Context(), dates)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	opts := formatOptionsFromRequest(r)
+
+	result := weekSummary{Days: make([]daySummary, 0, len(dates))}
+	for _, day := range dates {
+		result.Days = append(result.Days, summarizeDay(day, skateTimesMap, opts))
+	}
+
+	switch format {
+	case "json":
+		writeJSONResponse(w, result)
+	case "yaml":
+		writeYAMLResponse(w, result)
+	default:
+		var sb strings.Builder
+		for i, summary := range result.Days {
+			sb.WriteString(dates[i].Format("Mon Jan 2") + ": ")
+			switch {
+			case summary.Closed:
+				sb.WriteString("Closed\n")
+			case !summary.Open:
+				sb.WriteString("Sold out\n")
+			case isLimited(summary.TotalSpots):
+				sb.WriteString("limited spots across " + pluralize(summary.Sessions, "session") + "\n")
+			default:
+				sb.WriteString(strconv.Itoa(summary.TotalSpots) + " spots across " + pluralize(summary.Sessions, "session") + "\n")
+			}
+		}
+		writeSuccessResponse(w, &sb)
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// whatsappAPI is the Meta Graph API the Cloud API is part of
+var whatsappAPI = "https://graph.facebook.com/v19.0"
+
+// sendWhatsApp sends the alert through the WhatsApp Cloud API when WHATSAPP_TOKEN and
+// WHATSAPP_PHONE_NUMBER_ID are set, otherwise from TWILIO_WHATSAPP_FROM through Twilio
+func sendWhatsApp(ctx context.Context, to string, text string) error {
+	token, phoneID := os.Getenv("WHATSAPP_TOKEN"), os.Getenv("WHATSAPP_PHONE_NUMBER_ID")
+	if token == "" || phoneID == "" {
+		return sendTwilioMessage(ctx, whatsappAddress(os.Getenv("TWILIO_WHATSAPP_FROM")), whatsappAddress(to), text)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                strings.TrimPrefix(to, "+"),
+		"type":              "text",
+		"text":              map[string]string{"body": text},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, whatsappAPI+"/"+phoneID+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New("WhatsApp answered " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// whatsappAddress is a number as Twilio addresses it on WhatsApp, whatsapp:+15551234567
+func whatsappAddress(number string) string {
+	if number == "" || strings.HasPrefix(number, "whatsapp:") {
+		return number
+	}
+	return "whatsapp:" + number
+}
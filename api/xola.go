@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// The Xola client and decoding are in internal/xola. This wires them to the cache, the circuit
+// breaker and the budget, the handlers only see decoded { date: { time: count } } maps.
+
+// experienceURL is the Xola API URL of the request's rink (see rinks.go)
+func experienceURL(ctx context.Context) string {
+	return xola.ExperienceURL(rinkFromContext(ctx).experienceID)
+}
+
+func querySkateTimesAPI(ctx context.Context, date string) (map[string]map[string]int, map[string]map[string]bool, error) {
+	return querySkateTimesRange(ctx, date, date)
+}
+
+// querySkateTimesRange fetches every day from start to end (inclusive) in one Xola request. Any failure,
+// from the request itself to an undecodable body, comes back as the error for handlers to answer with
+// writeUpstreamError.
+func querySkateTimesRange(ctx context.Context, start string, end string) (map[string]map[string]int, map[string]map[string]bool, error) {
+	key := rinkFromContext(ctx).name + "/" + start + "/" + end
+	_, endCacheSpan := startSpan(ctx, "cache.lookup")
+	entry, cached := cachedAvailability(key)
+	endCacheSpan()
+	if cached && !freshDataRequested(ctx) {
+		if entry.fresh() {
+			incCounter("bpskate_cache_lookups_total", "result", "hit")
+			noteFetchedAt(ctx, entry.fetchedAt)
+			return entry.skateTimesMap, entry.waitlists, nil
+		}
+		// stale-while-revalidate: answer now with the stale copy, refresh for the next request. The
+		// refresh outlives the request, it only takes the rink from it.
+		if entry.revalidatable() {
+			if xolaBreaker.allow() {
+				refreshCtx := withRink(context.Background(), rinkFromContext(ctx))
+				goBackground(func() { refreshAvailability(refreshCtx, key, start, end) })
+			}
+			incCounter("bpskate_cache_lookups_total", "result", "stale")
+			noteFetchedAt(ctx, entry.fetchedAt)
+			return entry.skateTimesMap, entry.waitlists, nil
+		}
+	}
+	if !xolaBreaker.allow() {
+		// Xola has been failing, don't add to its load. Serve what we last saw if we have it.
+		if cached {
+			slog.WarnContext(ctx, "circuit open, serving last known availability", "range", key)
+			incCounter("bpskate_cache_lookups_total", "result", "fallback")
+			noteFetchedAt(ctx, entry.fetchedAt)
+			return entry.skateTimesMap, entry.waitlists, nil
+		}
+		return map[string]map[string]int{}, map[string]map[string]bool{}, errCircuitOpen
+	}
+
+	incCounter("bpskate_cache_lookups_total", "result", "miss")
+	skateTimesMap, waitlists, err := refreshAvailability(ctx, key, start, end)
+	if err == nil {
+		noteFetchedAt(ctx, time.Now())
+	}
+	return skateTimesMap, waitlists, err
+}
+
+// refreshAvailability asks Xola for the range (sharing the request with any identical one in
+// flight) and stores the result in the cache
+func refreshAvailability(ctx context.Context, key string, start string, end string) (map[string]map[string]int, map[string]map[string]bool, error) {
+	return coalesce(ctx, key, func(ctx context.Context) (map[string]map[string]int, map[string]map[string]bool, error) {
+		fetchCtx, endFetchSpan := startSpan(ctx, "xola.fetch")
+		skateTimesMap, waitlists, err := fetchSkateTimes(fetchCtx, start, end)
+		endFetchSpan()
+		xolaBreaker.record(err)
+		if err != nil {
+			return skateTimesMap, waitlists, err
+		}
+		storeAvailability(key, skateTimesMap, waitlists)
+		observeSessions(rinkFromContext(ctx).name, skateTimesMap)
+		changes := map[string][]availabilityChange{}
+		for date := range skateTimesMap {
+			changes[date] = recordChanges(rinkFromContext(ctx), date, skateTimesMap)
+		}
+		checkWatches(rinkFromContext(ctx), start, end, skateTimesMap)
+		for date := range skateTimesMap {
+			pushOpenings(rinkFromContext(ctx), date, changes[date])
+		}
+		// history, webhooks, alerts and the calendar sync only follow the default rink
+		if rinkFromContext(ctx).name != defaultRink {
+			return skateTimesMap, waitlists, nil
+		}
+		for date := range skateTimesMap {
+			alertOpenings(rinkFromContext(ctx), date, changes[date])
+			notifyHooks(rinkFromContext(ctx), date, changes[date])
+			recordSnapshot(date, skateTimesMap)
+			notifySpotsOpened(date, skateTimesMap)
+			syncGoogleCalendar(rinkFromContext(ctx), date, skateTimesMap)
+		}
+		return skateTimesMap, waitlists, nil
+	})
+}
+
+// fetchSkateTimes makes the actual availability request and decodes it
+func fetchSkateTimes(ctx context.Context, start string, end string) (map[string]map[string]int, map[string]map[string]bool, error) {
+	empty := map[string]map[string]int{}
+	// Query BP API for times
+	requestStart := time.Now()
+	res, err := getWithRetry(ctx, xola.AvailabilityURL(experienceURL(ctx), start, end))
+	if err != nil {
+		slog.ErrorContext(ctx, "Xola request failed", "start", start, "end", end, "duration_ms", time.Since(requestStart).Milliseconds(), "error", err)
+		return empty, map[string]map[string]bool{}, err
+	}
+
+	// read all response body into string and close stream
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		slog.ErrorContext(ctx, "could not read Xola response", "error", err)
+		return empty, map[string]map[string]bool{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "Xola returned an error", "start", start, "end", end, "status", res.StatusCode, "duration_ms", time.Since(requestStart).Milliseconds())
+		return empty, map[string]map[string]bool{}, errors.New("Xola returned status " + strconv.Itoa(res.StatusCode))
+	}
+	slog.InfoContext(ctx, "Xola request", "start", start, "end", end, "status", res.StatusCode, "duration_ms", time.Since(requestStart).Milliseconds())
+
+	// unpack response into { date: { time: count } } map
+	skateTimesMap, waitlists, err := xola.Decode(data)
+	if err != nil {
+		slog.ErrorContext(ctx, "bad availability response from Xola", "error", err)
+	}
+	return skateTimesMap, waitlists, err
+}
+
+// writeUpstreamError answers a failed Xola lookup: 503 while the circuit breaker is open, 504 when
+// Xola timed out, 502 for anything else
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errCircuitOpen) {
+		incCounter("bpskate_errors_total", "kind", "circuit_open")
+		w.Header().Set("Retry-After", strconv.Itoa(int(circuitCooldown().Seconds())))
+		writeJSONError(w, http.StatusServiceUnavailable, "Xola is unavailable, try again shortly")
+		return
+	}
+	if errors.Is(err, errXolaBudget) {
+		incCounter("bpskate_errors_total", "kind", "rate_limited")
+		w.Header().Set("Retry-After", "60")
+		writeJSONError(w, http.StatusServiceUnavailable, "Too many requests to Xola right now, try again shortly")
+		return
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		incCounter("bpskate_errors_total", "kind", "timeout")
+		writeJSONError(w, http.StatusGatewayTimeout, "Timed out waiting for Xola")
+		return
+	}
+	incCounter("bpskate_errors_total", "kind", "upstream")
+	writeJSONError(w, http.StatusBadGateway, "Could not get availability from Xola")
+}
+
+// defaultXolaTimeoutMs bounds a single Xola request when XOLA_TIMEOUT_MS is unset
+const defaultXolaTimeoutMs = 5000
+
+// getWithTimeout GETs the url, giving up when ctx is canceled or after XOLA_TIMEOUT_MS. The
+// deadline covers reading the body too, it's released when the body is closed.
+func getWithTimeout(ctx context.Context, url string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, envMilliseconds("XOLA_TIMEOUT_MS", defaultXolaTimeoutMs))
+	if err := waitForXolaBudget(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if id := requestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	start, endSpan := time.Now(), startClientSpan(req)
+	res, err := xola.Client.Do(req)
+	endSpan(res, err)
+	observeXolaCall(start, res, err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelOnClose releases the request's context once the body is done with
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// retry policy defaults, see RETRY_ATTEMPTS, RETRY_BACKOFF_MS and RETRY_DEADLINE_MS
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoffMs  = 200
+	defaultRetryDeadlineMs = 8000
+)
+
+// retryPolicy reads RETRY_ATTEMPTS, RETRY_BACKOFF_MS, RETRY_DEADLINE_MS and RETRY_JITTER (on
+// unless it's 0). Running into the Xola budget isn't retried, that would only spend more of it.
+func retryPolicy() xola.RetryPolicy {
+	return xola.RetryPolicy{
+		Attempts: envInt("RETRY_ATTEMPTS", defaultRetryAttempts),
+		Backoff:  envMilliseconds("RETRY_BACKOFF_MS", defaultRetryBackoffMs),
+		Deadline: envMilliseconds("RETRY_DEADLINE_MS", defaultRetryDeadlineMs),
+		Jitter:   os.Getenv("RETRY_JITTER") != "0",
+		GiveUp:   func(err error) bool { return errors.Is(err, errXolaBudget) },
+	}
+}
+
+// getWithRetry GETs the url with the retry policy, see xola.GetWithRetry
+func getWithRetry(ctx context.Context, url string) (*http.Response, error) {
+	return xola.GetWithRetry(ctx, url, retryPolicy(), getWithTimeout)
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// xolaStub is a fake Xola that answers every availability request with payload and the experience
+// itself (where the price is) with experience, counting both
+type xolaStub struct {
+	*httptest.Server
+	sync.Mutex
+	payload     string
+	status      int
+	experience  string
+	requests    []*http.Request
+	experiences int
+}
+
+// newXolaStub points XOLA_BASE_URL at a fresh stub and starts from an empty cache, a closed
+// breaker and none of the optional features turned on
+func newXolaStub(t *testing.T, payload string) *xolaStub {
+	t.Helper()
+	resetAvailability(t)
+	stub := &xolaStub{payload: payload, status: http.StatusOK, experience: `{}`}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/experiences/") {
+			http.NotFound(w, r)
+			return
+		}
+		stub.Lock()
+		payload, status := stub.payload, stub.status
+		if strings.HasSuffix(r.URL.Path, "/availability") {
+			stub.requests = append(stub.requests, r)
+		} else {
+			stub.experiences++
+			payload, status = stub.experience, http.StatusOK
+		}
+		stub.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(payload))
+	}))
+	t.Cleanup(stub.Close)
+	t.Setenv("XOLA_BASE_URL", stub.URL)
+	return stub
+}
+
+// answer changes what the stub returns from the next request on
+func (stub *xolaStub) answer(status int, payload string) {
+	stub.Lock()
+	defer stub.Unlock()
+	stub.status, stub.payload = status, payload
+}
+
+// price sets the experience payload the price is read from
+func (stub *xolaStub) price(experience string) {
+	stub.Lock()
+	defer stub.Unlock()
+	stub.experience = experience
+}
+
+// experienceCalls counts the requests for the experience itself
+func (stub *xolaStub) experienceCalls() int {
+	stub.Lock()
+	defer stub.Unlock()
+	return stub.experiences
+}
+
+// calls counts the availability requests
+func (stub *xolaStub) calls() int {
+	stub.Lock()
+	defer stub.Unlock()
+	return len(stub.requests)
+}
+
+// resetAvailability clears what earlier tests left in the package state and the environment
+func resetAvailability(t *testing.T) {
+	t.Helper()
+	for _, setting := range settings {
+		t.Setenv(setting.key, "")
+	}
+	t.Setenv("RETRY_ATTEMPTS", "1")
+	t.Setenv("XOLA_EXPERIENCE_ID", "test-experience")
+	localCache.Lock()
+	localCache.byRange = map[string]cacheEntry{}
+	localCache.Unlock()
+	xolaBreaker.Lock()
+	xolaBreaker.failures, xolaBreaker.open, xolaBreaker.probing = 0, false, false
+	xolaBreaker.Unlock()
+	changeLog.Lock()
+	changeLog.lastSeen, changeLog.changes = map[string]map[string]int{}, nil
+	changeLog.Unlock()
+	experiencePrices.Lock()
+	experiencePrices.byRink = map[string]cachedPrice{}
+	experiencePrices.Unlock()
+	// a fresh outbound budget, otherwise a long run of tests ends up waiting on it
+	xolaBucket.Lock()
+	xolaBucket.tokenBucket = tokenBucket{}
+	xolaBucket.Unlock()
+	keyBuckets.Lock()
+	keyBuckets.byKey = map[string]*tokenBucket{}
+	keyBuckets.Unlock()
+}
+
+// get runs a request through Handler, the way Vercel calls it
+func get(t *testing.T, target string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return send(t, http.MethodGet, target, "", header...)
+}
+
+// send is get for any method, with a body
+func send(t *testing.T, method string, target string, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	Handler(w, r)
+	return w
+}
+
+func TestQuerySkateTimesCaches(t *testing.T) {
+	stub := newXolaStub(t, `{"2024-01-02": {"1500": 4}}`)
+	ctx := withRink(context.Background(), configuredRinks()[defaultRink])
+	for i := 0; i < 3; i++ {
+		counts, _, err := querySkateTimesAPI(ctx, "2024-01-02")
+		if err != nil || counts["2024-01-02"]["1500"] != 4 {
+			t.Fatalf("querySkateTimesAPI = %v, %v", counts, err)
+		}
+	}
+	if stub.calls() != 1 {
+		t.Errorf("Xola was asked %d times, want 1", stub.calls())
+	}
+	request := stub.requests[0]
+	if !strings.Contains(request.URL.Path, "/test-experience/availability") || request.URL.Query().Get("start") != "2024-01-02" {
+		t.Errorf("asked Xola for %s", request.URL)
+	}
+}
+
+func TestQuerySkateTimesUpstreamError(t *testing.T) {
+	stub := newXolaStub(t, `oops`)
+	stub.answer(http.StatusInternalServerError, `oops`)
+	w := get(t, "/api?date=2024-01-02")
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d (%s), want 502", w.Code, body)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	resetAvailability(t)
+	t.Setenv("RETRY_ATTEMPTS", "5")
+	t.Setenv("RETRY_BACKOFF_MS", "100")
+	t.Setenv("RETRY_DEADLINE_MS", "2000")
+	t.Setenv("RETRY_JITTER", "0")
+	policy := retryPolicy()
+	if policy.Attempts != 5 || policy.Backoff != 100*time.Millisecond || policy.Deadline != 2*time.Second || policy.Jitter {
+		t.Errorf("retryPolicy() = %+v", policy)
+	}
+	if !policy.GiveUp(errXolaBudget) || policy.GiveUp(context.DeadlineExceeded) {
+		t.Errorf("only the Xola budget should stop retries")
+	}
+}
+
+func TestGetWithRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts string
+		calls    int
+		wantErr  bool
+	}{
+		{"success isn't retried", http.StatusOK, "3", 1, false},
+		{"5xx is retried", http.StatusBadGateway, "3", 3, true},
+		{"4xx isn't retried", http.StatusNotFound, "3", 1, true},
+		{"one attempt", http.StatusBadGateway, "1", 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newXolaStub(t, `{}`)
+			stub.answer(test.status, `{}`)
+			t.Setenv("RETRY_ATTEMPTS", test.attempts)
+			t.Setenv("RETRY_BACKOFF_MS", "1")
+			w := get(t, "/api?date=2024-01-02")
+			if stub.calls() != test.calls {
+				t.Errorf("Xola was asked %d times, want %d", stub.calls(), test.calls)
+			}
+			if (w.Code != http.StatusOK) != test.wantErr {
+				t.Errorf("status = %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	stub := newXolaStub(t, `{}`)
+	stub.answer(http.StatusBadGateway, `{}`)
+	t.Setenv("RETRY_ATTEMPTS", "5")
+	t.Setenv("RETRY_BACKOFF_MS", "1000")
+	t.Setenv("RETRY_JITTER", "0")
+	t.Setenv("RETRY_DEADLINE_MS", "500")
+	start := time.Now()
+	get(t, "/api?date=2024-01-02")
+	if stub.calls() != 1 || time.Since(start) >= time.Second {
+		t.Errorf("%d calls in %v, want one and no 1s wait for a retry that can't finish in time", stub.calls(), time.Since(start))
+	}
+}
+
+func TestXolaProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		auth     string
+	}{
+		{"without credentials", "", "", ""},
+		{"with credentials", "skate", "s3cret", "Basic c2thdGU6czNjcmV0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetAvailability(t)
+			var mu sync.Mutex
+			var proxied []string
+			var auth string
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				proxied = append(proxied, r.URL.String())
+				auth = r.Header.Get("Proxy-Authorization")
+				mu.Unlock()
+				w.Write([]byte(`{"2024-01-02": {"1500": 4}}`))
+			}))
+			defer proxy.Close()
+			// not a real host, only the proxy can answer for it
+			t.Setenv("XOLA_BASE_URL", "http://xola.invalid")
+			t.Setenv("XOLA_PROXY", proxy.URL)
+			t.Setenv("XOLA_PROXY_USER", test.user)
+			t.Setenv("XOLA_PROXY_PASSWORD", test.password)
+
+			w := get(t, "/api?date=2024-01-02")
+			if w.Body.String() != "For Jan 2, 2024:\n3:00 PM has 4 spots\n" {
+				t.Errorf("body = %q", w.Body.String())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(proxied) != 1 || !strings.HasPrefix(proxied[0], "http://xola.invalid/api/experiences/test-experience/availability?") {
+				t.Errorf("proxy saw %v", proxied)
+			}
+			if auth != test.auth {
+				t.Errorf("Proxy-Authorization = %q, want %q", auth, test.auth)
+			}
+		})
+	}
+}
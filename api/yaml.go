@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func writeYAMLResponse(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshalYAML(body))
+}
+
+// marshalYAML is a small block-style YAML encoder for our response structs. It uses the json
+// tags (including omitempty) so YAML and JSON output always have the same field names.
+func marshalYAML(v interface{}) []byte {
+	var sb strings.Builder
+	writeYAMLValue(&sb, reflect.ValueOf(v), 0)
+	return []byte(sb.String())
+}
+
+func writeYAMLValue(sb *strings.Builder, v reflect.Value, indent int) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			sb.WriteString("null\n")
+			return
+		}
+		v = v.Elem()
+	}
+
+	pad := strings.Repeat("  ", indent)
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := yamlFields(v)
+		if len(fields) == 0 {
+			sb.WriteString("{}\n")
+			return
+		}
+		for i, field := range fields {
+			if i > 0 {
+				sb.WriteString(pad)
+			}
+			writeYAMLEntry(sb, field.name, field.value, indent)
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			sb.WriteString("{}\n")
+			return
+		}
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = key.String()
+		}
+		sortStringsWithValues(names, keys)
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteString(pad)
+			}
+			writeYAMLEntry(sb, names[i], v.MapIndex(key), indent)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			sb.WriteString("[]\n")
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				sb.WriteString(pad)
+			}
+			sb.WriteString("- ")
+			writeYAMLValue(sb, v.Index(i), indent+1)
+		}
+	default:
+		sb.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLEntry writes "key: value", putting nested collections on the following lines
+func writeYAMLEntry(sb *strings.Builder, key string, value reflect.Value, indent int) {
+	sb.WriteString(yamlKey(key) + ":")
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			break
+		}
+		value = value.Elem()
+	}
+	nested := (value.Kind() == reflect.Struct && len(yamlFields(value)) > 0) ||
+		((value.Kind() == reflect.Map || value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Len() > 0)
+	if nested {
+		sb.WriteString("\n" + strings.Repeat("  ", indent+1))
+		writeYAMLValue(sb, value, indent+1)
+		return
+	}
+	sb.WriteString(" ")
+	writeYAMLValue(sb, value, indent+1)
+}
+
+type yamlField struct {
+	name  string
+	value reflect.Value
+}
+
+// yamlFields lists exported struct fields named and filtered by their json tags
+func yamlFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		omitEmpty := false
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, option := range parts[1:] {
+				omitEmpty = omitEmpty || option == "omitempty"
+			}
+		}
+		if omitEmpty && v.Field(i).IsZero() {
+			continue
+		}
+		fields = append(fields, yamlField{name: name, value: v.Field(i)})
+	}
+	return fields
+}
+
+func yamlKey(key string) string {
+	if key == "" || strings.ContainsAny(key, ":#{}[],&*!|>'\"%@`") || strings.TrimSpace(key) != key {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func yamlScalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		// double-quoted YAML strings use the same escapes as Go
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return "null"
+}
+
+// sortStringsWithValues sorts map keys by name so output is deterministic
+func sortStringsWithValues(names []string, keys []reflect.Value) {
+	for i := 1; i < len(names); i++ {
+		for j := i; j > 0 && names[j] < names[j-1]; j-- {
+			names[j], names[j-1] = names[j-1], names[j]
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"scalars", map[string]interface{}{"b": true, "n": 3, "s": "x: y", "empty": "", "number": "123", "none": nil},
+			"b: true\nempty: \"\"\nn: 3\nnone: null\nnumber: \"123\"\ns: \"x: y\"\n"},
+		{"lists", map[string]interface{}{"none": []string{}, "some": []int{1, 2}}, "none: []\nsome:\n  - 1\n  - 2\n"},
+		{"omitempty like JSON", availability{Date: "2024-01-02", Slots: []timeSlot{}}, "date: \"2024-01-02\"\nslots: []\n"},
+		{"nested structs", availability{Date: "2024-01-02", Slots: []timeSlot{{Time: "15:00", Spots: 4, Surface: "outdoor"}}},
+			"date: \"2024-01-02\"\nslots:\n  - time: \"15:00\"\n    spots: 4\n    surface: \"outdoor\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := marshalYAML(test.value)
+			if string(got) != test.want {
+				t.Errorf("marshalYAML() = %q, want %q", got, test.want)
+			}
+			var document interface{}
+			if err := yaml.Unmarshal(got, &document); err != nil {
+				t.Errorf("marshalYAML() = %q isn't valid YAML: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestYAMLMatchesJSON(t *testing.T) {
+	want := availability{Rink: "bp", Date: "2024-01-02", Slots: []timeSlot{
+		{Time: "15:00", Spots: 4, Surface: "outdoor"},
+		{Time: "17:30", Spots: 2, Accessible: true, Surface: "outdoor"},
+		{Time: "19:00", Spots: 0, Surface: "outdoor", WaitlistAvailable: true},
+	}}
+	tests := []struct {
+		name   string
+		target string
+		header []string
+	}{
+		{"format param", "/api?date=2024-01-02&includeSoldOut=1&format=yaml", nil},
+		{"Accept header", "/api?date=2024-01-02&includeSoldOut=1", []string{"Accept", "application/x-yaml"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newXolaStub(t, `{"2024-01-02": {"1500": 4, "1730": 2, "1900": {"available": 0, "waitlist": true}}}`)
+			t.Setenv("ACCESSIBLE_SESSIONS", "1730")
+			t.Setenv("ENABLED_FORMATS", "json,yaml")
+			w := get(t, test.target, test.header...)
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/x-yaml" {
+				t.Fatalf("Content-Type = %q", contentType)
+			}
+
+			// a real YAML parser, then through JSON so the struct's json tags apply
+			var document interface{}
+			if err := yaml.Unmarshal(w.Body.Bytes(), &document); err != nil {
+				t.Fatalf("invalid YAML %q: %v", w.Body.String(), err)
+			}
+			data, err := json.Marshal(document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromYAML availability
+			if err := json.Unmarshal(data, &fromYAML); err != nil {
+				t.Fatal(err)
+			}
+			var fromJSON availability
+			if err := json.Unmarshal(get(t, "/api?date=2024-01-02&includeSoldOut=1&format=json").Body.Bytes(), &fromJSON); err != nil {
+				t.Fatal(err)
+			}
+			for _, got := range []struct {
+				source string
+				value  availability
+			}{{"yaml", fromYAML}, {"json", fromJSON}} {
+				if !reflect.DeepEqual(got.value, want) {
+					t.Errorf("%s = %+v, want %+v", got.source, got.value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Command server runs the API as a long-running HTTP server instead of a Vercel function, which is
+// what the background refresher (REFRESH_INTERVAL_SECONDS) needs
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	handler "github.com/andrewwong97/bp-skate/api"
+	"github.com/andrewwong97/bp-skate/internal/xola"
+)
+
+// defaults for BIND_ADDR, PORT and SHUTDOWN_TIMEOUT_SECONDS
+const (
+	defaultBindAddr        = "localhost"
+	defaultPort            = "8080"
+	defaultShutdownTimeout = 10
+)
+
+func main() {
+	// a mux of our own, so nothing that registers on http.DefaultServeMux (net/http/pprof does)
+	// is served around the handler's auth
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler.Handler)
+
+	// SIGTERM (what containers get) or ^C starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	grpcDone := make(chan struct{})
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		go func() {
+			defer close(grpcDone)
+			addr := net.JoinHostPort(bindAddr(), port)
+			slog.Info("serving gRPC", "addr", addr)
+			if err := handler.ServeGRPC(ctx, addr); err != nil {
+				slog.Error("gRPC server stopped", "error", err)
+				os.Exit(1)
+			}
+		}()
+	} else {
+		close(grpcDone)
+	}
+
+	// keeps the /skate command's rink choices in step with RINKS
+	if err := handler.RegisterDiscordCommand(ctx); err != nil {
+		slog.Warn("could not register the Discord command", "error", err)
+	}
+
+	server := &http.Server{Addr: listenAddr(), Handler: mux}
+	go func() {
+		slog.Info("listening", "addr", server.Addr, "xola", xola.BaseURL())
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(server, grpcDone)
+}
+
+// shutdown stops taking connections, waits for requests in flight and then for the handler's
+// background refreshes, all within SHUTDOWN_TIMEOUT_SECONDS. A second signal kills the process
+// the usual way, stop has already restored the default handling.
+func shutdown(server *http.Server, grpcDone chan struct{}) {
+	timeout := time.Duration(shutdownTimeout()) * time.Second
+	slog.Info("shutting down", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("requests still running at the shutdown deadline", "error", err)
+	}
+	select {
+	case <-grpcDone:
+	case <-ctx.Done():
+		slog.Warn("gRPC calls still running at the shutdown deadline")
+	}
+	if err := handler.Shutdown(ctx); err != nil {
+		slog.Warn("background refreshes still running at the shutdown deadline", "error", err)
+	}
+	// nothing will use the kept-alive Xola connections again
+	xola.Client.CloseIdleConnections()
+	slog.Info("stopped")
+}
+
+// shutdownTimeout is SHUTDOWN_TIMEOUT_SECONDS, how long a graceful shutdown may take
+func shutdownTimeout() int {
+	if seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS")); err == nil && seconds >= 0 {
+		return seconds
+	}
+	return defaultShutdownTimeout
+}
+
+// listenAddr is BIND_ADDR:PORT. The handler package has already applied CONFIG_FILE by the time
+// main runs, so both can come from there too. Use BIND_ADDR=0.0.0.0 in a container or on a LAN.
+func listenAddr() string {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(bindAddr(), port)
+}
+
+// bindAddr is BIND_ADDR, shared by the HTTP and gRPC listeners
+func bindAddr() string {
+	if host := os.Getenv("BIND_ADDR"); host != "" {
+		return host
+	}
+	return defaultBindAddr
+}
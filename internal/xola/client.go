@@ -0,0 +1,157 @@
+// Package xola talks to Xola's public availability API and decodes what it sends back into
+// { date: { time: count } } maps, sorted and left-padded the same way for every entrypoint. The
+// Vercel functions in api/ and cmd/server both go through it, so a fix to the fetch, the parsing
+// or the session order lands in one place. Rendering for a request (formats, filters, headers)
+// stays with the handlers.
+package xola
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Xola defaults, the Bryant Park free skating experience on production Xola. XOLA_BASE_URL and
+// XOLA_EXPERIENCE_ID point the service at another experience or a staging/mock Xola.
+const (
+	DefaultBaseURL      = "https://xola.com"
+	DefaultExperienceID = "61536b244f19be5b3c6e4241"
+)
+
+// BaseURL is XOLA_BASE_URL without a trailing slash, production Xola when unset
+func BaseURL() string {
+	if baseURL := os.Getenv("XOLA_BASE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return DefaultBaseURL
+}
+
+// ExperienceID is XOLA_EXPERIENCE_ID, Bryant Park when unset
+func ExperienceID() string {
+	if id := os.Getenv("XOLA_EXPERIENCE_ID"); id != "" {
+		return id
+	}
+	return DefaultExperienceID
+}
+
+// ExperienceURL is the Xola API URL of an experience, where its price is
+func ExperienceURL(experienceID string) string {
+	return BaseURL() + "/api/experiences/" + experienceID
+}
+
+// AvailabilityURL is the public availability endpoint of an experience for start..end
+func AvailabilityURL(experienceURL string, start string, end string) string {
+	return experienceURL + "/availability?start=" + start + "&end=" + end + "&privacy=public"
+}
+
+// Client is used for every outbound request to Xola. It lives for the life of the instance so
+// warm invocations reuse the kept-alive connection instead of paying for DNS and TLS again.
+// Per-request deadlines are the caller's, the client Timeout is only a backstop.
+var Client = &http.Client{Transport: newTransport(), Timeout: 30 * time.Second}
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.DialContext = (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	transport.ResponseHeaderTimeout = 10 * time.Second
+	// everything goes to the one Xola host, so let it keep as many idle connections as the pool
+	transport.MaxIdleConns = 20
+	transport.MaxIdleConnsPerHost = 20
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// proxy routes requests through XOLA_PROXY when set (with optional XOLA_PROXY_USER /
+// XOLA_PROXY_PASSWORD), otherwise it honors the standard HTTP_PROXY / HTTPS_PROXY / NO_PROXY env
+func proxy(req *http.Request) (*url.URL, error) {
+	configured := os.Getenv("XOLA_PROXY")
+	if configured == "" {
+		return http.ProxyFromEnvironment(req)
+	}
+	proxyURL, err := url.Parse(configured)
+	if err != nil {
+		return nil, err
+	}
+	if user := os.Getenv("XOLA_PROXY_USER"); user != "" {
+		proxyURL.User = url.UserPassword(user, os.Getenv("XOLA_PROXY_PASSWORD"))
+	}
+	return proxyURL, nil
+}
+
+// RetryPolicy is how GetWithRetry retries. Attempts counts the first try, Backoff is the window
+// before the first retry (doubling after that) and no retry starts past Deadline. GiveUp, when
+// set, marks errors retrying can't help with.
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+	Deadline time.Duration
+	Jitter   bool
+	GiveUp   func(error) bool
+}
+
+// Delay is the backoff window before the given retry. With jitter the delay is picked uniformly
+// from [0, window) so retries from many requests don't all land on Xola at the same moment when
+// it recovers.
+func (policy RetryPolicy) Delay(attempt int) time.Duration {
+	window := policy.Backoff << uint(attempt-1)
+	if !policy.Jitter || window <= 0 {
+		return window
+	}
+	return time.Duration(rand.Int63n(int64(window)))
+}
+
+// GetWithRetry GETs the url with get, retrying transport errors and 5xx responses with exponential
+// backoff. Retries stop once the next one couldn't start before the deadline, so a flaky Xola still
+// gets an answer (the last error) back to the client in bounded time.
+func GetWithRetry(ctx context.Context, url string, policy RetryPolicy, get func(context.Context, string) (*http.Response, error)) (*http.Response, error) {
+	attempts := policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	deadline := time.Now().Add(policy.Deadline)
+
+	var res *http.Response
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay := policy.Delay(attempt)
+			if time.Now().Add(delay).After(deadline) {
+				break
+			}
+			// the previous 5xx is only handed back if this was the last try. Drain it so the
+			// connection goes back into the pool.
+			if res != nil {
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				// the client went away, no point in trying again
+				return nil, ctx.Err()
+			}
+		}
+		res, err = get(ctx, url)
+		if err == nil && res.StatusCode < 500 {
+			return res, nil
+		}
+		if err != nil && policy.GiveUp != nil && policy.GiveUp(err) {
+			return nil, err
+		}
+		if err == nil {
+			slog.WarnContext(ctx, "Xola returned an error", "status", res.StatusCode, "attempt", attempt+1)
+			continue
+		}
+		slog.WarnContext(ctx, "Xola request failed", "attempt", attempt+1, "error", err)
+	}
+	return res, err
+}
@@ -0,0 +1,98 @@
+package xola
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		jitter  bool
+		attempt int
+		window  time.Duration
+	}{
+		{"first retry", true, 1, 100 * time.Millisecond},
+		{"second retry doubles", true, 2, 200 * time.Millisecond},
+		{"third retry", true, 3, 400 * time.Millisecond},
+		{"jitter off", false, 2, 200 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: test.jitter}
+			seen := map[time.Duration]bool{}
+			for i := 0; i < 50; i++ {
+				delay := policy.Delay(test.attempt)
+				if !test.jitter && delay != test.window {
+					t.Fatalf("Delay(%d) = %v, want exactly %v without jitter", test.attempt, delay, test.window)
+				}
+				if delay < 0 || delay > test.window || (test.jitter && delay == test.window) {
+					t.Fatalf("Delay(%d) = %v, want [0, %v)", test.attempt, delay, test.window)
+				}
+				seen[delay] = true
+			}
+			if test.jitter && len(seen) < 2 {
+				t.Errorf("50 jittered delays were all %v", seen)
+			}
+		})
+	}
+}
+
+func TestGetWithRetry(t *testing.T) {
+	errGiveUp := errors.New("give up")
+	tests := []struct {
+		name     string
+		statuses []int
+		err      error
+		want     int
+		tries    int
+	}{
+		{"ok", []int{200}, nil, 200, 1},
+		{"5xx then ok", []int{502, 200}, nil, 200, 2},
+		{"4xx isn't retried", []int{404}, nil, 404, 1},
+		{"out of attempts", []int{500, 500, 500, 200}, nil, 500, 3},
+		{"give up", nil, errGiveUp, 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tries := 0
+			get := func(ctx context.Context, url string) (*http.Response, error) {
+				tries++
+				if test.err != nil {
+					return nil, test.err
+				}
+				w := httptest.NewRecorder()
+				w.WriteHeader(test.statuses[tries-1])
+				return w.Result(), nil
+			}
+			policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, Deadline: time.Second,
+				GiveUp: func(err error) bool { return errors.Is(err, errGiveUp) }}
+			res, err := GetWithRetry(context.Background(), "http://xola.test", policy, get)
+			if tries != test.tries {
+				t.Errorf("tried %d times, want %d", tries, test.tries)
+			}
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("err = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil || res.StatusCode != test.want {
+				t.Errorf("GetWithRetry = %v, %v, want %d", res, err, test.want)
+			}
+		})
+	}
+}
+
+func TestAvailabilityURL(t *testing.T) {
+	t.Setenv("XOLA_BASE_URL", "http://localhost:8080/")
+	got := AvailabilityURL(ExperienceURL("abc"), "2024-01-02", "2024-01-03")
+	want := "http://localhost:8080/api/experiences/abc/availability?start=2024-01-02&end=2024-01-03&privacy=public"
+	if got != want {
+		t.Errorf("AvailabilityURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package xola
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// availability is the availability payload, { "2024-01-02": { "1500": slot } }
+type availability map[string]map[string]slot
+
+// slot is one session in the availability payload. Xola normally sends a bare remaining count,
+// but for experiences with a waitlist the slot comes back as an object carrying the waitlist flag.
+type slot struct {
+	Remaining int
+	Waitlist  bool
+}
+
+// slotDetail is the object form of a slot
+type slotDetail struct {
+	Available *int `json:"available"`
+	Count     *int `json:"count"`
+	Waitlist  bool `json:"waitlist"`
+}
+
+func (s *slot) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Remaining); err == nil {
+		return nil
+	}
+	var detail slotDetail
+	if err := json.Unmarshal(data, &detail); err != nil {
+		return errors.New("slot is neither a count nor an object: " + string(data))
+	}
+	switch {
+	case detail.Available != nil:
+		s.Remaining = *detail.Available
+	case detail.Count != nil:
+		s.Remaining = *detail.Count
+	default:
+		return errors.New("slot has no count: " + string(data))
+	}
+	s.Waitlist = detail.Waitlist
+	return nil
+}
+
+// validate checks the decoded payload is keyed by real dates and session times with sane counts
+func (payload availability) validate() error {
+	for date, slots := range payload {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("unexpected date key " + strconv.Quote(date))
+		}
+		for skateTime, s := range slots {
+			if _, ok := NormalizeSlotTime(skateTime); !ok {
+				return errors.New("unexpected time key " + strconv.Quote(skateTime) + " on " + date)
+			}
+			if s.Remaining < 0 {
+				return errors.New("negative count for " + skateTime + " on " + date)
+			}
+		}
+	}
+	return nil
+}
+
+// Decode decodes and validates the payload into counts and the slots (HHMM) with an open waitlist
+func Decode(data []byte) (map[string]map[string]int, map[string]map[string]bool, error) {
+	skateTimesMap := map[string]map[string]int{}
+	waitlists := map[string]map[string]bool{}
+
+	// an empty schedule can come back as an empty list rather than an empty object
+	if strings.TrimSpace(string(data)) == "[]" {
+		return skateTimesMap, waitlists, nil
+	}
+	var payload availability
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return skateTimesMap, waitlists, err
+	}
+	if err := payload.validate(); err != nil {
+		return skateTimesMap, waitlists, err
+	}
+	for date, slots := range payload {
+		skateTimesMap[date] = map[string]int{}
+		waitlists[date] = map[string]bool{}
+		for skateTime, s := range slots {
+			skateTimesMap[date][skateTime] = s.Remaining
+			if s.Waitlist {
+				padded, _ := NormalizeSlotTime(skateTime)
+				waitlists[date][padded] = true
+			}
+		}
+	}
+	return skateTimesMap, waitlists, nil
+}
+
+// NormalizeSlotTime turns "9:00", "900" or "0900" into the left-padded HHMM keys Xola slots use
+func NormalizeSlotTime(value string) (string, bool) {
+	value = strings.Replace(strings.TrimSpace(value), ":", "", 1)
+	if len(value) == 3 {
+		value = "0" + value
+	}
+	if len(value) != 4 || strings.Trim(value, "0123456789") != "" {
+		return "", false
+	}
+	return value, true
+}
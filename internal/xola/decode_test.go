@@ -0,0 +1,65 @@
+package xola
+
+import "testing"
+
+func TestDecodeSkateTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		counts   map[string]map[string]int
+		waitlist map[string]bool
+		wantErr  bool
+	}{
+		{"bare counts", `{"2024-01-02": {"1500": 4, "1600": 0}}`, map[string]map[string]int{"2024-01-02": {"1500": 4, "1600": 0}}, nil, false},
+		{"empty list", `[]`, map[string]map[string]int{}, nil, false},
+		{"object slots", `{"2024-01-02": {"1500": {"available": 0, "waitlist": true}, "1600": {"count": 3}}}`, map[string]map[string]int{"2024-01-02": {"1500": 0, "1600": 3}}, map[string]bool{"1500": true}, false},
+		{"bad date key", `{"Jan 2": {"1500": 4}}`, nil, nil, true},
+		{"bad time key", `{"2024-01-02": {"noon": 4}}`, nil, nil, true},
+		{"negative count", `{"2024-01-02": {"1500": -1}}`, nil, nil, true},
+		{"not json", `<html>`, nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counts, waitlists, err := Decode([]byte(test.payload))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			for date, slots := range test.counts {
+				for skateTime, spots := range slots {
+					if counts[date][skateTime] != spots {
+						t.Errorf("%s %s = %d, want %d", date, skateTime, counts[date][skateTime], spots)
+					}
+				}
+			}
+			for skateTime := range test.waitlist {
+				if !waitlists["2024-01-02"][skateTime] {
+					t.Errorf("%s has no waitlist", skateTime)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeSlotTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"900", "0900", true},
+		{"9:00", "0900", true},
+		{"0900", "0900", true},
+		{" 15:30 ", "1530", true},
+		{"noon", "", false},
+		{"12345", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		if got, ok := NormalizeSlotTime(test.value); got != test.want || ok != test.ok {
+			t.Errorf("NormalizeSlotTime(%q) = %q, %v, want %q, %v", test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
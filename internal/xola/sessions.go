@@ -0,0 +1,33 @@
+package xola
+
+import "sort"
+
+// CleanSkateTimes drops empty slots for the date, left-pads times to HHMM and returns the times in sorted order
+func CleanSkateTimes(date string, skateTimesMap map[string]map[string]int) ([]string, map[string]int) {
+	return SortedSkateTimes(date, skateTimesMap, false)
+}
+
+// SortedSkateTimes is CleanSkateTimes with the option to keep sold out slots
+func SortedSkateTimes(date string, skateTimesMap map[string]map[string]int, keepSoldOut bool) ([]string, map[string]int) {
+	// Remove values where time slot count is 0
+	var skateTimesMapNoZeroValues = map[string]int{}
+	for k, v := range skateTimesMap[date] {
+		if v > 0 || keepSoldOut {
+			if len(k) == 3 {
+				k = "0" + k
+			}
+			skateTimesMapNoZeroValues[k] = v
+		}
+	}
+
+	// Go Maps do not iterate in insertion order, so we have to hack it to do so
+	// create slice and store keys
+	var keys = make([]string, 0, len(skateTimesMapNoZeroValues))
+	for k := range skateTimesMapNoZeroValues {
+		keys = append(keys, k)
+	}
+	// sort the slice by keys
+	sort.Strings(keys)
+
+	return keys, skateTimesMapNoZeroValues
+}
@@ -0,0 +1,6 @@
+// Package skatepb holds the gRPC stubs for skate.proto. They're generated and committed, after
+// changing skate.proto run `go generate ./proto/...` with protoc, protoc-gen-go and
+// protoc-gen-go-grpc installed.
+package skatepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative skate.proto
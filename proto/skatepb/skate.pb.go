@@ -0,0 +1,550 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: skate.proto
+
+package skatepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListRinksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRinksRequest) Reset() {
+	*x = ListRinksRequest{}
+	mi := &file_skate_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRinksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRinksRequest) ProtoMessage() {}
+
+func (x *ListRinksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRinksRequest.ProtoReflect.Descriptor instead.
+func (*ListRinksRequest) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{0}
+}
+
+type ListRinksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rinks         []*Rink                `protobuf:"bytes,1,rep,name=rinks,proto3" json:"rinks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRinksResponse) Reset() {
+	*x = ListRinksResponse{}
+	mi := &file_skate_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRinksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRinksResponse) ProtoMessage() {}
+
+func (x *ListRinksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRinksResponse.ProtoReflect.Descriptor instead.
+func (*ListRinksResponse) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListRinksResponse) GetRinks() []*Rink {
+	if x != nil {
+		return x.Rinks
+	}
+	return nil
+}
+
+type Rink struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rink) Reset() {
+	*x = Rink{}
+	mi := &file_skate_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rink) ProtoMessage() {}
+
+func (x *Rink) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rink.ProtoReflect.Descriptor instead.
+func (*Rink) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Rink) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Rink) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+type GetDayRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// rink defaults to bp
+	Rink string `protobuf:"bytes,1,opt,name=rink,proto3" json:"rink,omitempty"`
+	// date takes anything /api?startDate= does, defaults to today
+	Date           string `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	IncludeSoldOut bool   `protobuf:"varint,3,opt,name=include_sold_out,json=includeSoldOut,proto3" json:"include_sold_out,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetDayRequest) Reset() {
+	*x = GetDayRequest{}
+	mi := &file_skate_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDayRequest) ProtoMessage() {}
+
+func (x *GetDayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDayRequest.ProtoReflect.Descriptor instead.
+func (*GetDayRequest) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetDayRequest) GetRink() string {
+	if x != nil {
+		return x.Rink
+	}
+	return ""
+}
+
+func (x *GetDayRequest) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *GetDayRequest) GetIncludeSoldOut() bool {
+	if x != nil {
+		return x.IncludeSoldOut
+	}
+	return false
+}
+
+type GetDaysRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Rink           string                 `protobuf:"bytes,1,opt,name=rink,proto3" json:"rink,omitempty"`
+	Start          string                 `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`
+	End            string                 `protobuf:"bytes,3,opt,name=end,proto3" json:"end,omitempty"`
+	IncludeSoldOut bool                   `protobuf:"varint,4,opt,name=include_sold_out,json=includeSoldOut,proto3" json:"include_sold_out,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetDaysRequest) Reset() {
+	*x = GetDaysRequest{}
+	mi := &file_skate_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDaysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDaysRequest) ProtoMessage() {}
+
+func (x *GetDaysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDaysRequest.ProtoReflect.Descriptor instead.
+func (*GetDaysRequest) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetDaysRequest) GetRink() string {
+	if x != nil {
+		return x.Rink
+	}
+	return ""
+}
+
+func (x *GetDaysRequest) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *GetDaysRequest) GetEnd() string {
+	if x != nil {
+		return x.End
+	}
+	return ""
+}
+
+func (x *GetDaysRequest) GetIncludeSoldOut() bool {
+	if x != nil {
+		return x.IncludeSoldOut
+	}
+	return false
+}
+
+type Day struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Rink  string                 `protobuf:"bytes,1,opt,name=rink,proto3" json:"rink,omitempty"`
+	// date is YYYY-MM-DD
+	Date          string     `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	Closed        bool       `protobuf:"varint,3,opt,name=closed,proto3" json:"closed,omitempty"`
+	TotalSpots    int32      `protobuf:"varint,4,opt,name=total_spots,json=totalSpots,proto3" json:"total_spots,omitempty"`
+	Sessions      []*Session `protobuf:"bytes,5,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Day) Reset() {
+	*x = Day{}
+	mi := &file_skate_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Day) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Day) ProtoMessage() {}
+
+func (x *Day) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Day.ProtoReflect.Descriptor instead.
+func (*Day) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Day) GetRink() string {
+	if x != nil {
+		return x.Rink
+	}
+	return ""
+}
+
+func (x *Day) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *Day) GetClosed() bool {
+	if x != nil {
+		return x.Closed
+	}
+	return false
+}
+
+func (x *Day) GetTotalSpots() int32 {
+	if x != nil {
+		return x.TotalSpots
+	}
+	return 0
+}
+
+func (x *Day) GetSessions() []*Session {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+type Session struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// time is HH:MM in the venue's time zone
+	Time              string `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Spots             int32  `protobuf:"varint,2,opt,name=spots,proto3" json:"spots,omitempty"`
+	Limited           bool   `protobuf:"varint,3,opt,name=limited,proto3" json:"limited,omitempty"`
+	Surface           string `protobuf:"bytes,4,opt,name=surface,proto3" json:"surface,omitempty"`
+	Accessible        bool   `protobuf:"varint,5,opt,name=accessible,proto3" json:"accessible,omitempty"`
+	WaitlistAvailable bool   `protobuf:"varint,6,opt,name=waitlist_available,json=waitlistAvailable,proto3" json:"waitlist_available,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_skate_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_skate_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_skate_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Session) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *Session) GetSpots() int32 {
+	if x != nil {
+		return x.Spots
+	}
+	return 0
+}
+
+func (x *Session) GetLimited() bool {
+	if x != nil {
+		return x.Limited
+	}
+	return false
+}
+
+func (x *Session) GetSurface() string {
+	if x != nil {
+		return x.Surface
+	}
+	return ""
+}
+
+func (x *Session) GetAccessible() bool {
+	if x != nil {
+		return x.Accessible
+	}
+	return false
+}
+
+func (x *Session) GetWaitlistAvailable() bool {
+	if x != nil {
+		return x.WaitlistAvailable
+	}
+	return false
+}
+
+var File_skate_proto protoreflect.FileDescriptor
+
+const file_skate_proto_rawDesc = "" +
+	"\n" +
+	"\vskate.proto\x12\n" +
+	"bpskate.v1\"\x12\n" +
+	"\x10ListRinksRequest\";\n" +
+	"\x11ListRinksResponse\x12&\n" +
+	"\x05rinks\x18\x01 \x03(\v2\x10.bpskate.v1.RinkR\x05rinks\"0\n" +
+	"\x04Rink\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05label\x18\x02 \x01(\tR\x05label\"a\n" +
+	"\rGetDayRequest\x12\x12\n" +
+	"\x04rink\x18\x01 \x01(\tR\x04rink\x12\x12\n" +
+	"\x04date\x18\x02 \x01(\tR\x04date\x12(\n" +
+	"\x10include_sold_out\x18\x03 \x01(\bR\x0eincludeSoldOut\"v\n" +
+	"\x0eGetDaysRequest\x12\x12\n" +
+	"\x04rink\x18\x01 \x01(\tR\x04rink\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\tR\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\tR\x03end\x12(\n" +
+	"\x10include_sold_out\x18\x04 \x01(\bR\x0eincludeSoldOut\"\x97\x01\n" +
+	"\x03Day\x12\x12\n" +
+	"\x04rink\x18\x01 \x01(\tR\x04rink\x12\x12\n" +
+	"\x04date\x18\x02 \x01(\tR\x04date\x12\x16\n" +
+	"\x06closed\x18\x03 \x01(\bR\x06closed\x12\x1f\n" +
+	"\vtotal_spots\x18\x04 \x01(\x05R\n" +
+	"totalSpots\x12/\n" +
+	"\bsessions\x18\x05 \x03(\v2\x13.bpskate.v1.SessionR\bsessions\"\xb6\x01\n" +
+	"\aSession\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\tR\x04time\x12\x14\n" +
+	"\x05spots\x18\x02 \x01(\x05R\x05spots\x12\x18\n" +
+	"\alimited\x18\x03 \x01(\bR\alimited\x12\x18\n" +
+	"\asurface\x18\x04 \x01(\tR\asurface\x12\x1e\n" +
+	"\n" +
+	"accessible\x18\x05 \x01(\bR\n" +
+	"accessible\x12-\n" +
+	"\x12waitlist_available\x18\x06 \x01(\bR\x11waitlistAvailable2\xc8\x01\n" +
+	"\fAvailability\x12H\n" +
+	"\tListRinks\x12\x1c.bpskate.v1.ListRinksRequest\x1a\x1d.bpskate.v1.ListRinksResponse\x124\n" +
+	"\x06GetDay\x12\x19.bpskate.v1.GetDayRequest\x1a\x0f.bpskate.v1.Day\x128\n" +
+	"\aGetDays\x12\x1a.bpskate.v1.GetDaysRequest\x1a\x0f.bpskate.v1.Day0\x01B0Z.github.com/andrewwong97/bp-skate/proto/skatepbb\x06proto3"
+
+var (
+	file_skate_proto_rawDescOnce sync.Once
+	file_skate_proto_rawDescData []byte
+)
+
+func file_skate_proto_rawDescGZIP() []byte {
+	file_skate_proto_rawDescOnce.Do(func() {
+		file_skate_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_skate_proto_rawDesc), len(file_skate_proto_rawDesc)))
+	})
+	return file_skate_proto_rawDescData
+}
+
+var file_skate_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_skate_proto_goTypes = []any{
+	(*ListRinksRequest)(nil),  // 0: bpskate.v1.ListRinksRequest
+	(*ListRinksResponse)(nil), // 1: bpskate.v1.ListRinksResponse
+	(*Rink)(nil),              // 2: bpskate.v1.Rink
+	(*GetDayRequest)(nil),     // 3: bpskate.v1.GetDayRequest
+	(*GetDaysRequest)(nil),    // 4: bpskate.v1.GetDaysRequest
+	(*Day)(nil),               // 5: bpskate.v1.Day
+	(*Session)(nil),           // 6: bpskate.v1.Session
+}
+var file_skate_proto_depIdxs = []int32{
+	2, // 0: bpskate.v1.ListRinksResponse.rinks:type_name -> bpskate.v1.Rink
+	6, // 1: bpskate.v1.Day.sessions:type_name -> bpskate.v1.Session
+	0, // 2: bpskate.v1.Availability.ListRinks:input_type -> bpskate.v1.ListRinksRequest
+	3, // 3: bpskate.v1.Availability.GetDay:input_type -> bpskate.v1.GetDayRequest
+	4, // 4: bpskate.v1.Availability.GetDays:input_type -> bpskate.v1.GetDaysRequest
+	1, // 5: bpskate.v1.Availability.ListRinks:output_type -> bpskate.v1.ListRinksResponse
+	5, // 6: bpskate.v1.Availability.GetDay:output_type -> bpskate.v1.Day
+	5, // 7: bpskate.v1.Availability.GetDays:output_type -> bpskate.v1.Day
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_skate_proto_init() }
+func file_skate_proto_init() {
+	if File_skate_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_skate_proto_rawDesc), len(file_skate_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_skate_proto_goTypes,
+		DependencyIndexes: file_skate_proto_depIdxs,
+		MessageInfos:      file_skate_proto_msgTypes,
+	}.Build()
+	File_skate_proto = out.File
+	file_skate_proto_goTypes = nil
+	file_skate_proto_depIdxs = nil
+}
@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: skate.proto
+
+package skatepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Availability_ListRinks_FullMethodName = "/bpskate.v1.Availability/ListRinks"
+	Availability_GetDay_FullMethodName    = "/bpskate.v1.Availability/GetDay"
+	Availability_GetDays_FullMethodName   = "/bpskate.v1.Availability/GetDays"
+)
+
+// AvailabilityClient is the client API for Availability service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Availability is the same lookup as /api, for services that want typed stubs.
+// Served by cmd/server when built with -tags grpc and GRPC_PORT is set.
+type AvailabilityClient interface {
+	// ListRinks lists the rinks from RINKS, or just Bryant Park
+	ListRinks(ctx context.Context, in *ListRinksRequest, opts ...grpc.CallOption) (*ListRinksResponse, error)
+	// GetDay is one date's sessions
+	GetDay(ctx context.Context, in *GetDayRequest, opts ...grpc.CallOption) (*Day, error)
+	// GetDays streams each day from start to end inclusive, in order
+	GetDays(ctx context.Context, in *GetDaysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Day], error)
+}
+
+type availabilityClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAvailabilityClient(cc grpc.ClientConnInterface) AvailabilityClient {
+	return &availabilityClient{cc}
+}
+
+func (c *availabilityClient) ListRinks(ctx context.Context, in *ListRinksRequest, opts ...grpc.CallOption) (*ListRinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRinksResponse)
+	err := c.cc.Invoke(ctx, Availability_ListRinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *availabilityClient) GetDay(ctx context.Context, in *GetDayRequest, opts ...grpc.CallOption) (*Day, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Day)
+	err := c.cc.Invoke(ctx, Availability_GetDay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *availabilityClient) GetDays(ctx context.Context, in *GetDaysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Day], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Availability_ServiceDesc.Streams[0], Availability_GetDays_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetDaysRequest, Day]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Availability_GetDaysClient = grpc.ServerStreamingClient[Day]
+
+// AvailabilityServer is the server API for Availability service.
+// All implementations must embed UnimplementedAvailabilityServer
+// for forward compatibility.
+//
+// Availability is the same lookup as /api, for services that want typed stubs.
+// Served by cmd/server when built with -tags grpc and GRPC_PORT is set.
+type AvailabilityServer interface {
+	// ListRinks lists the rinks from RINKS, or just Bryant Park
+	ListRinks(context.Context, *ListRinksRequest) (*ListRinksResponse, error)
+	// GetDay is one date's sessions
+	GetDay(context.Context, *GetDayRequest) (*Day, error)
+	// GetDays streams each day from start to end inclusive, in order
+	GetDays(*GetDaysRequest, grpc.ServerStreamingServer[Day]) error
+	mustEmbedUnimplementedAvailabilityServer()
+}
+
+// UnimplementedAvailabilityServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAvailabilityServer struct{}
+
+func (UnimplementedAvailabilityServer) ListRinks(context.Context, *ListRinksRequest) (*ListRinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRinks not implemented")
+}
+func (UnimplementedAvailabilityServer) GetDay(context.Context, *GetDayRequest) (*Day, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDay not implemented")
+}
+func (UnimplementedAvailabilityServer) GetDays(*GetDaysRequest, grpc.ServerStreamingServer[Day]) error {
+	return status.Errorf(codes.Unimplemented, "method GetDays not implemented")
+}
+func (UnimplementedAvailabilityServer) mustEmbedUnimplementedAvailabilityServer() {}
+func (UnimplementedAvailabilityServer) testEmbeddedByValue()                      {}
+
+// UnsafeAvailabilityServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AvailabilityServer will
+// result in compilation errors.
+type UnsafeAvailabilityServer interface {
+	mustEmbedUnimplementedAvailabilityServer()
+}
+
+func RegisterAvailabilityServer(s grpc.ServiceRegistrar, srv AvailabilityServer) {
+	// If the following call pancis, it indicates UnimplementedAvailabilityServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Availability_ServiceDesc, srv)
+}
+
+func _Availability_ListRinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvailabilityServer).ListRinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Availability_ListRinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvailabilityServer).ListRinks(ctx, req.(*ListRinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Availability_GetDay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvailabilityServer).GetDay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Availability_GetDay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvailabilityServer).GetDay(ctx, req.(*GetDayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Availability_GetDays_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetDaysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AvailabilityServer).GetDays(m, &grpc.GenericServerStream[GetDaysRequest, Day]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Availability_GetDaysServer = grpc.ServerStreamingServer[Day]
+
+// Availability_ServiceDesc is the grpc.ServiceDesc for Availability service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Availability_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bpskate.v1.Availability",
+	HandlerType: (*AvailabilityServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRinks",
+			Handler:    _Availability_ListRinks_Handler,
+		},
+		{
+			MethodName: "GetDay",
+			Handler:    _Availability_GetDay_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetDays",
+			Handler:       _Availability_GetDays_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "skate.proto",
+}